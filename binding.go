@@ -0,0 +1,193 @@
+package gojq
+
+import "strings"
+
+// VariableBinding returns the byte offset of the binding of the variable
+// referenced at the offset in the source text, which the query is parsed from.
+// The binding is the variable pattern (like . as $x and reduce .[] as [$x]
+// (...)) or the parameter of the function definition (like def f($x): ...) in
+// the scope of the reference, and the offset should be at the start of the
+// variable name. When the offset is at the binding, this method returns the
+// offset as it is. This method returns -1 if no variable is referenced at the
+// offset, or the variable is not bound in the query (like $ENV, and the
+// variables given by [WithVariables]).
+func (e *Query) VariableBinding(offset int) int {
+	f := &bindingFinder{offset: offset + 1, result: -1}
+	f.query(e)
+	return f.result
+}
+
+type bindingFinder struct {
+	offset int // the offset to find plus one, like the offsets in the queries
+	scope  []varBinding
+	result int
+	done   bool
+}
+
+type varBinding struct {
+	name   string
+	offset int
+}
+
+func (f *bindingFinder) bind(name string, offset int) {
+	if offset > 0 && offset == f.offset {
+		f.result, f.done = offset-1, true
+	}
+	f.scope = append(f.scope, varBinding{name, offset})
+}
+
+func (f *bindingFinder) reference(name string, offset int) {
+	if offset == 0 || offset != f.offset {
+		return
+	}
+	for i := len(f.scope) - 1; i >= 0; i-- {
+		if b := f.scope[i]; b.name == name {
+			if b.offset > 0 {
+				f.result = b.offset - 1
+			}
+			break
+		}
+	}
+	f.done = true
+}
+
+func (f *bindingFinder) query(e *Query) {
+	if e == nil || f.done {
+		return
+	}
+	for _, fd := range e.FuncDefs {
+		f.funcDef(fd)
+	}
+	f.term(e.Term)
+	f.query(e.Left)
+	f.query(e.Right)
+}
+
+func (f *bindingFinder) funcDef(e *FuncDef) {
+	defer func(l int) { f.scope = f.scope[:l] }(len(f.scope))
+	for i, arg := range e.Args {
+		if arg[0] == '$' && i < len(e.argOffsets) {
+			f.bind(arg, e.argOffsets[i])
+		}
+	}
+	f.query(e.Body)
+}
+
+func (f *bindingFinder) term(e *Term) {
+	if e == nil || f.done {
+		return
+	}
+	f.index(e.Index)
+	if e.Func != nil {
+		if e.Func.Name[0] == '$' {
+			f.reference(e.Func.Name, e.Func.offset)
+		}
+		for _, arg := range e.Func.Args {
+			f.query(arg)
+		}
+	}
+	if e.Object != nil {
+		for _, kv := range e.Object.KeyVals {
+			if strings.HasPrefix(kv.Key, "$") {
+				f.reference(kv.Key, kv.offset)
+			}
+			f.string(kv.KeyString)
+			f.query(kv.KeyQuery)
+			if kv.Val != nil {
+				for _, q := range kv.Val.Queries {
+					f.query(q)
+				}
+			}
+		}
+	}
+	if e.Array != nil {
+		f.query(e.Array.Query)
+	}
+	if e.Unary != nil {
+		f.term(e.Unary.Term)
+	}
+	f.string(e.Str)
+	if e.If != nil {
+		f.query(e.If.Cond)
+		f.query(e.If.Then)
+		for _, elif := range e.If.Elif {
+			f.query(elif.Cond)
+			f.query(elif.Then)
+		}
+		f.query(e.If.Else)
+	}
+	if e.Try != nil {
+		f.query(e.Try.Body)
+		f.query(e.Try.Catch)
+	}
+	if e.Reduce != nil {
+		f.term(e.Reduce.Term)
+		f.query(e.Reduce.Start)
+		l := len(f.scope)
+		f.pattern(e.Reduce.Pattern)
+		f.query(e.Reduce.Update)
+		f.scope = f.scope[:l]
+	}
+	if e.Foreach != nil {
+		f.term(e.Foreach.Term)
+		f.query(e.Foreach.Start)
+		l := len(f.scope)
+		f.pattern(e.Foreach.Pattern)
+		f.query(e.Foreach.Update)
+		f.query(e.Foreach.Extract)
+		f.scope = f.scope[:l]
+	}
+	if e.Label != nil {
+		f.query(e.Label.Body)
+	}
+	f.query(e.Query)
+	for _, s := range e.SuffixList {
+		f.index(s.Index)
+		if s.Bind != nil {
+			l := len(f.scope)
+			for _, p := range s.Bind.Patterns {
+				f.pattern(p)
+			}
+			f.query(s.Bind.Body)
+			f.scope = f.scope[:l]
+		}
+	}
+}
+
+func (f *bindingFinder) pattern(e *Pattern) {
+	if e == nil || f.done {
+		return
+	}
+	if e.Name != "" {
+		f.bind(e.Name, e.offset)
+	}
+	for _, p := range e.Array {
+		f.pattern(p)
+	}
+	for _, kv := range e.Object {
+		if strings.HasPrefix(kv.Key, "$") {
+			f.bind(kv.Key, kv.offset)
+		}
+		f.string(kv.KeyString)
+		f.query(kv.KeyQuery)
+		f.pattern(kv.Val)
+	}
+}
+
+func (f *bindingFinder) index(e *Index) {
+	if e == nil {
+		return
+	}
+	f.string(e.Str)
+	f.query(e.Start)
+	f.query(e.End)
+}
+
+func (f *bindingFinder) string(e *String) {
+	if e == nil {
+		return
+	}
+	for _, q := range e.Queries {
+		f.query(q)
+	}
+}
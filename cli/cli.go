@@ -5,10 +5,13 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/mattn/go-isatty"
 
@@ -35,17 +38,21 @@ type cli struct {
 	outStream io.Writer
 	errStream io.Writer
 
-	outputRaw     bool
-	outputRaw0    bool
-	outputJoin    bool
-	outputCompact bool
-	outputIndent  *int
-	outputTab     bool
-	outputYAML    bool
-	inputRaw      bool
-	inputStream   bool
-	inputYAML     bool
-	inputSlurp    bool
+	outputRaw          bool
+	outputRaw0         bool
+	outputJoin         bool
+	outputCompact      bool
+	outputIndent       *int
+	outputTab          bool
+	outputPrec         int
+	outputYAML         bool
+	inputRaw           bool
+	inputStream        bool
+	inputYAML          bool
+	inputSlurp         bool
+	inputFollow        bool
+	inputIgnoreMissing bool
+	inputExts          []string
 
 	argnames  []string
 	argvalues []any
@@ -61,6 +68,7 @@ type flagopts struct {
 	OutputCompact bool              `short:"c" long:"compact-output" description:"output without pretty-printing"`
 	OutputIndent  *int              `long:"indent" description:"number of spaces for indentation"`
 	OutputTab     bool              `long:"tab" description:"use tabs for indentation"`
+	OutputPrec    *int              `long:"precision" description:"number of significant digits of numbers"`
 	OutputYAML    bool              `long:"yaml-output" description:"output in YAML format"`
 	OutputColor   bool              `short:"C" long:"color-output" description:"output with colors even if piped"`
 	OutputMono    bool              `short:"M" long:"monochrome-output" description:"output without colors"`
@@ -69,7 +77,10 @@ type flagopts struct {
 	InputStream   bool              `long:"stream" description:"parse input in stream fashion"`
 	InputYAML     bool              `long:"yaml-input" description:"read input as YAML format"`
 	InputSlurp    bool              `short:"s" long:"slurp" description:"read all inputs into an array"`
-	FromFile      string            `short:"f" long:"from-file" description:"load query from file"`
+	InputFollow   bool              `long:"follow" description:"wait for values appended to the last input file"`
+	IgnoreMissing bool              `long:"ignore-missing" description:"treat missing input files as empty"`
+	InputExt      string            `long:"ext" description:"comma-separated extensions of files to read in directories"`
+	FromFile      string            `short:"f" long:"from-file" query:"" description:"load query from file"`
 	ModulePaths   []string          `short:"L" description:"directory to search modules from"`
 	Arg           map[string]string `long:"arg" description:"set a string value to a variable"`
 	ArgJSON       map[string]string `long:"argjson" description:"set a JSON value to a variable"`
@@ -77,6 +88,9 @@ type flagopts struct {
 	RawFile       map[string]string `long:"rawfile" description:"set the contents of a file to a variable"`
 	Args          []any             `long:"args" positional:"" description:"consume remaining arguments as positional string values"`
 	JSONArgs      []any             `long:"jsonargs" positional:"" description:"consume remaining arguments as positional JSON values"`
+	Deterministic string            `long:"deterministic" description:"pin now to the timestamp and disable environment variables"`
+	RunTests      bool              `long:"run-tests" description:"run jq tests in the files or standard input"`
+	DiffJQ        bool              `long:"diff-jq" description:"compare the outputs with the jq command"`
 	ExitStatus    bool              `short:"e" long:"exit-status" description:"exit 1 when the last value is false or null"`
 	Version       bool              `short:"v" long:"version" description:"display version information"`
 	Help          bool              `short:"h" long:"help" description:"display this help information"`
@@ -96,7 +110,16 @@ func (cli *cli) run(args []string) int {
 }
 
 func (cli *cli) runInternal(args []string) (err error) {
+	if len(args) > 0 {
+		switch args[0] {
+		case "tool":
+			return cli.runTool(args[1:])
+		case "test":
+			return cli.runTest(args[1:])
+		}
+	}
 	var opts flagopts
+	rawArgs := append([]string{}, args...)
 	args, err = parseFlags(args, &opts)
 	if err != nil {
 		return &flagParseError{err}
@@ -111,6 +134,8 @@ Synopsis:
 
 Usage:
   %[1]s [OPTIONS]
+  %[1]s tool {fmt|check|lint|lsp} [OPTIONS] [FILES...]
+  %[1]s test [OPTIONS] [FILES...]
 
 `,
 			name, version, revision, runtime.Version())
@@ -121,6 +146,9 @@ Usage:
 		fmt.Fprintf(cli.outStream, "%s %s (rev: %s/%s)\n", name, version, revision, runtime.Version())
 		return nil
 	}
+	if opts.DiffJQ {
+		return cli.runDiffJQ(rawArgs)
+	}
 	cli.outputRaw, cli.outputRaw0, cli.outputJoin,
 		cli.outputCompact, cli.outputIndent, cli.outputTab, cli.outputYAML =
 		opts.OutputRaw, opts.OutputRaw0, opts.OutputJoin,
@@ -148,11 +176,36 @@ Usage:
 			return fmt.Errorf("negative indentation count: %d", *i)
 		}
 	}
+	if p := opts.OutputPrec; p != nil {
+		if *p <= 0 {
+			return fmt.Errorf("non-positive precision: %d", *p)
+		}
+		cli.outputPrec = *p
+	}
 	if opts.OutputYAML && opts.OutputTab {
 		return errors.New("cannot use tabs for YAML output")
 	}
-	cli.inputRaw, cli.inputStream, cli.inputYAML, cli.inputSlurp =
-		opts.InputRaw, opts.InputStream, opts.InputYAML, opts.InputSlurp
+	var now *time.Time
+	if opts.Deterministic != "" {
+		t, err := parseTimestamp(opts.Deterministic)
+		if err != nil {
+			return err
+		}
+		now = &t
+	}
+	if opts.InputFollow && opts.InputSlurp {
+		return errors.New("cannot follow inputs with --slurp")
+	}
+	cli.inputRaw, cli.inputStream, cli.inputYAML, cli.inputSlurp, cli.inputFollow =
+		opts.InputRaw, opts.InputStream, opts.InputYAML, opts.InputSlurp, opts.InputFollow
+	cli.inputIgnoreMissing = opts.IgnoreMissing
+	if opts.InputExt != "" {
+		for _, ext := range strings.Split(opts.InputExt, ",") {
+			if ext = strings.TrimPrefix(strings.TrimSpace(ext), "."); ext != "" {
+				cli.inputExts = append(cli.inputExts, ext)
+			}
+		}
+	}
 	for k, v := range opts.Arg {
 		cli.argnames = append(cli.argnames, "$"+k)
 		cli.argvalues = append(cli.argvalues, v)
@@ -204,6 +257,10 @@ Usage:
 		"named":      named,
 		"positional": positional,
 	})
+	modulePaths := resolveModulePaths(opts.ModulePaths)
+	if opts.RunTests {
+		return cli.runTests(args, modulePaths)
+	}
 	var arg, fname string
 	if opts.FromFile != "" {
 		src, err := os.ReadFile(opts.FromFile)
@@ -229,30 +286,39 @@ Usage:
 	if err != nil {
 		return &queryParseError{fname, arg, err}
 	}
-	modulePaths := opts.ModulePaths
-	if len(modulePaths) == 0 && addDefaultModulePaths {
-		modulePaths = listDefaultModulePaths()
-	}
 	iter := cli.createInputIter(args)
 	defer iter.Close()
-	code, err := gojq.Compile(query,
+	// The query and the main loop share the inputs read ahead by peek_input.
+	inputs := newPeekInputIter(iter)
+	options := []gojq.CompilerOption{
 		gojq.WithModuleLoader(gojq.NewModuleLoader(modulePaths)),
 		gojq.WithEnvironLoader(os.Environ),
 		gojq.WithVariables(cli.argnames),
-		gojq.WithFunction("debug", 0, 0, cli.funcDebug),
-		gojq.WithFunction("stderr", 0, 0, cli.funcStderr),
+		gojq.WithDebugHandler(cli.handleDebug),
+		gojq.WithStderr(cli.errStream),
 		gojq.WithFunction("input_filename", 0, 0,
 			func(iter inputIter) func(any, []any) any {
 				return func(any, []any) any {
-					if fname := iter.Name(); fname != "" && (len(args) > 0 || !opts.InputNull) {
+					if fname := iter.Name(); fname != "" && fname != stdinName {
 						return fname
 					}
 					return nil
 				}
-			}(iter),
+			}(inputs),
 		),
-		gojq.WithInputIter(iter),
-	)
+		gojq.WithFunction("input_line_number", 0, 0,
+			func(iter inputIter) func(any, []any) any {
+				return func(any, []any) any {
+					return inputLineNumber(iter)
+				}
+			}(inputs),
+		),
+		gojq.WithInputIter(inputs),
+	}
+	if now != nil {
+		options = append(options, gojq.WithDeterministic(*now))
+	}
+	code, err := gojq.Compile(query, options...)
 	if err != nil {
 		if err, ok := err.(interface {
 			QueryParseError() (string, string, error)
@@ -269,9 +335,41 @@ Usage:
 		return &compileError{err}
 	}
 	if opts.InputNull {
-		iter = newNullInputIter()
+		inputs = newNullInputIter()
 	}
-	return cli.process(iter, code)
+	return cli.process(inputs, code)
+}
+
+// Parses the timestamp for --deterministic option, in the seconds since the
+// Unix epoch or the RFC 3339 format.
+func parseTimestamp(s string) (time.Time, error) {
+	if f, err := strconv.ParseFloat(s, 64); err == nil && !math.IsNaN(f) && !math.IsInf(f, 0) {
+		sec := math.Floor(f)
+		return time.Unix(int64(sec), int64((f-sec)*1e9)), nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return t, fmt.Errorf("invalid timestamp for --deterministic: %q", s)
+	}
+	return t, nil
+}
+
+// Returns the module paths given by the -L option, or the default paths. Like
+// jq, ~/.jq is loaded as the init module even if the paths are given.
+func resolveModulePaths(modulePaths []string) []string {
+	if !addDefaultModulePaths {
+		return modulePaths
+	}
+	if len(modulePaths) == 0 {
+		return listDefaultModulePaths()
+	}
+	if homeDir, err := os.UserHomeDir(); err == nil {
+		path := filepath.Join(homeDir, ".jq")
+		if fi, err := os.Stat(path); err == nil && fi.Mode().IsRegular() {
+			modulePaths = append(modulePaths, path)
+		}
+	}
+	return modulePaths
 }
 
 func listDefaultModulePaths() []string {
@@ -293,7 +391,7 @@ func listDefaultModulePaths() []string {
 
 func slurpFile(name string) (any, error) {
 	iter := newSlurpInputIter(
-		newFilesInputIter(newJSONInputIter, []string{name}, nil),
+		newFilesInputIter(newJSONInputIter, []string{name}, nil, filesInputOptions{}),
 	)
 	defer iter.Close()
 	val, _ := iter.Next()
@@ -329,12 +427,16 @@ func (cli *cli) createInputIter(args []string) (iter inputIter) {
 		}()
 	}
 	if len(args) == 0 {
-		return newIter(cli.inStream, "<stdin>")
+		return newIter(cli.inStream, stdinName)
 	}
-	return newFilesInputIter(newIter, args, cli.inStream)
+	return newFilesInputIter(newIter, args, cli.inStream, filesInputOptions{
+		follow:        cli.inputFollow,
+		ignoreMissing: cli.inputIgnoreMissing,
+		exts:          cli.inputExts,
+	})
 }
 
-func (cli *cli) process(iter inputIter, code *gojq.Code) error {
+func (cli *cli) process(iter gojq.Iter, code *gojq.Code) error {
 	var err error
 	for {
 		v, ok := iter.Next()
@@ -402,29 +504,19 @@ func (cli *cli) createMarshaler() marshaler {
 		indent = *i
 	}
 	f := newEncoder(cli.outputTab, indent)
+	f.prec = cli.outputPrec
 	if cli.outputRaw || cli.outputRaw0 || cli.outputJoin {
 		return &rawMarshaler{f, cli.outputRaw0}
 	}
 	return f
 }
 
-func (cli *cli) funcDebug(v any, _ []any) any {
-	if err := newEncoder(false, 0).
-		marshal([]any{"DEBUG:", v}, cli.errStream); err != nil {
-		return err
-	}
-	if _, err := cli.errStream.Write([]byte{'\n'}); err != nil {
-		return err
-	}
-	return v
-}
-
-func (cli *cli) funcStderr(v any, _ []any) any {
-	if err := (&rawMarshaler{m: newEncoder(false, 0)}).
-		marshal(v, cli.errStream); err != nil {
+func (cli *cli) handleDebug(msg any) error {
+	if err := newEncoder(false, 0).marshal(msg, cli.errStream); err != nil {
 		return err
 	}
-	return v
+	_, err := cli.errStream.Write([]byte{'\n'})
+	return err
 }
 
 func (cli *cli) printError(err error) {
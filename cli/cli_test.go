@@ -2,6 +2,8 @@ package cli
 
 import (
 	"os"
+	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -120,3 +122,89 @@ func TestCliRun(t *testing.T) {
 		})
 	}
 }
+
+func TestCliRun_DiffJQ(t *testing.T) {
+	if _, err := exec.LookPath("jq"); err != nil {
+		t.Skip("jq command is not found")
+	}
+	testCases := []struct {
+		args     []string
+		expected string
+		error    string
+	}{
+		{
+			args:     []string{"--diff-jq", "-c", ".a"},
+			expected: "{\"a\":2,\"b\":1}\n",
+		},
+		{
+			args:     []string{"-c", "--diff-jq", ".a[]"},
+			expected: "2\n1\n",
+			error:    "output differs from jq\n--- gojq\n+++ jq\n-2\n-1\n+1\n+2\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(strings.Join(tc.args, " "), func(t *testing.T) {
+			var outStream, errStream strings.Builder
+			cli := cli{
+				inStream:  strings.NewReader(`{"a":{"b":1,"a":2}}`),
+				outStream: &outStream,
+				errStream: &errStream,
+			}
+			code := cli.run(tc.args)
+			if tc.error == "" && code != exitCodeOK || tc.error != "" && code != exitCodeDefaultErr {
+				t.Errorf("unexpected exit code: %d", code)
+			}
+			if diff := cmp.Diff(tc.expected, outStream.String()); diff != "" {
+				t.Error("standard output:\n" + diff)
+			}
+			if !strings.HasSuffix(errStream.String(), tc.error) {
+				t.Error("standard error output:\n" + cmp.Diff(tc.error, errStream.String()))
+			}
+		})
+	}
+}
+
+func TestCliRun_Follow(t *testing.T) {
+	defer func(d time.Duration) { followInterval = d }(followInterval)
+	followInterval = time.Millisecond
+	fname := filepath.Join(t.TempDir(), "follow.json")
+	if err := os.WriteFile(fname, []byte("1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var outStream, errStream strings.Builder
+	cli := cli{
+		inStream:  strings.NewReader(""),
+		outStream: &outStream,
+		errStream: &errStream,
+	}
+	done := make(chan int)
+	go func() {
+		done <- cli.run([]string{"-n", "-c", "--follow", "[limit(3; inputs)]", fname})
+	}()
+	time.Sleep(10 * time.Millisecond)
+	f, err := os.OpenFile(fname, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	for _, s := range []string{"2\n{", `"a":`, "3}\n"} {
+		if _, err := f.WriteString(s); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	select {
+	case code := <-done:
+		if code != exitCodeOK {
+			t.Errorf("exit code: got: %v, expected: %v", code, exitCodeOK)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out on following the appended contents")
+	}
+	if diff := cmp.Diff("[1,2,{\"a\":3}]\n", outStream.String()); diff != "" {
+		t.Error("standard output:\n" + diff)
+	}
+	if diff := cmp.Diff("", errStream.String()); diff != "" {
+		t.Error("standard error output:\n" + diff)
+	}
+}
@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os/exec"
+
+	"github.com/rturpen/gojq"
+)
+
+// Runs the same arguments and input with gojq and the jq command in the PATH,
+// and reports the divergence of the outputs and the exit codes.
+func (cli *cli) runDiffJQ(args []string) error {
+	path, err := exec.LookPath("jq")
+	if err != nil {
+		return err
+	}
+	xs := make([]string, 0, len(args))
+	for _, arg := range args {
+		if arg != "--diff-jq" {
+			xs = append(xs, arg)
+		}
+	}
+	input, err := io.ReadAll(cli.inStream)
+	if err != nil {
+		return err
+	}
+	var outStream, errStream, jqOutStream bytes.Buffer
+	c := *cli
+	c.inStream, c.outStream, c.errStream = bytes.NewReader(input), &outStream, &errStream
+	code := c.run(xs)
+	cmd := exec.Command(path, xs...)
+	cmd.Stdin, cmd.Stdout = bytes.NewReader(input), &jqOutStream
+	var jqCode int
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return err
+		}
+		jqCode = exitErr.ExitCode()
+	}
+	cli.outStream.Write(outStream.Bytes())
+	cli.errStream.Write(errStream.Bytes())
+	if code != jqCode || !sameOutputs(outStream.Bytes(), jqOutStream.Bytes()) {
+		return &diffJQError{outStream.String(), jqOutStream.String(), code, jqCode}
+	}
+	if code != exitCodeOK {
+		return &exitCodeError{code}
+	}
+	return nil
+}
+
+// Reports whether the outputs are the same, or the same JSON values ignoring
+// the formatting and the order of object keys.
+func sameOutputs(xs, ys []byte) bool {
+	if bytes.Equal(xs, ys) {
+		return true
+	}
+	iter1 := newJSONInputIter(bytes.NewReader(xs), "<gojq>")
+	defer iter1.Close()
+	iter2 := newJSONInputIter(bytes.NewReader(ys), "<jq>")
+	defer iter2.Close()
+	for {
+		v1, ok1 := iter1.Next()
+		v2, ok2 := iter2.Next()
+		if !ok1 || !ok2 {
+			return ok1 == ok2
+		}
+		if _, ok := v1.(error); ok {
+			return false
+		}
+		if _, ok := v2.(error); ok {
+			return false
+		}
+		if gojq.Compare(v1, v2) != 0 {
+			return false
+		}
+	}
+}
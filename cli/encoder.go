@@ -17,6 +17,7 @@ type encoder struct {
 	tab    bool
 	indent int
 	depth  int
+	prec   int
 	buf    [64]byte
 }
 
@@ -86,10 +87,15 @@ func (e *encoder) encodeFloat64(f float64) {
 		e.write([]byte("null"), nullColor)
 		return
 	}
+	if e.prec > 0 {
+		f, _ = strconv.ParseFloat(string(strconv.AppendFloat(e.buf[:0], f, 'g', e.prec, 64)), 64)
+	}
 	if f >= math.MaxFloat64 {
 		f = math.MaxFloat64
 	} else if f <= -math.MaxFloat64 {
 		f = -math.MaxFloat64
+	} else if f == 0 {
+		f = 0 // normalize negative zero
 	}
 	format := byte('f')
 	if x := math.Abs(f); x != 0 && x < 1e-6 || x >= 1e21 {
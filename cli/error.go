@@ -19,6 +19,10 @@ func (*emptyError) Error() string {
 	return ""
 }
 
+func (err *emptyError) Unwrap() error {
+	return err.err
+}
+
 func (*emptyError) IsEmptyError() bool {
 	return true
 }
@@ -54,6 +58,10 @@ func (err *flagParseError) Error() string {
 	return err.err.Error()
 }
 
+func (err *flagParseError) Unwrap() error {
+	return err.err
+}
+
 func (err *flagParseError) ExitCode() int {
 	return exitCodeFlagParseErr
 }
@@ -66,6 +74,10 @@ func (err *compileError) Error() string {
 	return "compile error: " + err.err.Error()
 }
 
+func (err *compileError) Unwrap() error {
+	return err.err
+}
+
 func (err *compileError) ExitCode() int {
 	return exitCodeCompileErr
 }
@@ -91,6 +103,10 @@ func (err *queryParseError) Error() string {
 		err.contents, linestr, strings.Repeat(" ", column), err.err)
 }
 
+func (err *queryParseError) Unwrap() error {
+	return err.err
+}
+
 func (err *queryParseError) ExitCode() int {
 	return exitCodeCompileErr
 }
@@ -117,6 +133,10 @@ func (err *jsonParseError) Error() string {
 		err.fname, linestr, strings.Repeat(" ", column), err.err)
 }
 
+func (err *jsonParseError) Unwrap() error {
+	return err.err
+}
+
 type yamlParseError struct {
 	fname, contents string
 	err             error
@@ -139,6 +159,10 @@ func (err *yamlParseError) Error() string {
 		err.fname, line, formatLineInfo(linestr, line, 0), msg)
 }
 
+func (err *yamlParseError) Unwrap() error {
+	return err.err
+}
+
 func getLineByOffset(str string, offset int) (linestr string, line, column int) {
 	ss := &stringScanner{str, 0}
 	for {
@@ -257,3 +281,37 @@ func indexNewline(str string) (i int) {
 	}
 	return
 }
+
+type diffJQError struct {
+	output, jqOutput string
+	code, jqCode     int
+}
+
+func (err *diffJQError) Error() string {
+	var sb strings.Builder
+	sb.WriteString("output differs from jq")
+	if err.code != err.jqCode {
+		sb.WriteString(" (exit code: " + strconv.Itoa(err.code) +
+			", jq exit code: " + strconv.Itoa(err.jqCode) + ")")
+	}
+	xs := strings.SplitAfter(err.output, "\n")
+	ys := strings.SplitAfter(err.jqOutput, "\n")
+	for len(xs) > 0 && len(ys) > 0 && xs[0] == ys[0] {
+		xs, ys = xs[1:], ys[1:]
+	}
+	for len(xs) > 0 && len(ys) > 0 && xs[len(xs)-1] == ys[len(ys)-1] {
+		xs, ys = xs[:len(xs)-1], ys[:len(ys)-1]
+	}
+	sb.WriteString("\n--- gojq\n+++ jq")
+	for _, x := range xs {
+		sb.WriteString("\n-" + strings.TrimSuffix(x, "\n"))
+	}
+	for _, y := range ys {
+		sb.WriteString("\n+" + strings.TrimSuffix(y, "\n"))
+	}
+	return sb.String()
+}
+
+func (err *diffJQError) ExitCode() int {
+	return exitCodeDefaultErr
+}
@@ -14,6 +14,7 @@ func parseFlags(args []string, opts any) ([]string, error) {
 	longToValue := map[string]reflect.Value{}
 	longToPositional := map[string]struct{}{}
 	shortToValue := map[string]reflect.Value{}
+	var queryVal reflect.Value
 	for i, l := 0, val.NumField(); i < l; i++ {
 		if flag, ok := typ.Field(i).Tag.Lookup("long"); ok {
 			longToValue[flag] = val.Field(i)
@@ -24,6 +25,14 @@ func parseFlags(args []string, opts any) ([]string, error) {
 		if flag, ok := typ.Field(i).Tag.Lookup("short"); ok {
 			shortToValue[flag] = val.Field(i)
 		}
+		if _, ok := typ.Field(i).Tag.Lookup("query"); ok {
+			queryVal = val.Field(i)
+		}
+	}
+	// The first non-flag argument is the query unless it is given by a flag,
+	// and the following ones are positional values after --args or --jsonargs.
+	hasQuery := func() bool {
+		return len(rest) > 0 || queryVal.IsValid() && !queryVal.IsZero()
 	}
 	mapKeys := map[string]struct{}{}
 	var positionalVal reflect.Value
@@ -35,12 +44,16 @@ func parseFlags(args []string, opts any) ([]string, error) {
 			shortopts string
 		)
 		if arg == "--" {
+			args = args[i+1:]
 			if positionalVal.IsValid() {
-				for _, arg := range args[i+1:] {
+				if !hasQuery() && len(args) > 0 {
+					rest, args = append(rest, args[0]), args[1:]
+				}
+				for _, arg := range args {
 					positionalVal.Set(reflect.Append(positionalVal, reflect.ValueOf(arg)))
 				}
 			} else {
-				rest = append(rest, args[i+1:]...)
+				rest = append(rest, args...)
 			}
 			break
 		}
@@ -79,7 +92,7 @@ func parseFlags(args []string, opts any) ([]string, error) {
 			}
 		}
 		if !ok {
-			if positionalVal.IsValid() && len(rest) > 0 {
+			if positionalVal.IsValid() && hasQuery() {
 				positionalVal.Set(reflect.Append(positionalVal, reflect.ValueOf(arg)))
 			} else {
 				rest = append(rest, arg)
@@ -4,9 +4,13 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/json"
+	"errors"
 	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 
@@ -15,18 +19,37 @@ import (
 
 type inputReader struct {
 	io.Reader
-	file *os.File
-	buf  *bytes.Buffer
+	file  *os.File
+	buf   *bytes.Buffer
+	lines int
 }
 
 func newInputReader(r io.Reader) *inputReader {
 	if r, ok := r.(*os.File); ok {
 		if _, err := r.Seek(0, io.SeekCurrent); err == nil {
-			return &inputReader{r, r, nil}
+			return &inputReader{r, r, nil, 0}
 		}
 	}
 	var buf bytes.Buffer // do not use strings.Builder because we need to Reset
-	return &inputReader{io.TeeReader(r, &buf), nil, &buf}
+	return &inputReader{io.TeeReader(r, &buf), nil, &buf, 0}
+}
+
+func (ir *inputReader) Read(p []byte) (int, error) {
+	n, err := ir.Reader.Read(p)
+	ir.lines += bytes.Count(p[:n], []byte{'\n'})
+	return n, err
+}
+
+// Returns the number of lines read by the decoder, excluding the lines in the
+// buffer of the decoder which are not consumed yet. Like jq, the newline just
+// after the last value is regarded as consumed, so this is the line number of
+// the value in newline-delimited JSON.
+func (ir *inputReader) lineNumber(buffered io.Reader) int {
+	cnt, _ := io.ReadAll(buffered)
+	if len(cnt) > 0 && cnt[0] == '\n' {
+		cnt = cnt[1:]
+	}
+	return ir.lines - bytes.Count(cnt, []byte{'\n'})
 }
 
 func (ir *inputReader) getContents(offset *int64, line *int) string {
@@ -61,12 +84,25 @@ func (ir *inputReader) getContents(offset *int64, line *int) string {
 	return buf.String()
 }
 
+// The name of the standard input used in the error messages. The
+// input_filename function emits null while reading the standard input.
+const stdinName = "<stdin>"
+
 type inputIter interface {
 	gojq.Iter
 	io.Closer
 	Name() string
 }
 
+// Returns the number of lines consumed by the input iterator, like jq, or 0 if
+// the iterator does not track the line numbers.
+func inputLineNumber(iter inputIter) int {
+	if iter, ok := iter.(interface{ LineNumber() int }); ok {
+		return iter.LineNumber()
+	}
+	return 0
+}
+
 type jsonInputIter struct {
 	dec    *json.Decoder
 	ir     *inputReader
@@ -119,6 +155,10 @@ func (i *jsonInputIter) Name() string {
 	return i.fname
 }
 
+func (i *jsonInputIter) LineNumber() int {
+	return i.ir.lineNumber(i.dec.Buffered())
+}
+
 type nullInputIter struct {
 	err error
 }
@@ -144,19 +184,77 @@ func (i *nullInputIter) Name() string {
 	return ""
 }
 
+// Reads the next input ahead for the peek_input function. The file name and
+// the line number are recorded along with each value, so input_filename and
+// input_line_number report those of the last value consumed by Next, rather
+// than those of the value read ahead.
+type peekInputIter struct {
+	iter     inputIter
+	value    any
+	ok       bool
+	peeked   bool
+	name     string
+	line     int
+	peekName string
+	peekLine int
+}
+
+func newPeekInputIter(iter inputIter) inputIter {
+	return &peekInputIter{iter: iter}
+}
+
+func (i *peekInputIter) Next() (any, bool) {
+	if i.peeked {
+		i.peeked = false
+		i.name, i.line = i.peekName, i.peekLine
+		return i.value, i.ok
+	}
+	v, ok := i.iter.Next()
+	i.name, i.line = i.iter.Name(), inputLineNumber(i.iter)
+	return v, ok
+}
+
+func (i *peekInputIter) Peek() (any, bool) {
+	if !i.peeked {
+		i.value, i.ok = i.iter.Next()
+		i.peekName, i.peekLine = i.iter.Name(), inputLineNumber(i.iter)
+		i.peeked = true
+	}
+	return i.value, i.ok
+}
+
+func (i *peekInputIter) Close() error {
+	return i.iter.Close()
+}
+
+func (i *peekInputIter) Name() string {
+	return i.name
+}
+
+func (i *peekInputIter) LineNumber() int {
+	return i.line
+}
+
 type filesInputIter struct {
 	newIter func(io.Reader, string) inputIter
 	fnames  []string
 	stdin   io.Reader
 	iter    inputIter
 	file    io.Reader
+	opts    filesInputOptions
 	err     error
 }
 
+type filesInputOptions struct {
+	follow        bool     // wait for the contents appended to the last file
+	ignoreMissing bool     // treat the missing files as empty
+	exts          []string // extensions of the files to read in directories
+}
+
 func newFilesInputIter(
-	newIter func(io.Reader, string) inputIter, fnames []string, stdin io.Reader,
+	newIter func(io.Reader, string) inputIter, fnames []string, stdin io.Reader, opts filesInputOptions,
 ) inputIter {
-	return &filesInputIter{newIter: newIter, fnames: fnames, stdin: stdin}
+	return &filesInputIter{newIter: newIter, fnames: fnames, stdin: stdin, opts: opts}
 }
 
 func (i *filesInputIter) Next() (any, bool) {
@@ -176,13 +274,28 @@ func (i *filesInputIter) Next() (any, bool) {
 			fname := i.fnames[0]
 			i.fnames = i.fnames[1:]
 			if fname == "-" && i.stdin != nil {
-				i.file, fname = i.stdin, "<stdin>"
+				i.file, fname = i.stdin, stdinName
 			} else {
+				fnames, err := expandInputFile(fname, i.opts.exts)
+				if err != nil {
+					return err, true
+				}
+				if fnames != nil {
+					i.fnames = append(fnames, i.fnames...)
+					continue
+				}
 				file, err := os.Open(fname)
 				if err != nil {
+					if i.opts.ignoreMissing && errors.Is(err, fs.ErrNotExist) {
+						continue
+					}
 					return err, true
 				}
-				i.file = file
+				if i.opts.follow && len(i.fnames) == 0 {
+					i.file = &followReader{file}
+				} else {
+					i.file = file
+				}
 			}
 			if i.iter != nil {
 				i.iter.Close()
@@ -217,9 +330,81 @@ func (i *filesInputIter) Name() string {
 	return ""
 }
 
+func (i *filesInputIter) LineNumber() int {
+	if i.iter != nil {
+		return inputLineNumber(i.iter)
+	}
+	return 0
+}
+
+// Expands the directory to the files in it recursively (filtered by the
+// extensions if any), and the glob pattern to the matched files, in the
+// lexical order. Returns nil for a plain file or a missing file, to let the
+// caller open it. The file named like a glob pattern takes precedence.
+func expandInputFile(fname string, exts []string) ([]string, error) {
+	fi, err := os.Stat(fname)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) || !strings.ContainsAny(fname, "*?[") {
+			return nil, nil
+		}
+		fnames, err := filepath.Glob(fname)
+		if err != nil {
+			return nil, &fs.PathError{Op: "glob", Path: fname, Err: err}
+		}
+		return fnames, nil
+	}
+	if !fi.IsDir() {
+		return nil, nil
+	}
+	fnames := []string{}
+	if err := filepath.WalkDir(fname, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && hasInputExt(path, exts) {
+			fnames = append(fnames, path)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return fnames, nil
+}
+
+func hasInputExt(fname string, exts []string) bool {
+	if len(exts) == 0 {
+		return true
+	}
+	ext := strings.TrimPrefix(filepath.Ext(fname), ".")
+	for _, e := range exts {
+		if strings.EqualFold(ext, e) {
+			return true
+		}
+	}
+	return false
+}
+
+var followInterval = 100 * time.Millisecond
+
+// Reads the file and waits for the contents appended to the file on reaching
+// the end, like tail -f. The file is read until the process is terminated.
+type followReader struct {
+	*os.File
+}
+
+func (r *followReader) Read(p []byte) (int, error) {
+	for {
+		if n, err := r.File.Read(p); n > 0 || err != io.EOF {
+			return n, err
+		}
+		time.Sleep(followInterval)
+	}
+}
+
 type rawInputIter struct {
 	r     *bufio.Reader
 	fname string
+	line  int
 	err   error
 }
 
@@ -240,6 +425,8 @@ func (i *rawInputIter) Next() (any, bool) {
 		if line == "" {
 			return nil, false
 		}
+	} else {
+		i.line++
 	}
 	return strings.TrimSuffix(line, "\n"), true
 }
@@ -253,8 +440,13 @@ func (i *rawInputIter) Name() string {
 	return i.fname
 }
 
+func (i *rawInputIter) LineNumber() int {
+	return i.line
+}
+
 type streamInputIter struct {
 	stream *jsonStream
+	dec    *json.Decoder
 	ir     *inputReader
 	fname  string
 	offset int64
@@ -266,7 +458,7 @@ func newStreamInputIter(r io.Reader, fname string) inputIter {
 	ir := newInputReader(r)
 	dec := json.NewDecoder(ir)
 	dec.UseNumber()
-	return &streamInputIter{stream: newJSONStream(dec), ir: ir, fname: fname}
+	return &streamInputIter{stream: newJSONStream(dec), dec: dec, ir: ir, fname: fname}
 }
 
 func (i *streamInputIter) Next() (any, bool) {
@@ -305,6 +497,10 @@ func (i *streamInputIter) Name() string {
 	return i.fname
 }
 
+func (i *streamInputIter) LineNumber() int {
+	return i.ir.lineNumber(i.dec.Buffered())
+}
+
 type yamlInputIter struct {
 	dec   *yaml.Decoder
 	ir    *inputReader
@@ -385,6 +581,10 @@ func (i *slurpInputIter) Name() string {
 	return i.iter.Name()
 }
 
+func (i *slurpInputIter) LineNumber() int {
+	return inputLineNumber(i.iter)
+}
+
 type readAllIter struct {
 	r     io.Reader
 	fname string
@@ -457,3 +657,7 @@ func (i *slurpRawInputIter) Close() error {
 func (i *slurpRawInputIter) Name() string {
 	return i.iter.Name()
 }
+
+func (i *slurpRawInputIter) LineNumber() int {
+	return inputLineNumber(i.iter)
+}
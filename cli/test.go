@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/rturpen/gojq"
+)
+
+type testopts struct {
+	ModulePaths []string `short:"L" description:"directory to search modules from"`
+	Help        bool     `short:"h" long:"help" description:"display this help information"`
+}
+
+// Runs the test command, which runs the jq test files (or the standard input)
+// just like --run-tests option.
+func (cli *cli) runTest(args []string) error {
+	var opts testopts
+	args, err := parseFlags(args, &opts)
+	if err != nil {
+		return &flagParseError{err}
+	}
+	if opts.Help {
+		fmt.Fprintf(cli.outStream, `Usage:
+  %[1]s test [OPTIONS] [FILES...]
+
+`, name)
+		fmt.Fprintln(cli.outStream, formatFlags(&opts))
+		return nil
+	}
+	return cli.runTests(args, resolveModulePaths(opts.ModulePaths))
+}
+
+func (cli *cli) runTests(args, modulePaths []string) error {
+	var tcs []*gojq.TestCase
+	if len(args) == 0 {
+		xs, err := gojq.ParseTests(cli.inStream)
+		if err != nil {
+			return err
+		}
+		tcs = xs
+	}
+	for _, fname := range args {
+		f, err := os.Open(fname)
+		if err != nil {
+			return err
+		}
+		xs, err := gojq.ParseTests(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", fname, err)
+		}
+		tcs = append(tcs, xs...)
+	}
+	var passed int
+	for _, tc := range tcs {
+		if err := tc.Run(
+			gojq.WithModuleLoader(gojq.NewModuleLoader(modulePaths)),
+			gojq.WithEnvironLoader(os.Environ),
+		); err != nil {
+			fmt.Fprintln(cli.outStream, err)
+		} else {
+			passed++
+		}
+	}
+	fmt.Fprintf(cli.outStream, "%d of %d tests passed\n", passed, len(tcs))
+	if passed < len(tcs) {
+		return &exitCodeError{exitCodeDefaultErr}
+	}
+	return nil
+}
@@ -0,0 +1,260 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/rturpen/gojq"
+	"github.com/rturpen/gojq/internal/lint"
+	"github.com/rturpen/gojq/lsp"
+)
+
+type toolopts struct {
+	JSON        bool              `long:"json" description:"output diagnostics in JSON lines"`
+	ModulePaths []string          `short:"L" description:"directory to search modules from"`
+	Arg         map[string]string `long:"arg" description:"declare a variable given by --arg"`
+	ArgJSON     map[string]string `long:"argjson" description:"declare a variable given by --argjson"`
+	SlurpFile   map[string]string `long:"slurpfile" description:"declare a variable given by --slurpfile"`
+	RawFile     map[string]string `long:"rawfile" description:"declare a variable given by --rawfile"`
+	Help        bool              `short:"h" long:"help" description:"display this help information"`
+}
+
+// A diagnostic reported by the tool commands. The position is omitted when it
+// is unknown, like for compile errors.
+type toolDiagnostic struct {
+	File     string `json:"file"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+	Severity string `json:"severity"`
+	Code     string `json:"code"`
+	Message  string `json:"message"`
+}
+
+func (d *toolDiagnostic) String() string {
+	pos := d.File
+	if d.Line > 0 {
+		pos += ":" + strconv.Itoa(d.Line) + ":" + strconv.Itoa(d.Column)
+	}
+	return pos + ": " + d.Severity + ": " + d.Message + " (" + d.Code + ")"
+}
+
+// Runs the tool command; fmt prints the formatted queries, check reports the
+// parse and compile errors, and lint reports the unused definitions in addition
+// to the errors. The queries are read from the files, or the standard input.
+// The lsp command runs the language server over the standard input and output.
+func (cli *cli) runTool(args []string) error {
+	var opts toolopts
+	args, err := parseFlags(args, &opts)
+	if err != nil {
+		return &flagParseError{err}
+	}
+	if opts.Help {
+		fmt.Fprintf(cli.outStream, `Usage:
+  %[1]s tool fmt [OPTIONS] [FILES...]
+  %[1]s tool check [OPTIONS] [FILES...]
+  %[1]s tool lint [OPTIONS] [FILES...]
+  %[1]s tool lsp [OPTIONS]
+
+The fmt command prints the formatted queries. The comments are not preserved,
+so the queries with comments are reported as errors instead of formatted.
+
+`, name)
+		fmt.Fprintln(cli.outStream, formatFlags(&opts))
+		return nil
+	}
+	if len(args) == 0 {
+		return &flagParseError{errors.New("expected a tool command: fmt, check, lint, or lsp")}
+	}
+	command, args := args[0], args[1:]
+	switch command {
+	case "fmt", "check", "lint", "lsp":
+	default:
+		return &flagParseError{fmt.Errorf("unknown tool command: %s", command)}
+	}
+	modulePaths := resolveModulePaths(opts.ModulePaths)
+	if command == "lsp" {
+		if len(args) > 0 {
+			return &flagParseError{fmt.Errorf("unexpected argument for tool lsp: %s", args[0])}
+		}
+		return lsp.NewServer(modulePaths).Serve(cli.inStream, cli.outStream)
+	}
+	variables := []string{"$ARGS"}
+	for _, m := range []map[string]string{opts.Arg, opts.ArgJSON, opts.SlurpFile, opts.RawFile} {
+		for k := range m {
+			variables = append(variables, "$"+k)
+		}
+	}
+	type source struct{ fname, src string }
+	var srcs []source
+	if len(args) == 0 {
+		src, err := io.ReadAll(cli.inStream)
+		if err != nil {
+			return err
+		}
+		srcs = append(srcs, source{stdinName, string(src)})
+	}
+	for _, fname := range args {
+		src, err := os.ReadFile(fname)
+		if err != nil {
+			return err
+		}
+		srcs = append(srcs, source{fname, string(src)})
+	}
+	enc := json.NewEncoder(cli.outStream)
+	enc.SetEscapeHTML(false)
+	var errored, warned bool
+	for _, s := range srcs {
+		q, ds := toolParse(s.fname, s.src)
+		if q != nil && command == "fmt" {
+			// The formatter prints the parsed query, which drops the comments.
+			if offset := indexComment(s.src); offset >= 0 {
+				q, ds = nil, []*toolDiagnostic{toolNewDiagnostic(s.fname, s.src, offset,
+					"error", "fmt-comment", "cannot format the query with comments")}
+			}
+		} else if q != nil {
+			ds = toolCompile(s.fname, s.src, q, modulePaths, variables)
+			if command == "lint" {
+				ds = append(ds, toolLint(s.fname, s.src, q)...)
+			}
+		}
+		for _, d := range ds {
+			if d.Severity == "error" {
+				errored = true
+			} else {
+				warned = true
+			}
+			if opts.JSON {
+				if err := enc.Encode(d); err != nil {
+					return err
+				}
+			} else {
+				fmt.Fprintln(cli.outStream, d)
+			}
+		}
+		if q != nil && command == "fmt" {
+			fmt.Fprintln(cli.outStream, q)
+		}
+	}
+	if errored {
+		return &exitCodeError{exitCodeCompileErr}
+	} else if warned {
+		return &exitCodeError{exitCodeDefaultErr}
+	}
+	return nil
+}
+
+func toolParse(fname, src string) (*gojq.Query, []*toolDiagnostic) {
+	q, err := gojq.Parse(src)
+	if err != nil {
+		return nil, []*toolDiagnostic{toolParseDiagnostic(fname, src, err)}
+	}
+	return q, nil
+}
+
+// Returns the offset of the first comment in the valid query, or -1.
+func indexComment(src string) int {
+	var inString bool
+	var depth int
+	var depths []int // the depths of the string interpolations
+	for i := 0; i < len(src); i++ {
+		if inString {
+			switch src[i] {
+			case '\\':
+				if i++; i < len(src) && src[i] == '(' {
+					depths = append(depths, depth)
+					depth++
+					inString = false
+				}
+			case '"':
+				inString = false
+			}
+			continue
+		}
+		switch src[i] {
+		case '#':
+			return i
+		case '"':
+			inString = true
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if n := len(depths); n > 0 && depths[n-1] == depth {
+				depths = depths[:n-1]
+				inString = true
+			}
+		}
+	}
+	return -1
+}
+
+func toolParseDiagnostic(fname, src string, err error) *toolDiagnostic {
+	var offset int
+	if e, ok := err.(interface{ Token() (string, int) }); ok {
+		token, end := e.Token()
+		if offset = end - len(token); offset < 0 {
+			offset = 0
+		} else if offset > len(src) {
+			offset = len(src)
+		}
+	}
+	return toolNewDiagnostic(fname, src, offset, "error", "parse-error", err.Error())
+}
+
+func toolCompile(fname, src string, q *gojq.Query, modulePaths, variables []string) []*toolDiagnostic {
+	_, err := gojq.Compile(q,
+		gojq.WithModuleLoader(gojq.NewModuleLoader(modulePaths)),
+		gojq.WithEnvironLoader(os.Environ),
+		gojq.WithFunction("input_filename", 0, 0, func(any, []any) any { return nil }),
+		gojq.WithFunction("input_line_number", 0, 0, func(any, []any) any { return 0 }),
+		gojq.WithInputIter(gojq.NewIter()),
+		gojq.WithVariables(variables),
+	)
+	if err == nil {
+		return nil
+	}
+	if e, ok := err.(interface {
+		QueryParseError() (string, string, error)
+	}); ok {
+		fname, src, err := e.QueryParseError()
+		return []*toolDiagnostic{toolParseDiagnostic(fname, src, err)}
+	}
+	if e, ok := err.(interface{ Token() (string, int) }); ok {
+		if token, end := e.Token(); end >= len(token) && end <= len(src) {
+			return []*toolDiagnostic{toolNewDiagnostic(fname, src, end-len(token),
+				"error", "compile-error", err.Error())}
+		}
+	}
+	return []*toolDiagnostic{{
+		File: fname, Severity: "error", Code: "compile-error", Message: err.Error(),
+	}}
+}
+
+func toolLint(fname, src string, q *gojq.Query) []*toolDiagnostic {
+	var ds []*toolDiagnostic
+	for _, p := range lint.Lint(src, q) {
+		ds = append(ds, toolNewDiagnostic(fname, src, p.Start, "warning", p.Code, p.Message))
+	}
+	return ds
+}
+
+func toolNewDiagnostic(fname, src string, offset int, severity, code, message string) *toolDiagnostic {
+	d := &toolDiagnostic{
+		File: fname, Severity: severity, Code: code, Message: message,
+	}
+	if offset >= 0 {
+		d.Line, d.Column = 1, 1
+		for _, r := range src[:offset] {
+			if r == '\n' {
+				d.Line, d.Column = d.Line+1, 1
+			} else {
+				d.Column++
+			}
+		}
+	}
+	return d
+}
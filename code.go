@@ -1,5 +1,7 @@
 package gojq
 
+import "strconv"
+
 type code struct {
 	v  any
 	op opcode
@@ -103,6 +105,6 @@ func (op opcode) String() string {
 	case oppathend:
 		return "pathend"
 	default:
-		panic(op)
+		return "op(" + strconv.Itoa(int(op)) + ")"
 	}
 }
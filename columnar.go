@@ -0,0 +1,250 @@
+package gojq
+
+import "context"
+
+// ColumnarCode is a compiled query for the batches of records in the columnar
+// layout, which maps the field names to the column values of the records.
+// This is an experimental API for the analytics workloads. Simple projection
+// and selection queries, like .x, select(.x > 1 and .y == "a") and
+// select(.x > 1) | .y, are evaluated column-wise without constructing the
+// records. Other queries are evaluated against each record constructed from
+// the columns, so the results are the same in either case.
+type ColumnarCode struct {
+	code   *Code
+	conds  []*columnarCond
+	field  string
+	vector bool
+}
+
+type columnarCond struct {
+	field string
+	op    Operator
+	value any
+}
+
+// CompileColumnar compiles the query for the columnar batches.
+func CompileColumnar(q *Query, options ...CompilerOption) (*ColumnarCode, error) {
+	code, err := Compile(q, options...)
+	if err != nil {
+		return nil, err
+	}
+	c := &ColumnarCode{code: code}
+	var o compiler
+	for _, opt := range options {
+		opt(&o)
+	}
+	// The builtin functions may be overridden by the modules.
+	if o.moduleLoader == nil && len(o.preludes) == 0 &&
+		len(q.Imports) == 0 && len(q.FuncDefs) == 0 {
+		c.vector = c.plan(q)
+	}
+	return c, nil
+}
+
+// Vectorized reports whether the query is evaluated column-wise.
+func (c *ColumnarCode) Vectorized() bool {
+	return c.vector
+}
+
+// Run evaluates the query against the records of the batch, and returns the
+// results in the order of the records, just like running the query against
+// each record (an object of the fields and the values at the same index in the
+// columns). All the columns should have the same length. The query should not
+// require the variable values. When the query emits an error, this method stops
+// and returns the preceding results with the error.
+func (c *ColumnarCode) Run(batch map[string][]any) ([]any, error) {
+	if len(c.code.variables) > 0 {
+		return nil, &expectedVariableError{c.code.variables[0]}
+	}
+	n, first := 0, true
+	for _, xs := range batch {
+		if first {
+			n, first = len(xs), false
+		} else if n != len(xs) {
+			return nil, &lengthMismatchError{}
+		}
+		if v := normalizeValue(xs, c.code.stringifyKeys); isNormalizeError(v) {
+			return nil, v.(error)
+		}
+	}
+	if !c.vector {
+		return c.runRecords(batch, n)
+	}
+	mask := make([]bool, n)
+	for i := range mask {
+		mask[i] = true
+	}
+	for _, cond := range c.conds {
+		xs := batch[cond.field]
+		for i := range mask {
+			if mask[i] {
+				var x any
+				if xs != nil {
+					x = xs[i]
+				}
+				mask[i] = cond.eval(x)
+			}
+		}
+	}
+	results := make([]any, 0, n)
+	if c.field != "" {
+		xs := batch[c.field]
+		for i, ok := range mask {
+			if ok {
+				if xs != nil {
+					results = append(results, xs[i])
+				} else {
+					results = append(results, nil)
+				}
+			}
+		}
+		return results, nil
+	}
+	for i, ok := range mask {
+		if ok {
+			results = append(results, columnarRecord(batch, i))
+		}
+	}
+	return results, nil
+}
+
+func (c *ColumnarCode) runRecords(batch map[string][]any, n int) ([]any, error) {
+	env := newEnv(context.Background())
+	var results []any
+	for i := 0; i < n; i++ {
+		env.reset()
+		iter := env.execute(c.code, columnarRecord(batch, i))
+		for {
+			v, ok := iter.Next()
+			if !ok {
+				break
+			}
+			if err, ok := v.(error); ok {
+				return results, err
+			}
+			results = append(results, v)
+		}
+	}
+	return results, nil
+}
+
+func columnarRecord(batch map[string][]any, i int) map[string]any {
+	v := make(map[string]any, len(batch))
+	for k, xs := range batch {
+		v[k] = xs[i]
+	}
+	return v
+}
+
+// Plans the column-wise evaluation of the query; select(cond) | .field where
+// both sides are optional, and cond is the conjunction of the comparisons of
+// fields and constants.
+func (c *ColumnarCode) plan(q *Query) bool {
+	if q.Op == OpPipe {
+		if !c.planSelect(q.Left) {
+			return false
+		}
+		q = q.Right
+		if c.field = columnarField(q); c.field == "" {
+			return false
+		}
+		return true
+	}
+	if c.field = columnarField(q); c.field != "" {
+		return true
+	}
+	return c.planSelect(q)
+}
+
+func (c *ColumnarCode) planSelect(q *Query) bool {
+	if q.Term == nil || q.Term.Type != TermTypeFunc || len(q.Term.SuffixList) > 0 {
+		return false
+	}
+	if f := q.Term.Func; f.Name != "select" || len(f.Args) != 1 {
+		return false
+	}
+	return c.planCond(q.Term.Func.Args[0])
+}
+
+func (c *ColumnarCode) planCond(q *Query) bool {
+	switch q.Op {
+	case OpAnd:
+		return c.planCond(q.Left) && c.planCond(q.Right)
+	case OpEq, OpNe, OpLt, OpLe, OpGt, OpGe:
+		op := q.Op
+		field, value, ok := columnarField(q.Left), any(nil), false
+		if field != "" {
+			value, ok = columnarConst(q.Right)
+		} else if field = columnarField(q.Right); field != "" {
+			value, ok = columnarConst(q.Left)
+			op = map[Operator]Operator{
+				OpEq: OpEq, OpNe: OpNe, OpLt: OpGt, OpLe: OpGe, OpGt: OpLt, OpGe: OpLe,
+			}[op]
+		}
+		if ok {
+			c.conds = append(c.conds, &columnarCond{field, op, value})
+		}
+		return ok
+	case 0:
+		if q.Term != nil && q.Term.Type == TermTypeQuery && len(q.Term.SuffixList) == 0 {
+			return c.planCond(q.Term.Query)
+		}
+	}
+	return false
+}
+
+// Returns the field name of the query like .x or ."x".
+func columnarField(q *Query) string {
+	if q.Op != 0 || q.Term == nil || q.Term.Type != TermTypeIndex ||
+		len(q.Term.SuffixList) > 0 {
+		return ""
+	}
+	if e := q.Term.Index; e.Name != "" {
+		return e.Name
+	} else if e.Str != nil && len(e.Str.Queries) == 0 && e.Str.Str != "" {
+		return e.Str.Str
+	}
+	return ""
+}
+
+func columnarConst(q *Query) (any, bool) {
+	if q.Op != 0 || q.Term == nil || len(q.Term.SuffixList) > 0 {
+		return nil, false
+	}
+	switch e := q.Term; e.Type {
+	case TermTypeNull:
+		return nil, true
+	case TermTypeTrue:
+		return true, true
+	case TermTypeFalse:
+		return false, true
+	case TermTypeNumber:
+		v := e.toNumber()
+		return v, v != nil
+	case TermTypeUnary:
+		v := e.Unary.toNumber()
+		return v, v != nil
+	case TermTypeString:
+		if len(e.Str.Queries) == 0 {
+			return e.Str.Str, true
+		}
+	}
+	return nil, false
+}
+
+func (c *columnarCond) eval(x any) bool {
+	switch n := compare(x, c.value); c.op {
+	case OpEq:
+		return n == 0
+	case OpNe:
+		return n != 0
+	case OpLt:
+		return n < 0
+	case OpLe:
+		return n <= 0
+	case OpGt:
+		return n > 0
+	default:
+		return n >= 0
+	}
+}
@@ -0,0 +1,126 @@
+package gojq_test
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"testing"
+
+	"github.com/rturpen/gojq"
+)
+
+func ExampleColumnarCode_Run() {
+	query, err := gojq.Parse(`select(.size > 100 and .user != "guest") | .id`)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	code, err := gojq.CompileColumnar(query)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	results, err := code.Run(map[string][]any{
+		"id":   {1, 2, 3, 4},
+		"user": {"admin", "guest", "guest", "user"},
+		"size": {500, 1000, 10, 200},
+	})
+	if err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Printf("%v (vectorized: %v)\n", results, code.Vectorized())
+
+	// Output:
+	// [1 4] (vectorized: true)
+}
+
+func TestColumnarCodeRun(t *testing.T) {
+	batch := func() map[string][]any {
+		return map[string][]any{
+			"x": {1, 2.5, nil, "a", 3, []any{1}},
+			"y": {"a", "b", "a", nil, "a", true},
+			"z": {0, 1, 2, 3, 4, 5},
+		}
+	}
+	testCases := []struct {
+		src        string
+		vectorized bool
+	}{
+		{".x", true},
+		{`."y"`, true},
+		{".w", true},
+		{"select(.x > 1)", true},
+		{"select(2 >= .x)", true},
+		{`select(.x < "b" and .y == "a") | .z`, true},
+		{`select((.x != null) and (-1 < .z)) | .x`, true},
+		{"select(.x == [1])", false},
+		{"select(.x > 1) | .y | length", false},
+		{"select(.x > 1 or .y == true)", false},
+		{".x, .y", false},
+		{"def f: .x; f", false},
+		{"select(.x > 1) | .x + 1", false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.src, func(t *testing.T) {
+			query, err := gojq.Parse(tc.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			code, err := gojq.CompileColumnar(query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := code.Vectorized(); got != tc.vectorized {
+				t.Errorf("expected vectorized: %v, got: %v", tc.vectorized, got)
+			}
+			got, err := code.Run(batch())
+			var expected []any
+			var expectedErr error
+			b := batch()
+			for i := range b["x"] {
+				iter := query.Run(map[string]any{"x": b["x"][i], "y": b["y"][i], "z": b["z"][i]})
+				for {
+					v, ok := iter.Next()
+					if !ok {
+						break
+					}
+					if err, ok := v.(error); ok {
+						expectedErr = err
+						break
+					}
+					expected = append(expected, v)
+				}
+				if expectedErr != nil {
+					break
+				}
+			}
+			if fmt.Sprint(err) != fmt.Sprint(expectedErr) {
+				t.Errorf("expected error: %v, got: %v", expectedErr, err)
+			}
+			if !reflect.DeepEqual(got, expected) {
+				t.Errorf("expected: %#v, got: %#v", expected, got)
+			}
+		})
+	}
+}
+
+func TestColumnarCodeRunError(t *testing.T) {
+	query, err := gojq.Parse(".x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := gojq.CompileColumnar(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := code.Run(map[string][]any{"x": {1}, "y": {}}); fmt.Sprint(err) != "length mismatch" {
+		t.Errorf("expected length mismatch error but got: %v", err)
+	}
+	if got, err := code.Run(nil); err != nil || len(got) != 0 {
+		t.Errorf("expected no results but got: %v, %v", got, err)
+	}
+	if code, err = gojq.CompileColumnar(query, gojq.WithVariables([]string{"$x"})); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := code.Run(nil); fmt.Sprint(err) != "variable defined but not bound: $x" {
+		t.Errorf("expected variable error but got: %v", err)
+	}
+}
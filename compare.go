@@ -1,13 +1,15 @@
 package gojq
 
 import (
+	"encoding/json"
 	"math"
 	"math/big"
 )
 
 // Compare l and r, and returns jq-flavored comparison value.
 // The result will be 0 if l == r, -1 if l < r, and +1 if l > r.
-// This comparison is used by built-in operators and functions.
+// This comparison is used by built-in operators and functions. The numbers of
+// any integer types, float32 and json.Number are compared as numbers.
 func Compare(l, r any) int {
 	return compare(l, r)
 }
@@ -63,6 +65,11 @@ func compare(l, r any) int {
 			return 0
 		},
 		func(l, r any) any {
+			x, ok1 := normalizeNumberValue(l)
+			y, ok2 := normalizeNumberValue(r)
+			if ok1 || ok2 {
+				return compare(x, y)
+			}
 			return compareInt(typeIndex(l), typeIndex(r))
 		},
 	).(int)
@@ -79,6 +86,21 @@ func compareInt(l, r int) any {
 	}
 }
 
+// Normalizes the numbers of types other than int, float64 and *big.Int, and the
+// values implementing JQMarshaler, which can be given to Compare without
+// normalizing the values.
+func normalizeNumberValue(v any) (any, bool) {
+	switch w := v.(type) {
+	case json.Number, int64, int32, int16, int8,
+		uint, uint64, uint32, uint16, uint8, float32:
+		return normalizeNumbers(v), true
+	case JQMarshaler:
+		return marshalJQ(w), true
+	default:
+		return v, false
+	}
+}
+
 func typeIndex(v any) int {
 	switch v := v.(type) {
 	default:
@@ -1,6 +1,7 @@
 package gojq_test
 
 import (
+	"encoding/json"
 	"fmt"
 	"math"
 	"math/big"
@@ -30,7 +31,22 @@ func TestCompare(t *testing.T) {
 		{0, math.NaN(), 1},
 		{math.NaN(), 0, -1},
 		{math.NaN(), math.NaN(), -1},
+		{math.NaN(), math.Inf(-1), -1},
+		{math.Inf(-1), math.NaN(), 1},
+		{math.Inf(1), math.MaxFloat64, 1},
+		{math.Inf(-1), math.Inf(-1), 0},
+		{math.NaN(), big.NewInt(0), -1},
+		{big.NewInt(0), math.Inf(1), -1},
 		{1, 1.00, 0},
+		{json.Number("2"), json.Number("10"), -1},
+		{json.Number("1.5"), 1.5, 0},
+		{int64(1), uint8(2), -1},
+		{float32(0.5), int32(0), 1},
+		{uint64(math.MaxUint64), json.Number("18446744073709551615"), 0},
+		{json.Number("1"), "1", -1},
+		{nil, json.Number("0"), -1},
+		{[]any{json.Number("2")}, []any{json.Number("10")}, -1},
+		{map[string]any{"a": int64(1)}, map[string]any{"a": 1.0}, 0},
 		{1.00, 1, 0},
 		{1.00, 1.01, -1},
 		{1.01, 1.00, 1},
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"sort"
 	"strconv"
 	"strings"
@@ -14,9 +15,21 @@ type compiler struct {
 	environLoader func() []string
 	variables     []string
 	customFuncs   map[string]function
+	filterFuncs   map[string]bool
 	inputIter     Iter
+	debugHandler  func(any) error
+	stderr        io.Writer
+	preludes      []*Query
+	skipInit      bool
+	optionErr     error
+	structErrors  bool
+	stringifyKeys bool
+	deterministic bool
+	now           float64
 	codes         []*code
 	codeinfos     []codeinfo
+	sources       []*Query
+	query         *Query
 	builtinScope  *scopeinfo
 	scopes        []*scopeinfo
 	scopecnt      int
@@ -24,9 +37,12 @@ type compiler struct {
 
 // Code is a compiled jq query.
 type Code struct {
-	variables []string
-	codes     []*code
-	codeinfos []codeinfo
+	variables     []string
+	codes         []*code
+	codeinfos     []codeinfo
+	sources       []*Query
+	structErrors  bool
+	stringifyKeys bool
 }
 
 // Run runs the code with the variable values (which should be in the
@@ -35,21 +51,248 @@ type Code struct {
 //
 // It is safe to call this method in goroutines, to reuse a compiled [*Code].
 // But for arguments, do not give values sharing same data between goroutines.
+// The numbers in the slices and maps of the arguments are normalized in place
+// (for example, json.Number is replaced with int or float64), and they are
+// left partially normalized when the method emits an error on normalizing.
 func (c *Code) Run(v any, values ...any) Iter {
 	return c.RunWithContext(context.Background(), v, values...)
 }
 
 // RunWithContext runs the code with context.
 func (c *Code) RunWithContext(ctx context.Context, v any, values ...any) Iter {
+	values, err := c.normalizeValues(values)
+	if err != nil {
+		return NewIter(err)
+	}
+	if v = normalizeValue(v, c.stringifyKeys); isNormalizeError(v) {
+		return NewIter(v)
+	}
+	return newEnv(ctx).execute(c, v, values...)
+}
+
+// Normalizes the variable values into a new slice, not to modify the slice
+// given by the caller.
+func (c *Code) normalizeValues(values []any) ([]any, error) {
 	if len(values) > len(c.variables) {
-		return NewIter(&tooManyVariableValuesError{})
+		return nil, &tooManyVariableValuesError{}
 	} else if len(values) < len(c.variables) {
-		return NewIter(&expectedVariableError{c.variables[len(values)]})
+		return nil, &expectedVariableError{c.variables[len(values)]}
+	}
+	xs := make([]any, len(values))
+	for i, v := range values {
+		if xs[i] = normalizeValue(v, c.stringifyKeys); isNormalizeError(xs[i]) {
+			return nil, xs[i].(error)
+		}
+	}
+	return xs, nil
+}
+
+// RunWithOptions runs the code with context and the run options. Use this
+// method with [WithRunVariables] to give the variable values by their names.
+func (c *Code) RunWithOptions(ctx context.Context, v any, options ...RunOption) Iter {
+	var opts runOptions
+	for _, opt := range options {
+		opt(&opts)
+	}
+	values, err := c.runVariables(&opts)
+	if err != nil {
+		return NewIter(err)
+	}
+	return c.RunWithContext(ctx, v, values...)
+}
+
+func (c *Code) runVariables(opts *runOptions) ([]any, error) {
+	values := make([]any, len(c.variables))
+	for i, name := range c.variables {
+		v, ok := opts.variables[name]
+		if !ok {
+			return nil, &expectedVariableError{name}
+		}
+		values[i] = v
+	}
+	if len(opts.variables) > len(c.variables) {
+	loop:
+		for name := range opts.variables {
+			for _, n := range c.variables {
+				if n == name {
+					continue loop
+				}
+			}
+			return nil, &variableNotFoundError{name, "", 0}
+		}
+	}
+	return values, nil
+}
+
+// RunInputs runs the code against each value of the inputs iterator, and
+// returns an iterator of the results of all the inputs in order. The variable
+// values are given by [WithRunVariables]. This method reuses the execution
+// environment between the inputs like [Code.RunBatch], but the inputs are read
+// on demand, so the inputs can be an unbounded stream.
+//
+// By default, the iterator stops on the first error, which is emitted as is.
+// With [WithIsolatedErrors], an error emitted by an input (or the inputs
+// iterator) is emitted as an [InputError], and the iterator continues with the
+// next input. Note that the results of the input emitted before the error are
+// not retracted.
+func (c *Code) RunInputs(ctx context.Context, inputs Iter, options ...RunOption) Iter {
+	var opts runOptions
+	for _, opt := range options {
+		opt(&opts)
+	}
+	values, err := c.runVariables(&opts)
+	if err != nil {
+		return NewIter(err)
 	}
 	for i, v := range values {
-		values[i] = normalizeNumbers(v)
+		if values[i] = normalizeValue(v, c.stringifyKeys); isNormalizeError(values[i]) {
+			return NewIter(values[i])
+		}
+	}
+	return &inputsIter{
+		code: c, env: newEnv(ctx), inputs: inputs, values: values,
+		isolate: opts.isolateErrors, index: -1,
+	}
+}
+
+type inputsIter struct {
+	code    *Code
+	env     *env
+	inputs  Iter
+	values  []any
+	iter    Iter
+	input   any
+	index   int
+	isolate bool
+	done    bool
+}
+
+func (iter *inputsIter) Next() (any, bool) {
+	for !iter.done {
+		if iter.iter == nil {
+			v, ok := iter.inputs.Next()
+			if !ok {
+				iter.done = true
+				break
+			}
+			iter.index, iter.input = iter.index+1, v
+			if v = normalizeValue(v, iter.code.stringifyKeys); isNormalizeError(v) {
+				return iter.error(v.(error)), true
+			}
+			if err, ok := v.(error); ok {
+				return iter.error(err), true
+			}
+			iter.env.reset()
+			iter.iter = iter.env.execute(iter.code, v, iter.values...)
+		}
+		v, ok := iter.iter.Next()
+		if !ok {
+			iter.iter = nil
+			continue
+		}
+		if err, ok := v.(error); ok {
+			iter.iter = nil
+			return iter.error(err), true
+		}
+		return v, true
+	}
+	return nil, false
+}
+
+func (iter *inputsIter) error(err error) error {
+	if !iter.isolate || iter.env.ctx.Err() != nil {
+		iter.done = true
+		return err
+	}
+	if _, ok := err.(*haltError); ok {
+		iter.done = true
+		return err
+	}
+	return &inputError{iter.index, iter.input, err}
+}
+
+// Source returns the innermost query which the instruction at the program
+// counter is compiled from, or nil if the instruction is not compiled from any
+// query (like the variable bindings). Use this method with the program counter
+// reported by [InternalError] to locate the query causing the error. Note that
+// the query may be in the definitions of the builtin functions or the modules,
+// and the parsed queries do not expose the offsets in the source text, so use
+// [Query.String] to show the query instead of the source span.
+func (c *Code) Source(pc int) *Query {
+	if pc < 0 || pc >= len(c.sources) {
+		return nil
+	}
+	return c.sources[pc]
+}
+
+// RunBatch runs the code against each of the inputs with the variable values,
+// and returns the results for each input. This method reuses the execution
+// environment between the inputs, so it is cheaper than calling [Code.Run] for
+// each input when the query is simple and the inputs are many.
+//
+// When the query emits an error, this method stops and returns the results
+// of the preceding inputs along with the error; so the index of the erroneous
+// input is the length of the returned results.
+func (c *Code) RunBatch(inputs []any, values ...any) ([][]any, error) {
+	values, err := c.normalizeValues(values)
+	if err != nil {
+		return nil, err
+	}
+	env := newEnv(context.Background())
+	results := make([][]any, 0, len(inputs))
+	for _, v := range inputs {
+		if v = normalizeValue(v, c.stringifyKeys); isNormalizeError(v) {
+			return results, v.(error)
+		}
+		env.reset()
+		iter, xs := env.execute(c, v, values...), []any{}
+		for {
+			v, ok := iter.Next()
+			if !ok {
+				break
+			}
+			if err, ok := v.(error); ok {
+				return results, err
+			}
+			xs = append(xs, v)
+		}
+		results = append(results, xs)
 	}
-	return newEnv(ctx).execute(c, normalizeNumbers(v), values...)
+	return results, nil
+}
+
+// RunMany runs the codes against the same input, and returns the result
+// iterators in the same order as the codes. The input is normalized (and
+// decoded when it includes [encoding/json.RawMessage]) once and shared between
+// the codes, so it is cheaper than calling [Code.Run] of each code for the rule
+// engines evaluating many queries against every event. Each iterator emits the
+// results and errors of the code independently of the others. The codes should
+// not require the variable values, otherwise the iterator emits an error.
+// When some of the codes are compiled with [WithStringifiedMapKeys], the input
+// is normalized for them separately.
+func RunMany(codes []*Code, v any) []Iter {
+	iters := make([]Iter, len(codes))
+	var vs [2]any // normalized without and with stringifying the map keys
+	var normalized [2]bool
+	for i, c := range codes {
+		if len(c.variables) > 0 {
+			iters[i] = NewIter(&expectedVariableError{c.variables[0]})
+			continue
+		}
+		var k int
+		if c.stringifyKeys {
+			k = 1
+		}
+		if !normalized[k] {
+			vs[k], normalized[k] = normalizeValue(v, c.stringifyKeys), true
+		}
+		if w := vs[k]; isNormalizeError(w) {
+			iters[i] = NewIter(w)
+		} else {
+			iters[i] = newEnv(context.Background()).execute(c, w)
+		}
+	}
+	return iters
 }
 
 type scopeinfo struct {
@@ -78,13 +321,16 @@ func Compile(q *Query, options ...CompilerOption) (*Code, error) {
 	for _, opt := range options {
 		opt(c)
 	}
+	if c.optionErr != nil {
+		return nil, c.optionErr
+	}
 	c.builtinScope = c.newScope()
 	scope := c.newScope()
 	c.scopes = []*scopeinfo{scope}
 	setscope := c.lazy(func() *code {
 		return &code{op: opscope, v: [3]int{scope.id, scope.variablecnt, 0}}
 	})
-	if c.moduleLoader != nil {
+	if c.moduleLoader != nil && !c.skipInit {
 		if moduleLoader, ok := c.moduleLoader.(interface {
 			LoadInitModules() ([]*Query, error)
 		}); ok {
@@ -99,16 +345,27 @@ func Compile(q *Query, options ...CompilerOption) (*Code, error) {
 			}
 		}
 	}
+	for _, q := range c.preludes {
+		if err := c.compileModule(q, ""); err != nil {
+			return nil, err
+		}
+	}
 	if err := c.compile(q); err != nil {
 		return nil, err
 	}
 	setscope()
 	c.optimizeTailRec()
 	c.optimizeCodeOps()
+	if err := c.verifyCodes(); err != nil {
+		return nil, err
+	}
 	return &Code{
-		variables: c.variables,
-		codes:     c.codes,
-		codeinfos: c.codeinfos,
+		variables:     c.variables,
+		codes:         c.codes,
+		codeinfos:     c.codeinfos,
+		sources:       c.sources[:len(c.codes)],
+		structErrors:  c.structErrors,
+		stringifyKeys: c.stringifyKeys,
 	}, nil
 }
 
@@ -160,7 +417,9 @@ func (c *compiler) compileImport(i *Import) error {
 		} else {
 			return fmt.Errorf("module not found: %q", path)
 		}
-		vals = normalizeNumbers(vals)
+		if vals = normalizeValue(vals, c.stringifyKeys); isNormalizeError(vals) {
+			return vals.(error)
+		}
 		c.append(&code{op: oppush, v: vals})
 		c.append(&code{op: opstore, v: c.pushVariable(alias)})
 		c.append(&code{op: oppush, v: vals})
@@ -180,11 +439,26 @@ func (c *compiler) compileImport(i *Import) error {
 		if q, err = moduleLoader.LoadModule(path); err != nil {
 			return err
 		}
+	} else {
+		return fmt.Errorf("module not found: %q", path)
 	}
 	c.appendCodeInfo("module " + path)
+	scope := c.scopes[len(c.scopes)-1]
+	l := len(scope.funcs)
 	if err = c.compileModule(q, alias); err != nil {
 		return err
 	}
+	// The functions with names starting with an underscore are private to the
+	// module. Mangle the names not to be called from the importing query.
+	for _, f := range scope.funcs[l:] {
+		name := f.name
+		if i := strings.LastIndex(name, "::"); i >= 0 {
+			name = name[i+2:]
+		}
+		if strings.HasPrefix(name, "_") {
+			f.name = "<" + path + ">" + f.name
+		}
+	}
 	c.appendCodeInfo("end of module " + path)
 	return nil
 }
@@ -210,7 +484,7 @@ func (c *compiler) compileModule(q *Query, alias string) error {
 	}
 	for _, fd := range q.FuncDefs {
 		if err := c.compileFuncDef(fd, false); err != nil {
-			return err
+			return clearErrorOffset(err)
 		}
 	}
 	return nil
@@ -247,7 +521,7 @@ func (c *compiler) lookupVariable(name string) ([2]int, error) {
 			}
 		}
 	}
-	return [2]int{}, &variableNotFoundError{name}
+	return [2]int{}, &variableNotFoundError{name, "", 0}
 }
 
 func (c *compiler) lookupFuncOrVariable(name string) (*funcinfo, *varinfo) {
@@ -367,6 +641,9 @@ func (c *compiler) compileFuncDef(e *FuncDef, builtin bool) error {
 		c.append(&code{op: opload, v: v})
 	}
 	if err := c.compile(e.Body); err != nil {
+		if err, ok := err.(*variableNotFoundError); ok && err.f == "" && !strings.HasPrefix(e.Name, "lambda:") {
+			err.f = e.Name + "/" + strconv.Itoa(len(e.Args))
+		}
 		return err
 	}
 	c.appendCodeInfo("end of " + e.Name)
@@ -374,6 +651,8 @@ func (c *compiler) compileFuncDef(e *FuncDef, builtin bool) error {
 }
 
 func (c *compiler) compileQuery(e *Query) error {
+	defer func(q *Query) { c.query = q }(c.query)
+	c.query = e
 	for _, fd := range e.FuncDefs {
 		if err := c.compileFuncDef(fd, false); err != nil {
 			return err
@@ -908,7 +1187,7 @@ func (c *compiler) compileFunc(e *Func) error {
 			return nil
 		} else if e.Name == "$ENV" || e.Name == "env" {
 			env := make(map[string]any)
-			if c.environLoader != nil {
+			if c.environLoader != nil && !c.deterministic {
 				for _, kv := range c.environLoader() {
 					if i := strings.IndexByte(kv, '='); i > 0 {
 						env[kv[:i]] = kv[i+1:]
@@ -917,8 +1196,11 @@ func (c *compiler) compileFunc(e *Func) error {
 			}
 			c.append(&code{op: opconst, v: env})
 			return nil
+		} else if e.Name == "$__loc__" {
+			c.append(&code{op: opconst, v: e.loc.value()})
+			return nil
 		} else if e.Name[0] == '$' {
-			return &variableNotFoundError{e.Name}
+			return &variableNotFoundError{e.Name, "", e.offset}
 		}
 	} else {
 		for i := len(c.scopes) - 1; i >= 0; i-- {
@@ -929,6 +1211,12 @@ func (c *compiler) compileFunc(e *Func) error {
 				}
 			}
 		}
+		switch {
+		case e.Name == "select" && len(e.Args) == 1:
+			return c.compileSelect(e.Args[0])
+		case e.Name == "map" && len(e.Args) == 1:
+			return c.compileMap(e.Args[0])
+		}
 	}
 	if f := c.lookupBuiltin(e.Name, len(e.Args)); f != nil {
 		return c.compileCallPc(f, e.Args)
@@ -983,6 +1271,20 @@ func (c *compiler) compileFunc(e *Func) error {
 				true,
 				-1,
 			)
+		case "peek_input":
+			if c.inputIter == nil {
+				return &inputNotAllowedError{}
+			}
+			if err := c.compileCallInternal(
+				[3]any{c.funcPeekInput, 0, e.Name},
+				e.Args,
+				true,
+				-1,
+			); err != nil {
+				return err
+			}
+			c.append(&code{op: opiter})
+			return nil
 		case "modulemeta":
 			return c.compileCallInternal(
 				[3]any{c.funcModulemeta, 0, e.Name},
@@ -990,17 +1292,69 @@ func (c *compiler) compileFunc(e *Func) error {
 				true,
 				-1,
 			)
+		case "fromstream":
+			if err := c.compileCallInternal(
+				[3]any{filterCallback(funcFromstream), 1, e.Name},
+				e.Args,
+				false,
+				-1,
+			); err != nil {
+				return err
+			}
+			c.append(&code{op: opiter})
+			return nil
+		case "debug", "stderr":
+			if _, ok := c.customFuncs[e.Name]; ok {
+				break // the custom function defined by WithFunction takes precedence
+			}
+			f := c.funcDebug
+			if e.Name == "stderr" {
+				f = c.funcStderr
+			}
+			return c.compileCallInternal(
+				[3]any{f, 0, e.Name},
+				e.Args,
+				true,
+				-1,
+			)
+		case "now":
+			if c.deterministic {
+				c.append(&code{op: opconst, v: c.now})
+				return nil
+			}
+			return c.compileCall(e.Name, e.Args)
+		case "localtime":
+			if c.deterministic {
+				return c.compileCall("gmtime", e.Args)
+			}
+			return c.compileCall(e.Name, e.Args)
+		case "strflocaltime":
+			if c.deterministic {
+				return c.compileCall("strftime", e.Args)
+			}
+			return c.compileCall(e.Name, e.Args)
 		default:
 			return c.compileCall(e.Name, e.Args)
 		}
 	}
 	if fn, ok := c.customFuncs[e.Name]; ok && fn.accept(len(e.Args)) {
-		if err := c.compileCallInternal(
-			[3]any{fn.callback, len(e.Args), e.Name},
-			e.Args,
-			true,
-			-1,
-		); err != nil {
+		var err error
+		if c.filterFuncs[e.Name] {
+			err = c.compileCallInternal(
+				[3]any{filterCallback(fn.callback), len(e.Args), e.Name},
+				e.Args,
+				false,
+				-1,
+			)
+		} else {
+			err = c.compileCallInternal(
+				[3]any{fn.callback, len(e.Args), e.Name},
+				e.Args,
+				true,
+				-1,
+			)
+		}
+		if err != nil {
 			return err
 		}
 		if fn.iter {
@@ -1008,7 +1362,54 @@ func (c *compiler) compileFunc(e *Func) error {
 		}
 		return nil
 	}
-	return &funcNotFoundError{e}
+	return &funcNotFoundError{e, e.offset}
+}
+
+// Appends the compiled code for `select(f)` at the call site. Originally the
+// function was implemented as follows.
+//
+//	def select(f): if f then . else empty end;
+//
+// Expanding the condition inline avoids the closure call on each value, which
+// makes the common idioms `.[] | select(f)` and `map(select(f))` run as plain
+// loops without entering any function scope.
+func (c *compiler) compileSelect(cond *Query) error {
+	c.appendCodeInfo("select")
+	c.append(&code{op: opdup})
+	c.append(&code{op: opexpbegin})
+	f := c.newScopeDepth()
+	if err := c.compileQuery(cond); err != nil {
+		return err
+	}
+	f()
+	c.append(&code{op: opexpend})
+	setjumpifnot := c.lazy(func() *code {
+		return &code{op: opjumpifnot, v: len(c.codes)}
+	})
+	defer c.lazy(func() *code {
+		return &code{op: opjump, v: len(c.codes)}
+	})()
+	setjumpifnot()
+	c.append(&code{op: opbacktrack})
+	return nil
+}
+
+// Appends the compiled code for `map(f)` at the call site. Originally the
+// function was implemented as follows.
+//
+//	def map(f): [.[] | f];
+//
+// The array is constructed directly by the caller, so `f` is evaluated inline
+// without closure calls. Note that `to_entries | map(f) | from_entries` (and
+// with_entries) still creates the intermediate arrays; from_entries reports
+// the invalid entries with the whole array after evaluating `f` on all the
+// entries, which the fused loop cannot tell without creating the array.
+func (c *compiler) compileMap(f *Query) error {
+	return c.compileArray(&Array{Query: &Query{
+		Left:  &Query{Term: &Term{Type: TermTypeIdentity, SuffixList: []*Suffix{{Iter: true}}}},
+		Op:    OpPipe,
+		Right: f,
+	}})
 }
 
 // Appends the compiled code for the assignment operator (`=`) to maximize
@@ -1154,9 +1555,12 @@ func (c *compiler) funcBuiltins(any, []any) any {
 		return xs[i].name < xs[j].name ||
 			xs[i].name == xs[j].name && xs[i].arity < xs[j].arity
 	})
-	ys := make([]any, len(xs))
+	// The custom functions can override the internal functions (like debug).
+	ys := make([]any, 0, len(xs))
 	for i, x := range xs {
-		ys[i] = x.name + "/" + strconv.Itoa(x.arity)
+		if i == 0 || *x != *xs[i-1] {
+			ys = append(ys, x.name+"/"+strconv.Itoa(x.arity))
+		}
 	}
 	return ys
 }
@@ -1166,7 +1570,37 @@ func (c *compiler) funcInput(any, []any) any {
 	if !ok {
 		return errors.New("break")
 	}
-	return normalizeNumbers(v)
+	return normalizeValue(v, c.stringifyKeys)
+}
+
+func (c *compiler) funcPeekInput(any, []any) any {
+	v, ok := c.inputIter.(inputPeeker).Peek()
+	if !ok {
+		return emptyIter{}
+	}
+	return NewIter(normalizeValue(v, c.stringifyKeys))
+}
+
+func (c *compiler) funcDebug(v any, _ []any) any {
+	if c.debugHandler != nil {
+		if err := c.debugHandler([]any{"DEBUG:", v}); err != nil {
+			return err
+		}
+	}
+	return v
+}
+
+func (c *compiler) funcStderr(v any, _ []any) any {
+	if c.stderr != nil {
+		s, ok := v.(string)
+		if !ok {
+			s = jsonMarshal(v)
+		}
+		if _, err := io.WriteString(c.stderr, s); err != nil {
+			return err
+		}
+	}
+	return v
 }
 
 func (c *compiler) funcModulemeta(v any, _ []any) any {
@@ -1191,6 +1625,8 @@ func (c *compiler) funcModulemeta(v any, _ []any) any {
 		if q, err = moduleLoader.LoadModule(s); err != nil {
 			return err
 		}
+	} else {
+		return fmt.Errorf("module not found: %q", s)
 	}
 	meta := q.Meta.ToValue()
 	if meta == nil {
@@ -1295,7 +1731,7 @@ func (c *compiler) compileObjectKeyVal(v [2]int, kv *ObjectKeyVal) error {
 				c.append(&code{op: oppush, v: key[1:]})
 			}
 			c.append(&code{op: opload, v: v})
-			if err := c.compileFunc(&Func{Name: key}); err != nil {
+			if err := c.compileFunc(&Func{Name: key, loc: kv.loc}); err != nil {
 				return err
 			}
 		} else {
@@ -1314,7 +1750,11 @@ func (c *compiler) compileObjectKeyVal(v [2]int, kv *ObjectKeyVal) error {
 			}
 		} else {
 			c.append(&code{op: opload, v: v})
-			if err := c.compileString(key, nil); err != nil {
+			if kv.KeyFormat != "" {
+				if err := c.compileFormat(kv.KeyFormat, key); err != nil {
+					return err
+				}
+			} else if err := c.compileString(key, nil); err != nil {
 				return err
 			}
 			if kv.Val == nil {
@@ -1596,19 +2036,29 @@ func (c *compiler) compileCallInternal(
 }
 
 func (c *compiler) append(code *code) {
+	c.appendSource()
 	c.codes = append(c.codes, code)
 }
 
 func (c *compiler) appends(codes ...*code) {
-	c.codes = append(c.codes, codes...)
+	for _, code := range codes {
+		c.append(code)
+	}
 }
 
 func (c *compiler) lazy(f func() *code) func() {
 	i := len(c.codes)
-	c.codes = append(c.codes, nil)
+	c.append(nil)
 	return func() { c.codes[i] = f() }
 }
 
+// Records the query being compiled as the source of the next code. The side
+// table is truncated along with the codes, and the codes replaced in place
+// inherit the source of the original ones.
+func (c *compiler) appendSource() {
+	c.sources = append(c.sources[:len(c.codes)], c.query)
+}
+
 func (c *compiler) optimizeTailRec() {
 	var pcs []int
 	scopes := map[int]bool{}
@@ -2,10 +2,13 @@ package gojq_test
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"reflect"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -104,6 +107,216 @@ func ExampleCode_RunWithContext() {
 	// context deadline exceeded
 }
 
+func ExampleCode_RunBatch() {
+	query, err := gojq.Parse(".[] | select(. > $x)")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	code, err := gojq.Compile(query, gojq.WithVariables([]string{"$x"}))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	results, err := code.RunBatch([]any{
+		[]any{1, 2, 3},
+		[]any{},
+		[]any{4, 5},
+		"foo",
+	}, 1)
+	for _, vs := range results {
+		fmt.Printf("%#v\n", vs)
+	}
+	if err != nil {
+		fmt.Println(err)
+	}
+
+	// Output:
+	// []interface {}{2, 3}
+	// []interface {}{}
+	// []interface {}{4, 5}
+	// cannot iterate over: string ("foo")
+}
+
+func ExampleRunMany() {
+	var codes []*gojq.Code
+	for _, src := range []string{".size > 100", ".user", ".tags[]", ".size.x"} {
+		query, err := gojq.Parse(src)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		code, err := gojq.Compile(query)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		codes = append(codes, code)
+	}
+	input := json.RawMessage(`{"user": "admin", "size": 500, "tags": ["a", "b"]}`)
+	for i, iter := range gojq.RunMany(codes, input) {
+		for {
+			v, ok := iter.Next()
+			if !ok {
+				break
+			}
+			if err, ok := v.(error); ok {
+				fmt.Printf("%d: error: %s\n", i, err)
+				continue
+			}
+			fmt.Printf("%d: %#v\n", i, v)
+		}
+	}
+
+	// Output:
+	// 0: true
+	// 1: "admin"
+	// 2: "a"
+	// 2: "b"
+	// 3: error: expected an object but got: number (500)
+}
+
+func TestRunMany(t *testing.T) {
+	var codes []*gojq.Code
+	for _, src := range []string{".a = 1", ".a", "$x"} {
+		query, err := gojq.Parse(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		code, err := gojq.Compile(query, gojq.WithVariables(
+			map[bool][]string{true: {"$x"}}[src == "$x"],
+		))
+		if err != nil {
+			t.Fatal(err)
+		}
+		codes = append(codes, code)
+	}
+	var results []any
+	for _, iter := range gojq.RunMany(codes, map[string]any{"a": json.Number("2")}) {
+		for {
+			v, ok := iter.Next()
+			if !ok {
+				break
+			}
+			if err, ok := v.(error); ok {
+				v = err.Error()
+			}
+			results = append(results, v)
+		}
+	}
+	expected := []any{map[string]any{"a": 1}, 2, "variable defined but not bound: $x"}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("expected: %v, got: %v", expected, results)
+	}
+	results = nil
+	for _, iter := range gojq.RunMany(codes[:2], json.RawMessage(`{`)) {
+		v, _ := iter.Next()
+		results = append(results, fmt.Sprint(v))
+	}
+	expected = []any{"invalid value: unexpected EOF", "invalid value: unexpected EOF"}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("expected: %v, got: %v", expected, results)
+	}
+}
+
+func ExampleCode_RunInputs() {
+	query, err := gojq.Parse(".[] | select(. > $x)")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	code, err := gojq.Compile(query, gojq.WithVariables([]string{"$x"}))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	inputs := gojq.NewIter([]any{1, 2, 3}, "foo", []any{4, 5})
+	iter := code.RunInputs(
+		context.Background(), inputs,
+		gojq.WithRunVariables(map[string]any{"$x": 1}),
+		gojq.WithIsolatedErrors(),
+	)
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			break
+		}
+		if err, ok := v.(gojq.InputError); ok {
+			index, input := err.Input()
+			fmt.Printf("input %d (%#v): %s\n", index, input, errors.Unwrap(err))
+			continue
+		}
+		fmt.Printf("%#v\n", v)
+	}
+
+	// Output:
+	// 2
+	// 3
+	// input 1 ("foo"): cannot iterate over: string ("foo")
+	// 4
+	// 5
+}
+
+func TestCodeRunInputs(t *testing.T) {
+	query, err := gojq.Parse("if . == 3 then halt_error else (1, 10 / .) end")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := gojq.Compile(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		options  []gojq.RunOption
+		expected []any
+	}{
+		{
+			nil,
+			[]any{1, 10, 1, "cannot divide number (10) by: number (0)"},
+		},
+		{
+			[]gojq.RunOption{gojq.WithIsolatedErrors()},
+			[]any{
+				1, 10, 1, "input 1: cannot divide number (10) by: number (0)",
+				"input 2: invalid input", 1, 5, "error: 3",
+			},
+		},
+	} {
+		inputs := gojq.NewIter(1, 0, errors.New("invalid input"), 2, 3, 4)
+		iter := code.RunInputs(context.Background(), inputs, tc.options...)
+		var got []any
+		for {
+			v, ok := iter.Next()
+			if !ok {
+				break
+			}
+			if err, ok := v.(error); ok {
+				v = err.Error()
+			}
+			got = append(got, v)
+		}
+		if !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("expected: %v, got: %v", tc.expected, got)
+		}
+	}
+}
+
+func TestCodeRunInputsVariableError(t *testing.T) {
+	query, err := gojq.Parse("$x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := gojq.Compile(query, gojq.WithVariables([]string{"$x"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	iter := code.RunInputs(context.Background(), gojq.NewIter(1), gojq.WithIsolatedErrors())
+	v, ok := iter.Next()
+	if !ok {
+		t.Fatal("expected an error but got no output")
+	}
+	if err, ok := v.(error); !ok || err.Error() != "variable defined but not bound: $x" {
+		t.Errorf("expected an error but got: %v", v)
+	}
+	if v, ok := iter.Next(); ok {
+		t.Errorf("expected no output but got: %v", v)
+	}
+}
+
 func TestCodeCompile_OptimizeConstants(t *testing.T) {
 	query, err := gojq.Parse(`[1,{foo:2,"bar":+3},[-4]]`)
 	if err != nil {
@@ -246,6 +459,91 @@ func TestCodeCompile_OptimizeTailRec_CallRec(t *testing.T) {
 	}
 }
 
+// The original definitions of the functions inlined by the compiler. The user
+// defined functions take precedence over the inlined ones.
+const mapSelectDefs = `
+	def map(f): [.[] | f];
+	def select(f): if f then . else empty end;
+`
+
+func TestCodeCompile_InlineMapSelect(t *testing.T) {
+	run := func(src string, v any) []any {
+		query, err := gojq.Parse(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		code, err := gojq.Compile(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var vs []any
+		iter := code.Run(v)
+		for {
+			v, ok := iter.Next()
+			if !ok {
+				return vs
+			}
+			if err, ok := v.(error); ok {
+				v = "error: " + err.Error()
+			}
+			vs = append(vs, v)
+		}
+	}
+	for _, src := range []string{
+		"map(select(. > 1))",
+		"map(select(. > 1, . > 2))",
+		"map(select(empty))",
+		"map(., . * 2)",
+		"map(.a)",
+		"try map(error) catch .",
+		"first(map(select(. > 1)))",
+		"label $x | map(select(. > 1) | ., break $x)",
+		".[] | select(. > 1)",
+		"path(.[] | select(. > 1))",
+		"(.[] | select(. > 1)) |= . * 10",
+		"del(.[] | select(. == 2))",
+		"path(map(select(. > 1)))",
+	} {
+		for _, v := range []any{[]any{1, 2, 3}, map[string]any{"a": 3, "b": 1}, 1} {
+			got, expected := run(src, v), run(mapSelectDefs+src, v)
+			if !reflect.DeepEqual(got, expected) {
+				t.Errorf("%s with %v: expected: %v, got: %v", src, v, expected, got)
+			}
+		}
+	}
+}
+
+func BenchmarkInlineMapSelect(b *testing.B) {
+	v := make([]any, 1000)
+	for i := range v {
+		v[i] = i
+	}
+	for _, tc := range []struct{ name, src string }{
+		{"inline", "map(select(. % 2 == 0))"},
+		{"original", mapSelectDefs + "map(select(. % 2 == 0))"},
+	} {
+		b.Run(tc.name, func(b *testing.B) {
+			query, err := gojq.Parse(tc.src)
+			if err != nil {
+				b.Fatal(err)
+			}
+			code, err := gojq.Compile(query)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				iter := code.Run(v)
+				for {
+					if _, ok := iter.Next(); !ok {
+						break
+					}
+				}
+			}
+		})
+	}
+}
+
 func TestCodeCompile_OptimizeJumps(t *testing.T) {
 	query, err := gojq.Parse("def f: 1; def g: 2; def h: 3; f")
 	if err != nil {
@@ -309,6 +607,128 @@ func TestCodeRun_Race(t *testing.T) {
 	wg.Wait()
 }
 
+func TestCodeRun_InternalError(t *testing.T) {
+	query, err := gojq.Parse("1, f, 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := gojq.Compile(query,
+		gojq.WithFunction("f", 0, 0, func(v any, _ []any) any {
+			var m map[string]any
+			m["x"] = v
+			return m
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iter := code.Run(nil)
+	if v, _ := iter.Next(); v != 1 {
+		t.Errorf("expected: %v, got: %v", 1, v)
+	}
+	v, ok := iter.Next()
+	if !ok {
+		t.Fatal("should emit an error but got no output")
+	}
+	if err, ok := v.(gojq.InternalError); !ok {
+		t.Errorf("should emit an internal error but got: %v", v)
+	} else {
+		if expected := "internal error: assignment to entry in nil map"; !strings.HasPrefix(err.Error(), expected) {
+			t.Errorf("expected: %v, got: %v", expected, err)
+		}
+		pc, op := err.Bytecode()
+		if op != "call" {
+			t.Errorf("expected: %v, got: %v", "call", op)
+		}
+		if q := code.Source(pc); q == nil || q.String() != "f" {
+			t.Errorf("expected: %v, got: %v", "f", q)
+		}
+		if expected := "query: f)"; !strings.HasSuffix(err.Error(), expected) {
+			t.Errorf("expected: %v, got: %v", expected, err)
+		}
+		if _, ok := err.Recovered().(error); !ok {
+			t.Errorf("should recover a runtime error but got: %v", err.Recovered())
+		}
+	}
+	if v, ok := iter.Next(); ok {
+		t.Errorf("should terminate but got: %v", v)
+	}
+}
+
+func TestCodeSource(t *testing.T) {
+	query, err := gojq.Parse(`.foo | [1, 2] | map(. * 2) | {(.[] | tostring): 3}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := gojq.Compile(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q := code.Source(-1); q != nil {
+		t.Errorf("expected: %v, got: %v", nil, q)
+	}
+	sources := map[string]bool{}
+	for pc := 0; pc < 1000; pc++ {
+		if q := code.Source(pc); q != nil {
+			sources[q.String()] = true
+		}
+	}
+	for _, expected := range []string{".foo", "[1, 2]", ". * 2", ".[]", "tostring", "3"} {
+		if !sources[expected] {
+			t.Errorf("expected source %q in: %v", expected, sources)
+		}
+	}
+}
+
+func TestCompileErrorToken(t *testing.T) {
+	for _, tc := range []struct {
+		src, prelude string
+		token        string
+		offset       int
+	}{
+		{".foo | bar", "", "bar", 10},
+		{"1 as $x | [$x, $y]", "", "$y", 17},
+		{"def f: $z; f", "", "$z", 9},
+		{"f", "def f: g;", "g", -1},
+	} {
+		query, err := gojq.Parse(tc.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = gojq.Compile(query, gojq.WithPrelude(tc.prelude))
+		e, ok := err.(interface{ Token() (string, int) })
+		if !ok {
+			t.Fatalf("%s: expected an error with the token but got: %v", tc.src, err)
+		}
+		if token, offset := e.Token(); token != tc.token || offset != tc.offset {
+			t.Errorf("%s: expected: %q, %d, got: %q, %d", tc.src, tc.token, tc.offset, token, offset)
+		}
+	}
+}
+
+func TestCodeRun_Allocs(t *testing.T) {
+	query, err := gojq.Parse(".foo | . + 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := gojq.Compile(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v any = map[string]any{"foo": 1}
+	allocs := testing.AllocsPerRun(100, func() {
+		iter := code.Run(v)
+		for {
+			if _, ok := iter.Next(); !ok {
+				break
+			}
+		}
+	})
+	if expected := 1.0; allocs > expected {
+		t.Errorf("expected allocations: %v, got: %v", expected, allocs)
+	}
+}
+
 func BenchmarkCompile(b *testing.B) {
 	cnt, err := os.ReadFile("builtin.jq")
 	if err != nil {
@@ -0,0 +1,203 @@
+package gojq
+
+import "strconv"
+
+// Complexity is the static estimation of the cost of a query, reported by
+// [Query.Complexity]. The estimation does not run the query, so it cannot
+// tell the actual cost, but helps rejecting obviously expensive queries.
+type Complexity struct {
+	// The maximum nesting depth of the query.
+	Depth int
+	// The number of the terms which may emit multiple values.
+	Generators int
+	// Whether the query contains recursion.
+	Recursive bool
+	// Whether the query uses regular expressions.
+	Regexp bool
+	// The score combining the above; larger is more expensive.
+	Score int
+}
+
+// Complexity estimates the complexity of the query.
+func (e *Query) Complexity() *Complexity {
+	var c complexityEstimator
+	c.query(e, 1)
+	x := &c.complexity
+	x.Score = x.Depth + x.Generators*x.Generators
+	if x.Recursive {
+		x.Score *= 4
+	}
+	if x.Regexp {
+		x.Score *= 2
+	}
+	return x
+}
+
+type complexityEstimator struct {
+	complexity Complexity
+	funcDefs   []string
+}
+
+var complexityGeneratorFuncs = map[string]bool{
+	"range/1": true, "range/2": true, "range/3": true, "recurse/0": true,
+	"recurse/1": true, "recurse/2": true, "repeat/1": true, "while/2": true,
+	"until/2": true, "paths/0": true, "paths/1": true, "leaf_paths/0": true,
+	"inputs/0": true, "splits/1": true, "splits/2": true, "scan/1": true,
+	"scan/2": true, "match/1": true, "match/2": true, "capture/1": true,
+	"capture/2": true, "combinations/0": true, "combinations/1": true,
+	"limit/2": true, "getpath/1": true, "tostream/0": true, "env/0": true,
+}
+
+var complexityRecursiveFuncs = map[string]bool{
+	"recurse/0": true, "recurse/1": true, "recurse/2": true, "repeat/1": true,
+	"while/2": true, "until/2": true, "paths/0": true, "paths/1": true,
+	"leaf_paths/0": true, "walk/1": true, "tostream/0": true,
+	"combinations/0": true, "combinations/1": true,
+}
+
+var complexityRegexpFuncs = map[string]bool{
+	"test/1": true, "test/2": true, "match/1": true, "match/2": true,
+	"capture/1": true, "capture/2": true, "scan/1": true, "scan/2": true,
+	"splits/1": true, "splits/2": true, "split/2": true, "sub/2": true,
+	"sub/3": true, "gsub/2": true, "gsub/3": true,
+}
+
+func (c *complexityEstimator) query(e *Query, depth int) {
+	if depth > c.complexity.Depth {
+		c.complexity.Depth = depth
+	}
+	for _, fd := range e.FuncDefs {
+		c.funcDefs = append(c.funcDefs, fd.Name+"/"+strconv.Itoa(len(fd.Args)))
+		c.query(fd.Body, depth+1)
+		c.funcDefs = c.funcDefs[:len(c.funcDefs)-1]
+	}
+	if e.Func != "" {
+		c.function(e.Func, 0)
+	} else if e.Term != nil {
+		c.term(e.Term, depth)
+	} else if e.Right != nil {
+		if e.Op == OpComma {
+			c.complexity.Generators++
+		}
+		c.query(e.Left, depth)
+		c.query(e.Right, depth)
+	}
+}
+
+func (c *complexityEstimator) function(name string, argcnt int) {
+	name += "/" + strconv.Itoa(argcnt)
+	for _, fd := range c.funcDefs {
+		if fd == name {
+			c.complexity.Recursive = true
+			break
+		}
+	}
+	if complexityGeneratorFuncs[name] {
+		c.complexity.Generators++
+	}
+	if complexityRecursiveFuncs[name] {
+		c.complexity.Recursive = true
+	}
+	if complexityRegexpFuncs[name] {
+		c.complexity.Regexp = true
+	}
+}
+
+func (c *complexityEstimator) term(e *Term, depth int) {
+	switch e.Type {
+	case TermTypeRecurse:
+		c.complexity.Generators++
+		c.complexity.Recursive = true
+	case TermTypeIndex:
+		c.index(e.Index, depth)
+	case TermTypeFunc:
+		c.function(e.Func.Name, len(e.Func.Args))
+		for _, e := range e.Func.Args {
+			c.query(e, depth+1)
+		}
+	case TermTypeObject:
+		for _, kv := range e.Object.KeyVals {
+			if kv.KeyString != nil {
+				c.str(kv.KeyString, depth)
+			} else if kv.KeyQuery != nil {
+				c.query(kv.KeyQuery, depth+1)
+			}
+			if kv.Val != nil {
+				for _, e := range kv.Val.Queries {
+					c.query(e, depth+1)
+				}
+			}
+		}
+	case TermTypeArray:
+		if e.Array.Query != nil {
+			c.query(e.Array.Query, depth+1)
+		}
+	case TermTypeUnary:
+		c.term(e.Unary.Term, depth)
+	case TermTypeFormat:
+		if e.Str != nil {
+			c.str(e.Str, depth)
+		}
+	case TermTypeString:
+		c.str(e.Str, depth)
+	case TermTypeIf:
+		c.query(e.If.Cond, depth+1)
+		c.query(e.If.Then, depth+1)
+		for _, e := range e.If.Elif {
+			c.query(e.Cond, depth+1)
+			c.query(e.Then, depth+1)
+		}
+		if e.If.Else != nil {
+			c.query(e.If.Else, depth+1)
+		}
+	case TermTypeTry:
+		c.query(e.Try.Body, depth+1)
+		if e.Try.Catch != nil {
+			c.query(e.Try.Catch, depth+1)
+		}
+	case TermTypeReduce:
+		c.term(e.Reduce.Term, depth+1)
+		c.query(e.Reduce.Start, depth+1)
+		c.query(e.Reduce.Update, depth+1)
+	case TermTypeForeach:
+		c.complexity.Generators++
+		c.term(e.Foreach.Term, depth+1)
+		c.query(e.Foreach.Start, depth+1)
+		c.query(e.Foreach.Update, depth+1)
+		if e.Foreach.Extract != nil {
+			c.query(e.Foreach.Extract, depth+1)
+		}
+	case TermTypeLabel:
+		c.query(e.Label.Body, depth+1)
+	case TermTypeQuery:
+		c.query(e.Query, depth+1)
+	}
+	for _, e := range e.SuffixList {
+		switch {
+		case e.Index != nil:
+			c.index(e.Index, depth)
+		case e.Iter:
+			c.complexity.Generators++
+		case e.Bind != nil:
+			c.query(e.Bind.Body, depth+1)
+		}
+	}
+}
+
+func (c *complexityEstimator) index(e *Index, depth int) {
+	if e.Str != nil {
+		c.str(e.Str, depth)
+	}
+	if e.Start != nil {
+		c.query(e.Start, depth+1)
+	}
+	if e.End != nil {
+		c.query(e.End, depth+1)
+	}
+}
+
+func (c *complexityEstimator) str(e *String, depth int) {
+	for _, e := range e.Queries {
+		c.query(e, depth+1)
+	}
+}
@@ -0,0 +1,78 @@
+package gojq_test
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"testing"
+
+	"github.com/rturpen/gojq"
+)
+
+func ExampleQuery_Complexity() {
+	query, err := gojq.Parse(`.[] | select(.name | test("^a")) | [.items[] | .x]`)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	c := query.Complexity()
+	fmt.Printf("depth: %d, generators: %d, recursive: %t, regexp: %t, score: %d\n",
+		c.Depth, c.Generators, c.Recursive, c.Regexp, c.Score)
+
+	// Output:
+	// depth: 3, generators: 2, recursive: false, regexp: true, score: 14
+}
+
+func TestQueryComplexity(t *testing.T) {
+	testCases := []struct {
+		src      string
+		expected gojq.Complexity
+	}{
+		{
+			src:      ".",
+			expected: gojq.Complexity{Depth: 1, Score: 1},
+		},
+		{
+			src:      ".foo.bar",
+			expected: gojq.Complexity{Depth: 1, Score: 1},
+		},
+		{
+			src:      "1, 2, 3",
+			expected: gojq.Complexity{Depth: 1, Generators: 2, Score: 5},
+		},
+		{
+			src:      "[.[] | {a: [range(.)]}]",
+			expected: gojq.Complexity{Depth: 5, Generators: 2, Score: 9},
+		},
+		{
+			src:      "..",
+			expected: gojq.Complexity{Depth: 1, Generators: 1, Recursive: true, Score: 8},
+		},
+		{
+			src:      "def f: if . > 0 then . - 1 | f else . end; f",
+			expected: gojq.Complexity{Depth: 3, Recursive: true, Score: 12},
+		},
+		{
+			src:      "def f: 1; def g: f; g",
+			expected: gojq.Complexity{Depth: 2, Score: 2},
+		},
+		{
+			src:      `"\(sub("a"; "b"))"`,
+			expected: gojq.Complexity{Depth: 4, Regexp: true, Score: 8},
+		},
+		{
+			src:      "reduce .[] as $x (0; . + $x)",
+			expected: gojq.Complexity{Depth: 2, Generators: 1, Score: 3},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.src, func(t *testing.T) {
+			query, err := gojq.Parse(tc.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := query.Complexity(); !reflect.DeepEqual(*got, tc.expected) {
+				t.Errorf("expected: %+v, got: %+v", tc.expected, *got)
+			}
+		})
+	}
+}
@@ -210,3 +210,185 @@ func debugValue(v any) string {
 		return Preview(v)
 	}
 }
+
+type verifyCodeError struct {
+	pc  int
+	op  opcode
+	msg string
+}
+
+func (err *verifyCodeError) Error() string {
+	return fmt.Sprintf("invalid bytecode at %d (%s): %s", err.pc, err.op, err.msg)
+}
+
+// Verifies the invariants of the compiled codes; the operand types, the jump
+// targets, the pairing of scopes and returns, and the balance of the stack
+// depth, the expression depth and the path depth in each function body. This
+// is for catching compiler bugs early, so it runs only in the debug build.
+func (c *compiler) verifyCodes() error {
+	codes := c.codes
+	owners := make([]int, len(codes))
+	var scopes []int
+	for pc, code := range codes {
+		if code == nil {
+			return &verifyCodeError{pc, opnop, "missing code"}
+		}
+		if err := verifyOperand(codes, pc); err != nil {
+			return err
+		}
+		if code.op == opscope {
+			scopes = append(scopes, pc)
+		}
+		if len(scopes) == 0 {
+			return &verifyCodeError{pc, code.op, "code outside of scope"}
+		}
+		owners[pc] = scopes[len(scopes)-1]
+		if code.op == opret {
+			scopes = scopes[:len(scopes)-1]
+		}
+	}
+	if len(scopes) > 0 {
+		pc := scopes[len(scopes)-1]
+		return &verifyCodeError{pc, opscope, "scope without ret"}
+	}
+	for pc, code := range codes {
+		if code.op == opscope {
+			argcnt := code.v.([3]int)[2]
+			if pc == 0 {
+				argcnt = len(c.variables)
+			}
+			if err := verifyFunc(codes, owners, pc, argcnt); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func verifyOperand(codes []*code, pc int) error {
+	code := codes[pc]
+	var ok bool
+	switch code.op {
+	case opload, opstore, opappend, opforklabel:
+		_, ok = code.v.([2]int)
+	case opscope:
+		_, ok = code.v.([3]int)
+	case opobject:
+		var n int
+		n, ok = code.v.(int)
+		ok = ok && n >= 0
+	case opfork, opforktrybegin, opforkalt, opjump, opjumpifnot:
+		var i int
+		if i, ok = code.v.(int); ok && (i < 0 || i >= len(codes)) {
+			return &verifyCodeError{pc, code.op, "jump target out of range: " + strconv.Itoa(i)}
+		}
+	case opcall, opcallrec, oppushpc:
+		switch v := code.v.(type) {
+		case int:
+			if v < 0 || v >= len(codes) || codes[v] == nil || codes[v].op != opscope {
+				return &verifyCodeError{pc, code.op, "call target is not a scope: " + strconv.Itoa(v)}
+			}
+			ok = true
+		case [3]any:
+			_, ok = v[1].(int)
+			ok = ok && code.op == opcall
+		}
+	default:
+		ok = true
+	}
+	if !ok {
+		return &verifyCodeError{pc, code.op, "invalid operand: " + debugOperand(code)}
+	}
+	return nil
+}
+
+type verifyState struct {
+	depth, expdepth, pathdepth int
+}
+
+func verifyFunc(codes []*code, owners []int, scope, argcnt int) error {
+	states := make(map[int]verifyState)
+	var pcs []int
+	visit := func(from, pc int, s verifyState) error {
+		if owners[pc] != scope || codes[pc].op == opscope {
+			return &verifyCodeError{from, codes[from].op, "jump out of function: " + strconv.Itoa(pc)}
+		}
+		if t, ok := states[pc]; ok {
+			if t != s {
+				return &verifyCodeError{pc, codes[pc].op, fmt.Sprintf(
+					"inconsistent depths (stack, exp, path): %v and %v", t, s)}
+			}
+			return nil
+		}
+		states[pc] = s
+		pcs = append(pcs, pc)
+		return nil
+	}
+	if err := visit(scope, scope+1, verifyState{}); err != nil {
+		return err
+	}
+	for len(pcs) > 0 {
+		pc := pcs[len(pcs)-1]
+		pcs = pcs[:len(pcs)-1]
+		code, s := codes[pc], states[pc]
+		next, target := true, -1
+		switch code.op {
+		case oppush, opdup, opload, oppushpc:
+			s.depth++
+		case oppop, opstore, opappend, opcallpc:
+			s.depth--
+		case opobject:
+			s.depth -= 2*code.v.(int) - 1
+		case opfork, opforktrybegin, opforkalt:
+			target = code.v.(int)
+		case opjump:
+			next, target = false, code.v.(int)
+		case opjumpifnot:
+			s.depth--
+			target = code.v.(int)
+		case opcall:
+			switch v := code.v.(type) {
+			case int:
+				s.depth -= codes[v].v.([3]int)[2]
+			case [3]any:
+				s.depth -= v[1].(int)
+				next = v[2] != "_break" // always emits breakError
+			}
+		case opexpbegin:
+			s.expdepth++
+		case opexpend:
+			s.expdepth--
+		case oppathbegin:
+			s.pathdepth++
+		case oppathend:
+			s.depth--
+			s.pathdepth--
+		case opbacktrack, opcallrec:
+			next = false
+		case opret:
+			if s != (verifyState{depth: -argcnt}) {
+				return &verifyCodeError{pc, code.op, fmt.Sprintf(
+					"unbalanced depths (stack, exp, path): %v", s)}
+			}
+			next = false
+		}
+		if s.depth < -argcnt-1 || s.expdepth < 0 || s.pathdepth < 0 {
+			return &verifyCodeError{pc, code.op, fmt.Sprintf(
+				"negative depths (stack, exp, path): %v", s)}
+		}
+		if target >= 0 {
+			if err := visit(pc, target, s); err != nil {
+				return err
+			}
+		}
+		if next {
+			if pc+1 >= len(codes) {
+				return &verifyCodeError{pc, code.op, "falls off the end of codes"}
+			}
+			if err := visit(pc, pc+1, s); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+//go:build gojq_debug
+// +build gojq_debug
+
+package gojq
+
+import "testing"
+
+func TestVerifyCodes(t *testing.T) {
+	scope := &code{op: opscope, v: [3]int{0, 0, 0}}
+	testCases := []struct {
+		name  string
+		codes []*code
+		err   string
+	}{
+		{
+			name:  "valid codes",
+			codes: []*code{scope, {op: oppush, v: 1}, {op: oppop}, {op: opret}},
+		},
+		{
+			name:  "missing code",
+			codes: []*code{scope, nil, {op: opret}},
+			err:   "invalid bytecode at 1 (nop): missing code",
+		},
+		{
+			name:  "code outside of scope",
+			codes: []*code{{op: oppush, v: 1}},
+			err:   "invalid bytecode at 0 (push): code outside of scope",
+		},
+		{
+			name:  "scope without ret",
+			codes: []*code{scope, {op: oppush, v: 1}},
+			err:   "invalid bytecode at 0 (scope): scope without ret",
+		},
+		{
+			name:  "invalid operand",
+			codes: []*code{scope, {op: opload, v: 0}, {op: opret}},
+			err:   "invalid bytecode at 1 (load): invalid operand: 0",
+		},
+		{
+			name:  "jump target out of range",
+			codes: []*code{scope, {op: opjump, v: 5}, {op: opret}},
+			err:   "invalid bytecode at 1 (jump): jump target out of range: 5",
+		},
+		{
+			name:  "call target is not a scope",
+			codes: []*code{scope, {op: opcall, v: 2}, {op: opret}},
+			err:   "invalid bytecode at 1 (call): call target is not a scope: 2",
+		},
+		{
+			name: "jump out of function",
+			codes: []*code{
+				scope, {op: opjump, v: 4}, {op: opret},
+				{op: opscope, v: [3]int{1, 0, 0}}, {op: opret},
+			},
+			err: "invalid bytecode at 1 (jump): jump out of function: 4",
+		},
+		{
+			name: "inconsistent depths",
+			codes: []*code{
+				scope, {op: opfork, v: 3}, {op: oppush, v: 1},
+				{op: opret},
+			},
+			err: "invalid bytecode at 3 (ret): inconsistent depths (stack, exp, path): {0 0 0} and {1 0 0}",
+		},
+		{
+			name:  "unbalanced depths",
+			codes: []*code{scope, {op: oppush, v: 1}, {op: opret}},
+			err:   "invalid bytecode at 2 (ret): unbalanced depths (stack, exp, path): {1 0 0}",
+		},
+		{
+			name:  "negative depths",
+			codes: []*code{scope, {op: opexpend}, {op: opret}},
+			err:   "invalid bytecode at 1 (expend): negative depths (stack, exp, path): {0 -1 0}",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &compiler{codes: tc.codes}
+			err := c.verifyCodes()
+			if tc.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected error: %v, got no error", tc.err)
+			}
+			if err.Error() != tc.err {
+				t.Errorf("expected: %v, got: %v", tc.err, err)
+			}
+		})
+	}
+}
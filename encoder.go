@@ -16,17 +16,74 @@ import (
 //
 // This method accepts only limited types (nil, bool, int, float64, *big.Int,
 // string, []any, and map[string]any) because these are the possible types a
-// gojq iterator can emit. This method marshals NaN to null, truncates
-// infinities to (+|-) math.MaxFloat64, uses \b and \f in strings, and does not
-// escape '<', '>', '&', '\u2028', and '\u2029'. These behaviors are based on
-// the marshaler of jq command, and different from json.Marshal in the Go
-// standard library. Note that the result is not safe to embed in HTML.
+// gojq iterator can emit, and the types implementing [JQMarshaler]. This
+// method marshals NaN to null, truncates infinities to (+|-) math.MaxFloat64,
+// uses \b and \f in strings, and does not escape '<', '>', '&', '\u2028', and
+// '\u2029'. These behaviors are based on the marshaler of jq command, and
+// different from json.Marshal in the Go standard library. Note that the result
+// is not safe to embed in HTML.
 func Marshal(v any) ([]byte, error) {
 	var b bytes.Buffer
 	(&encoder{w: &b}).encode(v)
 	return b.Bytes(), nil
 }
 
+// JQMarshaler is the interface implemented by the custom types, which are
+// returned by the custom functions (refer to [WithFunction]), to control the
+// rendering of the values. MarshalJQ should return a value of the types a
+// gojq iterator can emit (the number types are normalized like the query
+// input), which is used by [Marshal], [TypeOf], [Compare], and built-in
+// functions like tostring, tojson, type, keys, length, indexing and iteration,
+// instead of panicking with the invalid type.
+type JQMarshaler interface {
+	MarshalJQ() any
+}
+
+func marshalJQ(v JQMarshaler) any {
+	w := normalizeNumbers(v.MarshalJQ())
+	if _, ok := w.(JQMarshaler); ok || isNormalizeError(w) {
+		panic(fmt.Sprintf("invalid value of MarshalJQ: %[1]T (%[1]v)", v))
+	}
+	return w
+}
+
+// Encoder is a reusable encoder of the jq-flavored JSON. Reusing an Encoder and
+// the output buffer avoids allocations per value when encoding a large number
+// of results. The zero value is ready to use. An Encoder is not safe for
+// concurrent use; create an Encoder for each goroutine.
+type Encoder struct {
+	encoder
+}
+
+// SetPrecision sets the number of significant digits of floating-point numbers.
+// The numbers are rounded before encoding, so 0.1+0.2 is encoded as 0.3 with
+// the precision 15. A non-positive precision, which is the default, encodes
+// the numbers with the minimal digits to represent them uniquely.
+func (e *Encoder) SetPrecision(prec int) {
+	e.prec = prec
+}
+
+// EncodeTo appends the jq-flavored JSON encoding of v to buf. The encoding is
+// the same as [Marshal] unless the precision is set. Reset buf after consuming
+// the output to reuse its underlying storage for the next value. When buf
+// cannot grow, this method returns [bytes.ErrTooLarge] and truncates buf to
+// the length before the call.
+func (e *Encoder) EncodeTo(buf *bytes.Buffer, v any) (err error) {
+	defer func(l int) {
+		e.w = nil
+		if r := recover(); r != nil {
+			if r != bytes.ErrTooLarge {
+				panic(r)
+			}
+			buf.Truncate(l)
+			err = bytes.ErrTooLarge
+		}
+	}(buf.Len())
+	e.w = buf
+	e.encode(v)
+	return nil
+}
+
 func jsonMarshal(v any) string {
 	var sb strings.Builder
 	(&encoder{w: &sb}).encode(v)
@@ -43,7 +100,8 @@ type encoder struct {
 		io.ByteWriter
 		io.StringWriter
 	}
-	buf [64]byte
+	buf  [64]byte
+	prec int
 }
 
 func (e *encoder) encode(v any) {
@@ -68,6 +126,8 @@ func (e *encoder) encode(v any) {
 		e.encodeArray(v)
 	case map[string]any:
 		e.encodeObject(v)
+	case JQMarshaler:
+		e.encode(marshalJQ(v))
 	default:
 		panic(fmt.Sprintf("invalid type: %[1]T (%[1]v)", v))
 	}
@@ -83,6 +143,11 @@ func (e *encoder) encodeFloat64(f float64) {
 		f = math.MaxFloat64
 	} else if f <= -math.MaxFloat64 {
 		f = -math.MaxFloat64
+	} else if f == 0 {
+		f = 0 // normalize negative zero
+	}
+	if e.prec > 0 && e.encodeFloat64Prec(f) {
+		return
 	}
 	format := byte('f')
 	if x := math.Abs(f); x != 0 && x < 1e-6 || x >= 1e21 {
@@ -99,6 +164,86 @@ func (e *encoder) encodeFloat64(f float64) {
 	e.w.Write(buf)
 }
 
+// Encodes the number rounded to the significant digits of the precision, in
+// the same notation as the minimal digits. Returns false without writing when
+// the minimal digits are not longer than the precision.
+func (e *encoder) encodeFloat64Prec(f float64) bool {
+	if e.prec >= 16 {
+		// The decimal numbers of 15 digits are distinct as float64, but the
+		// longer ones are not; use the minimal digits unless they are longer.
+		buf := strconv.AppendFloat(e.buf[:0], math.Abs(f), 'e', -1, 64)
+		n := bytes.IndexByte(buf, 'e')
+		if n > 1 {
+			n-- // the decimal point
+		}
+		if n <= e.prec {
+			return false
+		}
+	}
+	buf := strconv.AppendFloat(e.buf[:0], f, 'e', e.prec-1, 64)
+	var neg bool
+	if buf[0] == '-' {
+		neg, buf = true, buf[1:]
+	}
+	i := bytes.IndexByte(buf, 'e')
+	var exp int
+	for _, c := range buf[i+2:] {
+		exp = exp*10 + int(c-'0')
+	}
+	if buf[i+1] == '-' {
+		exp = -exp
+	} else if exp >= 308 {
+		// Rare enough to parse the number to check if it is rounded up over
+		// the maximum value, which is truncated as the infinities are.
+		if _, err := strconv.ParseFloat(string(buf), 64); err != nil {
+			f = math.Copysign(math.MaxFloat64, f)
+			e.w.Write(strconv.AppendFloat(e.buf[:0], f, 'e', -1, 64))
+			return true
+		}
+	}
+	// The significant digits without the decimal point and the trailing zeros.
+	digits := buf[:i]
+	if len(digits) > 1 {
+		copy(digits[1:], digits[2:])
+		digits = bytes.TrimRight(digits[:len(digits)-1], "0")
+	}
+	if neg {
+		e.w.WriteByte('-')
+	}
+	switch {
+	case exp <= -7 || exp >= 21:
+		e.w.WriteByte(digits[0])
+		if len(digits) > 1 {
+			e.w.WriteByte('.')
+			e.w.Write(digits[1:])
+		}
+		e.w.WriteByte('e')
+		if exp < 0 {
+			e.w.WriteByte('-')
+			exp = -exp
+		} else {
+			e.w.WriteByte('+')
+		}
+		e.w.Write(strconv.AppendInt(buf[len(buf):], int64(exp), 10))
+	case exp < 0:
+		e.w.WriteString("0.")
+		for ; exp < -1; exp++ {
+			e.w.WriteByte('0')
+		}
+		e.w.Write(digits)
+	case len(digits) <= exp+1:
+		e.w.Write(digits)
+		for i := len(digits); i <= exp; i++ {
+			e.w.WriteByte('0')
+		}
+	default:
+		e.w.Write(digits[:exp+1])
+		e.w.WriteByte('.')
+		e.w.Write(digits[exp+1:])
+	}
+	return true
+}
+
 // ref: encodeState#string in encoding/json
 func (e *encoder) encodeString(s string) {
 	e.w.WriteByte('"')
@@ -1,9 +1,13 @@
 package gojq_test
 
 import (
+	"bytes"
 	"fmt"
+	"log"
 	"math"
 	"math/big"
+	"reflect"
+	"strconv"
 	"testing"
 
 	"github.com/rturpen/gojq"
@@ -29,6 +33,10 @@ func TestMarshal(t *testing.T) {
 			},
 			expected: "[42,3.14,0.000001,1e-7,-1e-9,1e-10,null,1.7976931348623157e+308,-1.7976931348623157e+308,340282366920938463463374607431768211455]",
 		},
+		{
+			value:    []any{0.0, math.Copysign(0.0, -1)},
+			expected: "[0,0]",
+		},
 		{
 			value:    []any{"", "abcde", "foo\x00\x1f\r\n\t\f\b<=>!\"#$%'& \\\x7fbar"},
 			expected: `["","abcde","foo\u0000\u001f\r\n\t\f\b<=>!\"#$%'& \\\u007fbar"]`,
@@ -54,3 +62,181 @@ func TestMarshal(t *testing.T) {
 		})
 	}
 }
+
+type point struct {
+	x, y int64
+}
+
+func (p point) MarshalJQ() any {
+	return map[string]any{"x": p.x, "y": p.y}
+}
+
+type color uint8
+
+func (c color) MarshalJQ() any {
+	return []string{"red", "green", "blue"}[c]
+}
+
+func ExampleJQMarshaler() {
+	query, err := gojq.Parse(`point, (point | type, tojson), color, (color | type, tostring, tojson), "\(color)"`)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	code, err := gojq.Compile(query,
+		gojq.WithFunction("point", 0, 0, func(any, []any) any {
+			return point{1, 2}
+		}),
+		gojq.WithFunction("color", 0, 0, func(any, []any) any {
+			return color(2)
+		}),
+	)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	iter := code.Run(nil)
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			break
+		}
+		if err, ok := v.(error); ok {
+			log.Fatalln(err)
+		}
+		bs, _ := gojq.Marshal(v)
+		fmt.Printf("%s\n", bs)
+	}
+
+	// Output:
+	// {"x":1,"y":2}
+	// "object"
+	// "{\"x\":1,\"y\":2}"
+	// "blue"
+	// "string"
+	// "blue"
+	// "\"blue\""
+	// "blue"
+}
+
+func TestJQMarshalerValues(t *testing.T) {
+	query, err := gojq.Parse(`point | .x, keys, length, [.[]], . == {x: 1, y: 2}, . == null,
+		. < {x: 1, y: 3}, ([point, null] | sort | .[0]), (color | length, .[1:], . == "blue")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := gojq.Compile(query,
+		gojq.WithFunction("point", 0, 0, func(any, []any) any {
+			return point{1, 2}
+		}),
+		gojq.WithFunction("color", 0, 0, func(any, []any) any {
+			return color(2)
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iter := code.Run(nil)
+	var got []any
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			break
+		}
+		if err, ok := v.(error); ok {
+			t.Fatal(err)
+		}
+		got = append(got, v)
+	}
+	expected := []any{
+		1, []any{"x", "y"}, 2, []any{1, 2}, true, false,
+		true, nil, 4, "lue", true,
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected: %v, got: %v", expected, got)
+	}
+}
+
+func TestEncoderEncodeTo(t *testing.T) {
+	var enc gojq.Encoder
+	var buf bytes.Buffer
+	for _, tc := range []struct {
+		value    any
+		expected string
+	}{
+		{nil, "null"},
+		{[]any{1, "a", math.NaN()}, `[1,"a",null]`},
+		{map[string]any{"b": 2, "a": []any{}}, `{"a":[],"b":2}`},
+	} {
+		buf.Reset()
+		if err := enc.EncodeTo(&buf, tc.value); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != tc.expected {
+			t.Errorf("expected: %s, got: %s", tc.expected, got)
+		}
+	}
+	var v any = []any{1, 2.5, "foo", nil, true, []any{false}}
+	allocs := testing.AllocsPerRun(100, func() {
+		buf.Reset()
+		_ = enc.EncodeTo(&buf, v)
+	})
+	if allocs > 0 {
+		t.Errorf("expected no allocations but got: %v", allocs)
+	}
+}
+
+func TestEncoderSetPrecision(t *testing.T) {
+	var enc gojq.Encoder
+	var buf bytes.Buffer
+	x, y := 0.1, 0.2
+	v := []any{x + y, 1.0 / 3, 2.5e-10, 1e300, math.MaxFloat64, 42}
+	for _, tc := range []struct {
+		prec     int
+		expected string
+	}{
+		{0, "[0.30000000000000004,0.3333333333333333,2.5e-10,1e+300,1.7976931348623157e+308,42]"},
+		{15, "[0.3,0.333333333333333,2.5e-10,1e+300,1.7976931348623157e+308,42]"},
+		{3, "[0.3,0.333,2.5e-10,1e+300,1.7976931348623157e+308,42]"},
+	} {
+		buf.Reset()
+		enc.SetPrecision(tc.prec)
+		if err := enc.EncodeTo(&buf, v); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != tc.expected {
+			t.Errorf("expected: %s, got: %s", tc.expected, got)
+		}
+	}
+	// The numbers are formatted as the rounded numbers with the minimal digits.
+	for _, f := range []float64{
+		0, 1, -1, 0.1, 9.996, 123456789, -0.000123456, 1.5e-7, 9.9999e20,
+		1e21, 1.2345e-300, 12345.678, math.Pi * 1e10, -math.MaxFloat64,
+	} {
+		for prec := 1; prec <= 20; prec++ {
+			x, err := strconv.ParseFloat(strconv.FormatFloat(f, 'g', prec, 64), 64)
+			if err != nil && !math.IsInf(x, 0) {
+				t.Fatal(err)
+			}
+			expected, err := gojq.Marshal(x)
+			if err != nil {
+				t.Fatal(err)
+			}
+			buf.Reset()
+			enc.SetPrecision(prec)
+			if err := enc.EncodeTo(&buf, f); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != string(expected) {
+				t.Errorf("%v with precision %d: expected: %s, got: %s", f, prec, expected, got)
+			}
+		}
+	}
+	enc.SetPrecision(15)
+	var w any = v[:4] // the numbers rounded within the range of float64
+	allocs := testing.AllocsPerRun(100, func() {
+		buf.Reset()
+		_ = enc.EncodeTo(&buf, w)
+	})
+	if allocs > 0 {
+		t.Errorf("expected no allocations but got: %v", allocs)
+	}
+}
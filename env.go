@@ -4,27 +4,59 @@ import "context"
 
 type env struct {
 	pc        int
-	stack     *stack
-	paths     *stack
-	scopes    *scopeStack
+	stack     stack
+	paths     stack
+	scopes    scopeStack
 	values    []any
 	codes     []*code
 	codeinfos []codeinfo
+	sources   []*Query
 	forks     []fork
 	backtrack bool
 	offset    int
 	expdepth  int
 	label     int
+	retindex  int
+	structErr bool
 	args      [32]any // len(env.args) > maxarity
 	ctx       context.Context
+	buf       envBuffer
+}
+
+// envBuffer is the initial storage of the stacks, which is allocated along
+// with the env to run simple queries without growing the slices.
+type envBuffer struct {
+	stack  [16]block
+	scopes [4]scopeBlock
+	forks  [4]fork
+	values [8]any
 }
 
 func newEnv(ctx context.Context) *env {
-	return &env{
-		stack:  newStack(),
-		paths:  newStack(),
-		scopes: newScopeStack(),
-		ctx:    ctx,
+	env := &env{
+		stack:    newStack(),
+		paths:    newStack(),
+		scopes:   newScopeStack(),
+		retindex: -1,
+		ctx:      ctx,
+	}
+	env.stack.data = env.buf.stack[:0]
+	env.scopes.data = env.buf.scopes[:0]
+	env.forks = env.buf.forks[:0]
+	env.values = env.buf.values[:]
+	return env
+}
+
+// Resets the env to run the code again. The values of the previous run are
+// cleared, so that the env does not retain them while reused.
+func (env *env) reset() {
+	env.pc, env.backtrack, env.offset, env.expdepth, env.label = 0, false, 0, 0, 0
+	env.stack.clear()
+	env.paths.clear()
+	env.scopes.restore(-1, -1)
+	env.forks = env.forks[:0]
+	for i := range env.values {
+		env.values[i] = nil
 	}
 }
 
@@ -1,6 +1,10 @@
 package gojq
 
-import "strconv"
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+)
 
 // ValueError is an interface for errors with a value for internal function.
 // Return an error implementing this interface when you want to catch error
@@ -11,6 +15,64 @@ type ValueError interface {
 	Value() any
 }
 
+// InternalError is an interface for errors on unexpected internal states of
+// the execution. The iterator emits this error instead of panicking, so that
+// the caller can continue serving; please report the error with the query.
+type InternalError interface {
+	error
+	// Bytecode returns the program counter and the name of the opcode.
+	Bytecode() (int, string)
+	// Recovered returns the value recovered from the panic.
+	Recovered() any
+}
+
+// HaltError is an interface for errors emitted by halt and halt_error functions,
+// which stop the evaluation with the exit code. Unlike the errors emitted by
+// error function, these errors are not caught by try-catch, and the iterator
+// emits nothing after the error. The value is nil for halt.
+type HaltError interface {
+	ValueError
+	// ExitCode returns the exit code; 0 for halt, and 5 for halt_error unless
+	// specified by the argument.
+	ExitCode() int
+	// IsHaltError returns true, to distinguish from the other value errors.
+	IsHaltError() bool
+}
+
+// InputError is an interface for errors emitted by [Code.RunInputs] with the
+// [WithIsolatedErrors] option. Use [errors.Unwrap] to get the original error.
+type InputError interface {
+	error
+	// Input returns the index (starting from 0) and the value of the input.
+	Input() (int, any)
+}
+
+// QueryError is an interface for errors emitted by the iterator, with the query
+// raising the error. The errors emitted by error, halt and halt_error functions
+// do not implement this interface. Use [errors.Unwrap] to get the original
+// error, or [errors.As] to find the error in the chain.
+type QueryError interface {
+	error
+	// Query returns the innermost query raising the error, which may be in the
+	// definitions of the builtin functions or the modules; see also [Code.Source].
+	Query() *Query
+}
+
+// ParseError is an interface for errors returned by [Parse] and
+// [ParseWithOptions] on malformed queries.
+type ParseError interface {
+	error
+	// Token returns the invalid token and the byte offset in the query string.
+	// The token is empty if the error occurred after scanning the entire query
+	// string. The byte offset is the scanned bytes when the error occurred.
+	Token() (string, int)
+	// TokenKind returns the kind of the invalid token.
+	TokenKind() TokenKind
+	// Position returns the line and column (both starting from 1) of the start
+	// of the invalid token. The column is counted in characters.
+	Position() (int, int)
+}
+
 type expectedObjectError struct {
 	v any
 }
@@ -36,7 +98,7 @@ func (err *iteratorError) Error() string {
 }
 
 type arrayIndexNegativeError struct {
-	v int
+	v any
 }
 
 func (err *arrayIndexNegativeError) Error() string {
@@ -59,6 +121,14 @@ func (err *objectKeyNotStringError) Error() string {
 	return "expected a string for object key but got: " + typeErrorPreview(err.v)
 }
 
+type objectKeyDuplicateError struct {
+	k string
+}
+
+func (err *objectKeyDuplicateError) Error() string {
+	return "duplicate object key: " + strconv.Quote(err.k)
+}
+
 type arrayIndexNotNumberError struct {
 	v any
 }
@@ -96,13 +166,20 @@ func (*inputNotAllowedError) Error() string {
 }
 
 type funcNotFoundError struct {
-	f *Func
+	f      *Func
+	offset int // the offset of the function name plus one, or zero if unknown
 }
 
 func (err *funcNotFoundError) Error() string {
 	return "function not defined: " + err.f.Name + "/" + strconv.Itoa(len(err.f.Args))
 }
 
+// Token returns the function name and the byte offset of the end of the name
+// in the query string, or -1 if unknown (like in the modules).
+func (err *funcNotFoundError) Token() (string, int) {
+	return err.f.Name, tokenEnd(err.f.Name, err.offset)
+}
+
 type func0TypeError struct {
 	name string
 	v    any
@@ -140,6 +217,10 @@ func (err *func0WrapError) Error() string {
 	return err.name + " cannot be applied to " + Preview(err.v) + ": " + err.err.Error()
 }
 
+func (err *func0WrapError) Unwrap() error {
+	return err.err
+}
+
 type func1WrapError struct {
 	name string
 	v, w any
@@ -150,6 +231,10 @@ func (err *func1WrapError) Error() string {
 	return err.name + "(" + Preview(err.w) + ") cannot be applied to " + Preview(err.v) + ": " + err.err.Error()
 }
 
+func (err *func1WrapError) Unwrap() error {
+	return err.err
+}
+
 type func2WrapError struct {
 	name    string
 	v, w, x any
@@ -160,10 +245,13 @@ func (err *func2WrapError) Error() string {
 	return err.name + "(" + Preview(err.w) + "; " + Preview(err.x) + ") cannot be applied to " + Preview(err.v) + ": " + err.err.Error()
 }
 
+func (err *func2WrapError) Unwrap() error {
+	return err.err
+}
+
 type exitCodeError struct {
 	value any
 	code  int
-	halt  bool
 }
 
 func (err *exitCodeError) Error() string {
@@ -185,8 +273,26 @@ func (err *exitCodeError) ExitCode() int {
 	return err.code
 }
 
-func (err *exitCodeError) IsHaltError() bool {
-	return err.halt
+type haltError exitCodeError
+
+func (err *haltError) Error() string {
+	return (*exitCodeError)(err).Error()
+}
+
+func (err *haltError) IsEmptyError() bool {
+	return err.value == nil
+}
+
+func (err *haltError) Value() any {
+	return err.value
+}
+
+func (err *haltError) ExitCode() int {
+	return err.code
+}
+
+func (*haltError) IsHaltError() bool {
+	return true
 }
 
 type flattenDepthError struct {
@@ -262,6 +368,43 @@ func (err *formatRowError) Error() string {
 	return "@" + err.typ + " cannot format an array including: " + typeErrorPreview(err.v)
 }
 
+type cycleError struct {
+	v any
+}
+
+func (err *cycleError) Error() string {
+	return "encountered a cycle in value: " + typeErrorPreview(err.v)
+}
+
+type invalidValueError struct {
+	v    any
+	path []any
+	err  error
+}
+
+func (err *invalidValueError) Error() string {
+	s := "invalid value"
+	if len(err.path) > 0 {
+		s += " at " + jsonMarshal(err.path)
+	}
+	if err.err != nil {
+		return s + ": " + err.err.Error()
+	}
+	return s + ": " + reflect.TypeOf(err.v).String()
+}
+
+func (err *invalidValueError) Unwrap() error {
+	return err.err
+}
+
+type expectedReduceError struct {
+	q *Query
+}
+
+func (err *expectedReduceError) Error() string {
+	return "expected a reduce query but got: " + err.q.String()
+}
+
 type tooManyVariableValuesError struct{}
 
 func (err *tooManyVariableValuesError) Error() string {
@@ -277,13 +420,43 @@ func (err *expectedVariableError) Error() string {
 }
 
 type variableNotFoundError struct {
-	n string
+	n      string
+	f      string // the innermost function definition referencing the variable
+	offset int    // the offset of the variable name plus one, or zero if unknown
 }
 
 func (err *variableNotFoundError) Error() string {
+	if err.f != "" {
+		return "variable not defined: " + err.n + " in function " + err.f
+	}
 	return "variable not defined: " + err.n
 }
 
+// Token returns the variable name and the byte offset of the end of the name
+// in the query string, or -1 if unknown (like in the modules).
+func (err *variableNotFoundError) Token() (string, int) {
+	return err.n, tokenEnd(err.n, err.offset)
+}
+
+func tokenEnd(token string, offset int) int {
+	if offset == 0 {
+		return -1
+	}
+	return offset - 1 + len(token)
+}
+
+// Clears the offset of the error raised in the module, which is not an offset
+// in the source text of the query.
+func clearErrorOffset(err error) error {
+	switch err := err.(type) {
+	case *funcNotFoundError:
+		err.offset = 0
+	case *variableNotFoundError:
+		err.offset = 0
+	}
+	return err
+}
+
 type variableNameError struct {
 	n string
 }
@@ -313,6 +486,10 @@ func (err *tryEndError) Error() string {
 	return err.err.Error()
 }
 
+func (err *tryEndError) Unwrap() error {
+	return err.err
+}
+
 type invalidPathError struct {
 	v any
 }
@@ -342,6 +519,10 @@ func (err *queryParseError) Error() string {
 	return "invalid query: " + err.fname + ": " + err.err.Error()
 }
 
+func (err *queryParseError) Unwrap() error {
+	return err.err
+}
+
 type jsonParseError struct {
 	fname, contents string
 	err             error
@@ -355,6 +536,158 @@ func (err *jsonParseError) Error() string {
 	return "invalid json: " + err.fname + ": " + err.err.Error()
 }
 
+func (err *jsonParseError) Unwrap() error {
+	return err.err
+}
+
+type queryError struct {
+	err   error
+	query *Query
+}
+
+func (err *queryError) Error() string {
+	return err.err.Error()
+}
+
+func (err *queryError) Unwrap() error {
+	return err.err
+}
+
+func (err *queryError) Query() *Query {
+	return err.query
+}
+
+type internalError struct {
+	pc    int
+	op    string
+	query *Query
+	v     any
+}
+
+func (err *internalError) Error() string {
+	if err.query != nil {
+		return fmt.Sprintf("internal error: %v (pc: %d, op: %s, query: %s)", err.v, err.pc, err.op, err.query)
+	}
+	return fmt.Sprintf("internal error: %v (pc: %d, op: %s)", err.v, err.pc, err.op)
+}
+
+func (err *internalError) Bytecode() (int, string) {
+	return err.pc, err.op
+}
+
+func (err *internalError) Recovered() any {
+	return err.v
+}
+
+func (err *internalError) Unwrap() error {
+	if err, ok := err.v.(error); ok {
+		return err
+	}
+	return nil
+}
+
+type inputError struct {
+	index int
+	v     any
+	err   error
+}
+
+func (err *inputError) Error() string {
+	return "input " + strconv.Itoa(err.index) + ": " + err.err.Error()
+}
+
+func (err *inputError) Input() (int, any) {
+	return err.index, err.v
+}
+
+func (err *inputError) Unwrap() error {
+	return err.err
+}
+
+type testParseError struct {
+	line int
+	msg  string
+}
+
+func (err *testParseError) Error() string {
+	return "invalid test at line " + strconv.Itoa(err.line) + ": " + err.msg
+}
+
+type testCaseError struct {
+	line    int
+	program string
+	msg     string
+}
+
+func (err *testCaseError) Error() string {
+	return "test at line " + strconv.Itoa(err.line) + " failed: " + err.program + ": " + err.msg
+}
+
+// Converts an error to an object to be caught by try-catch; see also
+// [WithStructuredErrors]. The path and the offset are nil if unknown.
+func errorToValue(err error, path []any, offset any) map[string]any {
+	if er, ok := err.(*queryError); ok {
+		err = er.err
+	}
+	typ, v := "error", any(nil)
+	switch err := err.(type) {
+	case *expectedObjectError:
+		typ, v = "type_error", err.v
+	case *expectedArrayError:
+		typ, v = "type_error", err.v
+	case *iteratorError:
+		typ, v = "type_error", err.v
+	case *objectKeyNotStringError:
+		typ, v = "type_error", err.v
+	case *func0TypeError:
+		typ, v = "type_error", err.v
+	case *func1TypeError:
+		typ, v = "type_error", err.v
+	case *func2TypeError:
+		typ, v = "type_error", err.v
+	case *joinTypeError:
+		typ, v = "type_error", err.v
+	case *timeArrayError:
+		typ = "type_error"
+	case *unaryTypeError:
+		typ, v = "type_error", err.v
+	case *binopTypeError:
+		typ, v = "type_error", []any{err.l, err.r}
+	case *arrayIndexNegativeError:
+		typ, v = "index_error", err.v
+	case *arrayIndexTooLargeError:
+		typ, v = "index_error", err.v
+	case *arrayIndexNotNumberError:
+		typ, v = "index_error", err.v
+	case *stringIndexNotNumberError:
+		typ, v = "index_error", err.v
+	case *expectedStartEndError:
+		typ, v = "index_error", err.v
+	case *zeroDivisionError:
+		typ, v = "arithmetic_error", []any{err.l, err.r}
+	case *zeroModuloError:
+		typ, v = "arithmetic_error", []any{err.l, err.r}
+	case *invalidPathError:
+		typ, v = "path_error", err.v
+	case *invalidPathIterError:
+		typ, v = "path_error", err.v
+	case *func0WrapError:
+		typ, v = "function_error", err.v
+	case *func1WrapError:
+		typ, v = "function_error", err.v
+	case *func2WrapError:
+		typ, v = "function_error", err.v
+	}
+	var p any
+	if path != nil {
+		p = path
+	}
+	return map[string]any{
+		"message": err.Error(), "type": typ, "value": v,
+		"path": p, "offset": offset,
+	}
+}
+
 func typeErrorPreview(v any) string {
 	switch v.(type) {
 	case nil:
@@ -10,6 +10,8 @@ import (
 func (env *env) execute(bc *Code, v any, vars ...any) Iter {
 	env.codes = bc.codes
 	env.codeinfos = bc.codeinfos
+	env.sources = bc.sources
+	env.structErr = bc.structErrors
 	env.push(v)
 	for i := len(vars) - 1; i >= 0; i-- {
 		env.push(vars[i])
@@ -18,11 +20,22 @@ func (env *env) execute(bc *Code, v any, vars ...any) Iter {
 	return env
 }
 
-func (env *env) Next() (any, bool) {
+func (env *env) Next() (result any, ok bool) {
 	var err error
-	pc, callpc, index := env.pc, len(env.codes)-1, -1
+	pc, callpc, index, errpc := env.pc, len(env.codes)-1, env.scopes.index, -1
+	var errpath []any
 	backtrack, hasCtx := env.backtrack, env.ctx != context.Background()
 	defer func() { env.pc, env.backtrack = pc, true }()
+	defer func() {
+		if r := recover(); r != nil {
+			err := &internalError{pc: pc, v: r}
+			if pc < len(env.codes) {
+				err.op, err.query = env.codes[pc].op.String(), env.sources[pc]
+			}
+			pc, env.forks = len(env.codes), nil
+			result, ok = err, true
+		}
+	}()
 loop:
 	for ; pc < len(env.codes); pc++ {
 		env.debugState(pc, backtrack)
@@ -92,10 +105,9 @@ loop:
 					break loop
 				case *breakError:
 					break loop
+				case *haltError:
+					break loop
 				case ValueError:
-					if er, ok := er.(*exitCodeError); ok && er.halt {
-						break loop
-					}
 					if v := er.Value(); v != nil {
 						env.pop()
 						env.push(v)
@@ -105,9 +117,13 @@ loop:
 					}
 				default:
 					env.pop()
-					env.push(err.Error())
+					if env.structErr {
+						env.push(errorToValue(err, errpath, env.sourceOffset(errpc)))
+					} else {
+						env.push(err.Error())
+					}
 				}
-				pc, backtrack, err = code.v.(int), false, nil
+				pc, backtrack, err, errpc, errpath = code.v.(int), false, nil, -1, nil
 				goto loop
 			}
 			env.pushfork(pc)
@@ -124,7 +140,7 @@ loop:
 				if err == nil {
 					break loop
 				}
-				pc, backtrack, err = code.v.(int), false, nil
+				pc, backtrack, err, errpc, errpath = code.v.(int), false, nil, -1, nil
 				goto loop
 			}
 			env.pushfork(pc)
@@ -189,9 +205,23 @@ loop:
 				for i := 0; i < argcnt; i++ {
 					args[i] = env.pop()
 				}
-				w := v[0].(func(any, []any) any)(x, args)
+				var w any
+				if f, ok := v[0].(filterCallback); ok {
+					s := newFilterEnv(env)
+					for i, arg := range args {
+						xs := arg.([2]int)
+						args[i] = Filter{s, xs[0], xs[1]}
+					}
+					w = f(x, args)
+				} else {
+					w = v[0].(func(any, []any) any)(x, args)
+				}
 				if e, ok := w.(error); ok {
-					if er, ok := e.(*exitCodeError); !ok || er.value != nil || er.halt {
+					if _, ok := e.(*haltError); ok {
+						pc, env.forks = len(env.codes), nil
+						return e, true
+					}
+					if er, ok := e.(*exitCodeError); !ok || er.value != nil {
 						err = e
 					}
 					break loop
@@ -266,7 +296,7 @@ loop:
 				break loop
 			}
 			pc, env.scopes.index = env.popscope()
-			if env.scopes.empty() {
+			if env.scopes.index == env.retindex {
 				return env.pop(), true
 			}
 		case opiter:
@@ -275,7 +305,11 @@ loop:
 			}
 			backtrack = false
 			var xs []pathValue
-			switch v := env.pop().(type) {
+			v := env.pop()
+			if m, ok := v.(JQMarshaler); ok {
+				v = marshalJQ(m)
+			}
+			switch v := v.(type) {
 			case []pathValue:
 				xs = v
 			case []any:
@@ -357,16 +391,46 @@ loop:
 			panic(code.op)
 		}
 	}
+	if err == nil {
+		errpc, errpath = -1, nil
+	} else if errpc < 0 {
+		errpc, errpath = pc, env.currentPath()
+	}
 	if len(env.forks) > 0 {
 		pc, backtrack = env.popfork(), true
 		goto loop
 	}
 	if err != nil {
-		return err, true
+		return env.wrapError(err, errpc), true
 	}
 	return nil, false
 }
 
+// Wraps the error with the query raising the error, except for the value
+// errors (including the halt errors) and the breaks, which the callers inspect.
+func (env *env) wrapError(err error, pc int) error {
+	switch err.(type) {
+	case ValueError, *breakError, *queryError:
+		return err
+	}
+	if pc < 0 || pc >= len(env.sources) || env.sources[pc] == nil {
+		return err
+	}
+	return &queryError{err, env.sources[pc]}
+}
+
+// Returns the offset in the source text of the query at the program counter,
+// or nil if unknown.
+func (env *env) sourceOffset(pc int) any {
+	if pc < 0 || pc >= len(env.sources) || env.sources[pc] == nil {
+		return nil
+	}
+	if offset := env.sources[pc].offset; offset > 0 {
+		return offset - 1
+	}
+	return nil
+}
+
 func (env *env) push(v any) {
 	env.stack.push(v)
 }
@@ -435,6 +499,26 @@ func (env *env) pathIntact(v any) bool {
 	return v == w
 }
 
+// Returns the path of the current value while tracking the paths, without
+// popping the path stack, or nil if the paths are not tracked.
+func (env *env) currentPath() []any {
+	if env.paths.empty() || env.expdepth != 0 {
+		return nil
+	}
+	xs := []any{}
+	for i := env.paths.index; i >= 0; i = env.paths.data[i].next {
+		p, ok := env.paths.data[i].value.(pathValue)
+		if !ok || p.path == nil {
+			break
+		}
+		xs = append(xs, p.path)
+	}
+	for i, j := 0, len(xs)-1; i < j; i, j = i+1, j-1 {
+		xs[i], xs[j] = xs[j], xs[i]
+	}
+	return xs
+}
+
 func (env *env) poppaths() []any {
 	xs := []any{}
 	for {
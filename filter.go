@@ -0,0 +1,53 @@
+package gojq
+
+// Filter is a filter argument of the function added by [WithFilterFunction].
+type Filter struct {
+	env   *env
+	pc    int
+	index int
+}
+
+// Run applies the filter to the value and returns a result iterator. The
+// filter can be applied any number of times, even after the function returns
+// (when the function returns an [Iter] for example), but do not use the
+// iterators concurrently.
+func (f Filter) Run(v any) Iter {
+	env := newEnv(f.env.ctx)
+	env.codes, env.codeinfos, env.sources = f.env.codes, f.env.codeinfos, f.env.sources
+	env.label, env.structErr = f.env.label, f.env.structErr
+	env.pc, env.offset = f.pc, f.env.offset
+	// The snapshot of the scopes and the variables is shared between the runs,
+	// but never modified since the slices are full and grow on write.
+	env.scopes.data = f.env.scopes.data
+	env.scopes.index, env.scopes.limit = f.index, len(env.scopes.data)-1
+	env.retindex = f.index
+	env.values = f.env.values
+	env.push(v)
+	return env
+}
+
+// The callback of the functions added by WithFilterFunction, which takes
+// the closures of the arguments instead of the evaluated values.
+type filterCallback func(any, []any) any
+
+// Captures the scopes and the variables on calling the function, so that the
+// filters run in the context of the caller.
+func newFilterEnv(parent *env) *env {
+	n := parent.scopes.index + 1
+	if n <= parent.scopes.limit {
+		n = parent.scopes.limit + 1
+	}
+	scopes := append([]scopeBlock{}, parent.scopes.data[:n]...)
+	values := append([]any{}, parent.values[:parent.offset]...)
+	return &env{
+		codes:     parent.codes,
+		codeinfos: parent.codeinfos,
+		sources:   parent.sources,
+		scopes:    scopeStack{data: scopes[:n:n]},
+		values:    values[:parent.offset:parent.offset],
+		offset:    parent.offset,
+		label:     parent.label,
+		structErr: parent.structErr,
+		ctx:       parent.ctx,
+	}
+}
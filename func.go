@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"math"
 	"math/big"
 	"net/url"
@@ -14,7 +13,9 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
 	"unicode/utf8"
 
 	"github.com/itchyny/timefmt-go"
@@ -49,8 +50,13 @@ func init() {
 		"env":            argFunc0(nil),
 		"builtins":       argFunc0(nil),
 		"input":          argFunc0(nil),
+		"peek_input":     {argcount0, true, nil},
 		"modulemeta":     argFunc0(nil),
+		"debug":          argFunc0(nil),
+		"stderr":         argFunc0(nil),
+		"fromstream":     argFunc1(nil),
 		"abs":            argFunc0(funcAbs),
+		"toarray":        argFunc0(funcToArray),
 		"length":         argFunc0(funcLength),
 		"utf8bytelength": argFunc0(funcUtf8ByteLength),
 		"keys":           argFunc0(funcKeys),
@@ -70,8 +76,12 @@ func init() {
 		"endswith":       argFunc1(funcEndsWith),
 		"ltrimstr":       argFunc1(funcLtrimstr),
 		"rtrimstr":       argFunc1(funcRtrimstr),
+		"trim":           argFunc0(funcTrim),
+		"ltrim":          argFunc0(funcLtrim),
+		"rtrim":          argFunc0(funcRtrim),
 		"explode":        argFunc0(funcExplode),
 		"implode":        argFunc0(funcImplode),
+		"ascii":          argFunc0(funcASCII),
 		"split":          {argcount1 | argcount2, false, funcSplit},
 		"ascii_downcase": argFunc0(funcASCIIDowncase),
 		"ascii_upcase":   argFunc0(funcASCIIUpcase),
@@ -150,6 +160,7 @@ func init() {
 		"gamma":          mathFunc("gamma", math.Gamma),
 		"tgamma":         mathFunc("tgamma", math.Gamma),
 		"lgamma":         mathFunc("lgamma", funcLgamma),
+		"lgamma_r":       argFunc0(funcLgammaR),
 		"erf":            mathFunc("erf", math.Erf),
 		"erfc":           mathFunc("erfc", math.Erfc),
 		"j0":             mathFunc("j0", math.J0),
@@ -280,6 +291,9 @@ func funcAbs(v any) any {
 	case int:
 		if v >= 0 {
 			return v
+		} else if v == math.MinInt {
+			x := big.NewInt(int64(v))
+			return x.Neg(x)
 		}
 		return -v
 	case float64:
@@ -294,6 +308,13 @@ func funcAbs(v any) any {
 	}
 }
 
+func funcToArray(v any) any {
+	if v, ok := v.([]any); ok {
+		return v
+	}
+	return []any{v}
+}
+
 func funcLength(v any) any {
 	switch v := v.(type) {
 	case nil:
@@ -301,6 +322,9 @@ func funcLength(v any) any {
 	case int:
 		if v >= 0 {
 			return v
+		} else if v == math.MinInt {
+			x := big.NewInt(int64(v))
+			return x.Neg(x)
 		}
 		return -v
 	case float64:
@@ -316,6 +340,8 @@ func funcLength(v any) any {
 		return len(v)
 	case map[string]any:
 		return len(v)
+	case JQMarshaler:
+		return funcLength(marshalJQ(v))
 	default:
 		return &func0TypeError{"length", v}
 	}
@@ -343,6 +369,8 @@ func funcKeys(v any) any {
 			w[i] = k
 		}
 		return w
+	case JQMarshaler:
+		return funcKeys(marshalJQ(v))
 	default:
 		return &func0TypeError{"keys", v}
 	}
@@ -424,18 +452,24 @@ func funcFromEntries(v any) any {
 				value any
 				ok    bool
 			)
-			for _, k := range [4]string{"key", "Key", "name", "Name"} {
+			for _, k := range [6]string{"key", "k", "name", "Name", "K", "Key"} {
 				if k := v[k]; k != nil && k != false {
-					if key, ok = k.(string); !ok {
+					switch k := k.(type) {
+					case string:
+						key = k
+					case int, float64, *big.Int, bool:
+						key = jsonMarshal(k)
+					default:
 						return &func0WrapError{"from_entries", vs, &objectKeyNotStringError{k}}
 					}
+					ok = true
 					break
 				}
 			}
 			if !ok {
 				return &func0WrapError{"from_entries", vs, &objectKeyNotStringError{nil}}
 			}
-			for _, k := range [2]string{"value", "Value"} {
+			for _, k := range [3]string{"value", "v", "Value"} {
 				if value, ok = v[k]; ok {
 					break
 				}
@@ -529,6 +563,9 @@ func toNumber(v string) any {
 }
 
 func funcToString(v any) any {
+	if m, ok := v.(JQMarshaler); ok {
+		v = marshalJQ(m)
+	}
 	if s, ok := v.(string); ok {
 		return s
 	}
@@ -540,15 +577,24 @@ func funcType(v any) any {
 }
 
 func funcReverse(v any) any {
-	vs, ok := v.([]any)
-	if !ok {
+	switch v := v.(type) {
+	case nil:
+		return []any{}
+	case []any:
+		ws := make([]any, len(v))
+		for i, v := range v {
+			ws[len(ws)-i-1] = v
+		}
+		return ws
+	case string:
+		rs := []rune(v)
+		for i, j := 0, len(rs)-1; i < j; i, j = i+1, j-1 {
+			rs[i], rs[j] = rs[j], rs[i]
+		}
+		return string(rs)
+	default:
 		return &func0TypeError{"reverse", v}
 	}
-	ws := make([]any, len(vs))
-	for i, v := range vs {
-		ws[len(ws)-i-1] = v
-	}
-	return ws
 }
 
 func funcContains(v, x any) any {
@@ -703,6 +749,30 @@ func funcRtrimstr(v, x any) any {
 	return strings.TrimSuffix(s, t)
 }
 
+func funcTrim(v any) any {
+	s, ok := v.(string)
+	if !ok {
+		return &func0TypeError{"trim", v}
+	}
+	return strings.TrimSpace(s)
+}
+
+func funcLtrim(v any) any {
+	s, ok := v.(string)
+	if !ok {
+		return &func0TypeError{"ltrim", v}
+	}
+	return strings.TrimLeftFunc(s, unicode.IsSpace)
+}
+
+func funcRtrim(v any) any {
+	s, ok := v.(string)
+	if !ok {
+		return &func0TypeError{"rtrim", v}
+	}
+	return strings.TrimRightFunc(s, unicode.IsSpace)
+}
+
 func funcExplode(v any) any {
 	s, ok := v.(string)
 	if !ok {
@@ -729,19 +799,25 @@ func funcImplode(v any) any {
 	var sb strings.Builder
 	sb.Grow(len(vs))
 	for _, v := range vs {
-		if r, ok := toInt(v); ok {
-			if 0 <= r && r <= utf8.MaxRune {
-				sb.WriteRune(rune(r))
-			} else {
-				sb.WriteRune(utf8.RuneError)
-			}
-		} else {
+		r, ok := toInt(v)
+		if !ok {
 			return &func0TypeError{"implode", vs}
 		}
+		if !utf8.ValidRune(rune(r)) || int(rune(r)) != r {
+			return &func0WrapError{"implode", vs, errors.New("invalid code point: " + Preview(v))}
+		}
+		sb.WriteRune(rune(r))
 	}
 	return sb.String()
 }
 
+func funcASCII(v any) any {
+	if r, ok := toInt(v); ok && 0 <= r && r < utf8.RuneSelf {
+		return string(rune(r))
+	}
+	return &func0TypeError{"ascii", v}
+}
+
 func funcSplit(v any, args []any) any {
 	s, ok := v.(string)
 	if !ok {
@@ -749,10 +825,13 @@ func funcSplit(v any, args []any) any {
 	}
 	x, ok := args[0].(string)
 	if !ok {
-		return &func0TypeError{"split", x}
+		return &func0TypeError{"split", args[0]}
 	}
 	var ss []string
 	if len(args) == 1 {
+		if s == "" {
+			return []any{}
+		}
 		ss = strings.Split(s, x)
 	} else {
 		var flags string
@@ -767,7 +846,17 @@ func funcSplit(v any, args []any) any {
 		if err != nil {
 			return err
 		}
-		ss = r.Split(s, -1)
+		if strings.ContainsRune(flags, 'n') {
+			var i int
+			for _, x := range r.FindAllStringIndex(s, -1) {
+				if x[0] < x[1] {
+					ss, i = append(ss, s[i:x[0]]), x[1]
+				}
+			}
+			ss = append(ss, s[i:])
+		} else {
+			ss = r.Split(s, -1)
+		}
 	}
 	xs := make([]any, len(ss))
 	for i, s := range ss {
@@ -811,15 +900,10 @@ func funcFromJSON(v any) any {
 	if !ok {
 		return &func0TypeError{"fromjson", v}
 	}
-	var w any
-	dec := json.NewDecoder(strings.NewReader(s))
-	dec.UseNumber()
-	if err := dec.Decode(&w); err != nil {
+	w, err := decodeRawMessage(json.RawMessage(s))
+	if err != nil {
 		return &func0WrapError{"fromjson", v, err}
 	}
-	if _, err := dec.Token(); err != io.EOF {
-		return &func0TypeError{"fromjson", v}
-	}
 	return normalizeNumbers(w)
 }
 
@@ -856,7 +940,9 @@ func funcToHTML(v any) any {
 func funcToURI(v any) any {
 	switch x := funcToString(v).(type) {
 	case string:
-		return url.QueryEscape(x)
+		// url.QueryEscape escapes a space to a plus sign, but we prefer
+		// percent-encoding for consistency with jq.
+		return strings.ReplaceAll(url.QueryEscape(x), "+", "%20")
 	default:
 		return x
 	}
@@ -904,7 +990,11 @@ var shEscaper = strings.NewReplacer(
 )
 
 func funcToSh(v any) any {
-	if _, ok := v.([]any); !ok {
+	switch v.(type) {
+	case []any:
+	case map[string]any:
+		return &func0TypeError{"@sh", v}
+	default:
 		v = []any{v}
 	}
 	return formatJoin("sh", v, " ", func(s string) string {
@@ -959,6 +1049,9 @@ func funcToBase64d(v any) any {
 }
 
 func funcIndex2(_, v, x any) any {
+	if m, ok := v.(JQMarshaler); ok {
+		v = marshalJQ(m)
+	}
 	switch x := x.(type) {
 	case string:
 		switch v := v.(type) {
@@ -1138,7 +1231,7 @@ func funcFlatten(v any, args []any) any {
 		if !ok {
 			return &func0TypeError{"flatten", args[0]}
 		}
-		if depth < 0 {
+		if !(depth >= 0) {
 			return &flattenDepthError{depth}
 		}
 	}
@@ -1395,6 +1488,15 @@ func funcLgamma(v float64) float64 {
 	return v
 }
 
+func funcLgammaR(v any) any {
+	x, ok := toFloat(v)
+	if !ok {
+		return &func0TypeError{"lgamma_r", v}
+	}
+	y, s := math.Lgamma(x)
+	return []any{y, s}
+}
+
 func funcDrem(l, r float64) float64 {
 	x := math.Remainder(l, r)
 	if x == 0.0 {
@@ -1572,9 +1674,9 @@ func update(v any, path []any, n any, a allocator) (any, error) {
 		i, _ := toInt(p)
 		switch v := v.(type) {
 		case nil:
-			return updateArrayIndex(nil, i, path[1:], n, a)
+			return updateArrayIndex(nil, i, p, path[1:], n, a)
 		case []any:
-			return updateArrayIndex(v, i, path[1:], n, a)
+			return updateArrayIndex(v, i, p, path[1:], n, a)
 		case struct{}:
 			return v, nil
 		default:
@@ -1622,13 +1724,14 @@ func updateObject(v map[string]any, k string, path []any, n any, a allocator) (a
 	return w, nil
 }
 
-func updateArrayIndex(v []any, i int, path []any, n any, a allocator) (any, error) {
+// The index i is converted from p, which is reported on errors as it is.
+func updateArrayIndex(v []any, i int, p any, path []any, n any, a allocator) (any, error) {
 	var x any
 	if j := clampIndex(i, -1, len(v)); j < 0 {
 		if n == struct{}{} {
 			return v, nil
 		}
-		return nil, &arrayIndexNegativeError{i}
+		return nil, &arrayIndexNegativeError{p}
 	} else if j < len(v) {
 		i = j
 		x = v[i]
@@ -1637,7 +1740,7 @@ func updateArrayIndex(v []any, i int, path []any, n any, a allocator) (any, erro
 			return v, nil
 		}
 		if i >= 0x8000000 {
-			return nil, &arrayIndexTooLargeError{i}
+			return nil, &arrayIndexTooLargeError{p}
 		}
 	}
 	u, err := update(x, path, n, a)
@@ -1768,6 +1871,63 @@ func funcGetpath(v, p any) any {
 	return v
 }
 
+// Implements fromstream(f) natively, since the definition in jq updates the
+// value by setpath on each event, which copies the value being constructed.
+// The containers allocated for the value are updated in place until emitted.
+func funcFromstream(v any, xs []any) any {
+	return &fromstreamIter{iter: xs[0].(Filter).Run(v)}
+}
+
+type fromstreamIter struct {
+	iter Iter
+	x    any
+	a    allocator
+	err  bool
+}
+
+func (iter *fromstreamIter) Next() (any, bool) {
+	for !iter.err {
+		v, ok := iter.iter.Next()
+		if !ok {
+			break
+		}
+		if err, ok := v.(error); ok {
+			iter.err = true
+			return err, true
+		}
+		var path []any
+		e, ok := v.([]any)
+		if ok = ok && (len(e) == 1 || len(e) == 2); ok {
+			path, ok = e[0].([]any)
+		}
+		if !ok {
+			iter.err = true
+			return &func0TypeError{"fromstream", v}, true
+		}
+		if len(e) == 1 {
+			if len(path) != 1 {
+				continue
+			}
+		} else {
+			if iter.a == nil {
+				iter.a = allocator{}
+			}
+			x, err := update(iter.x, path, e[1], iter.a)
+			if err != nil {
+				iter.err = true
+				return &func0WrapError{"fromstream", v, err}, true
+			}
+			if iter.x = x; len(path) != 0 {
+				continue
+			}
+		}
+		x := iter.x
+		iter.x, iter.a = nil, nil
+		return x, true
+	}
+	return nil, false
+}
+
 func funcTranspose(v any) any {
 	vss, ok := v.([]any)
 	if !ok {
@@ -1986,8 +2146,23 @@ func funcMatch(v, re, fs, testing any) any {
 		return err
 	}
 	var xs [][]int
-	if strings.ContainsRune(flags, 'g') && testing != true {
+	if global, nonempty := strings.ContainsRune(flags, 'g'),
+		strings.ContainsRune(flags, 'n'); global && testing != true || nonempty {
 		xs = r.FindAllStringSubmatchIndex(s, -1)
+		if nonempty {
+			ys := xs[:0]
+			for _, x := range xs {
+				if x[0] < x[1] {
+					ys = append(ys, x)
+				}
+			}
+			if xs = ys; !global && len(xs) > 1 {
+				xs = xs[:1]
+			}
+		}
+		if testing == true {
+			return len(xs) > 0
+		}
 	} else {
 		got := r.FindStringSubmatchIndex(s)
 		if testing == true {
@@ -2031,26 +2206,136 @@ func funcMatch(v, re, fs, testing any) any {
 	return res
 }
 
+// Regular expressions are usually constant in the query but matched against
+// each input, so the compiled ones are cached. The cache is reset when it
+// grows too large, to keep the memory bounded for dynamic patterns.
+const regexpCacheSize = 256
+
+var regexpCache struct {
+	sync.Mutex
+	m map[[2]string]*regexp.Regexp
+}
+
 func compileRegexp(re, flags string) (*regexp.Regexp, error) {
+	key := [2]string{re, flags}
+	regexpCache.Lock()
+	r, ok := regexpCache.m[key]
+	regexpCache.Unlock()
+	if ok {
+		return r, nil
+	}
+	r, err := compileRegexpUncached(re, flags)
+	if err != nil {
+		return nil, err
+	}
+	regexpCache.Lock()
+	if len(regexpCache.m) >= regexpCacheSize || regexpCache.m == nil {
+		regexpCache.m = make(map[[2]string]*regexp.Regexp)
+	}
+	regexpCache.m[key] = r
+	regexpCache.Unlock()
+	return r, nil
+}
+
+// Compiles the regular expression with the flags of Oniguruma, which jq uses.
+// The flags g (global search) and n (ignore empty matches) are handled by the
+// callers. Note that s (single line mode) is the default behavior of RE2.
+func compileRegexpUncached(re, flags string) (*regexp.Regexp, error) {
 	if strings.IndexFunc(flags, func(r rune) bool {
-		return r != 'g' && r != 'i' && r != 'm'
+		return !strings.ContainsRune("gimnpslx", r)
 	}) >= 0 {
 		return nil, fmt.Errorf("unsupported regular expression flag: %q", flags)
 	}
-	re = strings.ReplaceAll(re, "(?<", "(?P<")
+	src, err := translateRegexp(re, strings.ContainsRune(flags, 'x'))
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression %q: %w", re, err)
+	}
 	if strings.ContainsRune(flags, 'i') {
-		re = "(?i)" + re
+		src = "(?i)" + src
 	}
-	if strings.ContainsRune(flags, 'm') {
-		re = "(?s)" + re
+	if strings.ContainsAny(flags, "mp") {
+		src = "(?s)" + src
 	}
-	r, err := regexp.Compile(re)
+	r, err := regexp.Compile(src)
 	if err != nil {
-		return nil, fmt.Errorf("invalid regular expression %q: %s", re, err)
+		return nil, fmt.Errorf("invalid regular expression %q: %w", re, err)
+	}
+	if strings.ContainsRune(flags, 'l') {
+		r.Longest()
 	}
 	return r, nil
 }
 
+// Translates the regular expression of Oniguruma to RE2 syntax. This converts
+// the named groups, removes the whitespaces and comments in extended mode, and
+// reports the constructs which RE2 does not support.
+func translateRegexp(re string, extended bool) (string, error) {
+	var sb strings.Builder
+	var class bool
+	for i := 0; i < len(re); i++ {
+		c := re[i]
+		switch {
+		case c == '\\':
+			if i++; i == len(re) {
+				sb.WriteByte(c)
+				break
+			}
+			if d := re[i]; !class && ('1' <= d && d <= '9' ||
+				d == 'k' && strings.HasPrefix(re[i+1:], "<")) {
+				return "", errors.New("backreference is not supported")
+			} else if d == ' ' {
+				sb.WriteByte(d)
+			} else {
+				sb.WriteByte(c)
+				sb.WriteByte(d)
+			}
+		case class:
+			if c == '[' && strings.HasPrefix(re[i+1:], ":") {
+				if j := strings.Index(re[i:], ":]"); j > 0 {
+					sb.WriteString(re[i : i+j+2])
+					i += j + 1
+					break
+				}
+			}
+			class = c != ']'
+			sb.WriteByte(c)
+		case c == '[':
+			class = true
+			sb.WriteByte(c)
+			if strings.HasPrefix(re[i+1:], "^") {
+				sb.WriteByte('^')
+				i++
+			}
+			if strings.HasPrefix(re[i+1:], "]") {
+				sb.WriteByte(']')
+				i++
+			}
+		case c == '(' && strings.HasPrefix(re[i+1:], "?"):
+			switch s := re[i+2:]; {
+			case strings.HasPrefix(s, "="), strings.HasPrefix(s, "!"):
+				return "", errors.New("lookahead is not supported")
+			case strings.HasPrefix(s, "<="), strings.HasPrefix(s, "<!"):
+				return "", errors.New("lookbehind is not supported")
+			case strings.HasPrefix(s, ">"):
+				return "", errors.New("atomic group is not supported")
+			case strings.HasPrefix(s, "<"):
+				sb.WriteString("(?P")
+				i++
+			default:
+				sb.WriteByte(c)
+			}
+		case extended && strings.IndexByte(" \t\n\v\f\r", c) >= 0:
+		case extended && c == '#':
+			for i < len(re) && re[i] != '\n' {
+				i++
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String(), nil
+}
+
 func funcCapture(v any) any {
 	vs, ok := v.(map[string]any)
 	if !ok {
@@ -2080,11 +2365,11 @@ func funcError(v any, args []any) any {
 	if v == nil {
 		code = 0
 	}
-	return &exitCodeError{v, code, false}
+	return &exitCodeError{v, code}
 }
 
 func funcHalt(any) any {
-	return &exitCodeError{nil, 0, true}
+	return &haltError{nil, 0}
 }
 
 func funcHaltError(v any, args []any) any {
@@ -2095,7 +2380,7 @@ func funcHaltError(v any, args []any) any {
 			return &func0TypeError{"halt_error", args[0]}
 		}
 	}
-	return &exitCodeError{v, code, true}
+	return &haltError{v, code}
 }
 
 func toInt(x any) (int, bool) {
@@ -0,0 +1,140 @@
+// Package lint implements the static analysis of the queries shared by the
+// tool commands and the language server.
+package lint
+
+import (
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rturpen/gojq"
+)
+
+// Problem is a problem of a query reported by [Lint].
+type Problem struct {
+	// Code is the kind of the problem; unused-function or unused-variable.
+	Code string
+	// Message is the description of the problem.
+	Message string
+	// Start and End are the byte offsets of the definition in the source, or
+	// -1 if the definition is not found.
+	Start, End int
+}
+
+// Lint reports the function definitions and the variable bindings which are
+// never referenced, in the order of the offsets. The references are matched by
+// the names regardless of the scope, so this reports no false positives but may
+// miss some of the unused ones. The query should be parsed from the source.
+func Lint(src string, q *gojq.Query) []*Problem {
+	l := linter{params: map[string]bool{}, calls: map[string]int{}, vars: map[string]int{}}
+	l.walk(reflect.ValueOf(q))
+	// The top-level functions of a module are used by the importers. The body
+	// of a module (or the query of only function definitions) is the identity.
+	module := q.Meta != nil || q.Left == nil && q.Term != nil &&
+		q.Term.Type == gojq.TermTypeIdentity && len(q.Term.SuffixList) == 0
+	var ps []*Problem
+	for i, fd := range l.funcDefs {
+		if module && i < len(q.FuncDefs) {
+			continue
+		}
+		if name := fd.Name + "/" + strconv.Itoa(len(fd.Args)); l.calls[name] == 0 {
+			start, end := FindFuncDef(src, fd.Name)
+			ps = append(ps, &Problem{
+				"unused-function", "function " + name + " is defined but not used", start, end,
+			})
+		}
+	}
+	for _, name := range l.bindings {
+		if l.vars[name] == 0 && (!l.params[name] || l.calls[name[1:]+"/0"] == 0) {
+			start, end := find(src, regexp.QuoteMeta(name)+`\b`)
+			ps = append(ps, &Problem{
+				"unused-variable", "variable " + name + " is bound but not used", start, end,
+			})
+		}
+	}
+	sort.SliceStable(ps, func(i, j int) bool {
+		return ps[i].Start < ps[j].Start
+	})
+	return ps
+}
+
+// FindFuncDef returns the byte offsets of the first definition of the function
+// (from def to the name) in the source, or -1 if not found.
+func FindFuncDef(src, name string) (int, int) {
+	return find(src, `\bdef\s+`+regexp.QuoteMeta(name)+`\b`)
+}
+
+func find(src, pattern string) (int, int) {
+	if loc := regexp.MustCompile(pattern).FindStringIndex(src); loc != nil {
+		return loc[0], loc[1]
+	}
+	return -1, -1
+}
+
+type linter struct {
+	funcDefs []*gojq.FuncDef
+	bindings []string
+	params   map[string]bool
+	calls    map[string]int
+	vars     map[string]int
+}
+
+func (l *linter) walk(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return
+		}
+		switch x := v.Interface().(type) {
+		case *gojq.Query:
+			l.funcDefs = append(l.funcDefs, x.FuncDefs...)
+		case *gojq.FuncDef:
+			for _, arg := range x.Args {
+				if arg[0] == '$' {
+					l.bind(arg)
+					l.params[arg] = true
+				}
+			}
+		case *gojq.Func:
+			if x.Name[0] == '$' {
+				l.vars[x.Name]++
+			} else {
+				l.calls[x.Name+"/"+strconv.Itoa(len(x.Args))]++
+			}
+		case *gojq.Pattern:
+			if x.Name != "" {
+				l.bind(x.Name)
+			}
+		case *gojq.PatternObject:
+			if strings.HasPrefix(x.Key, "$") {
+				l.bind(x.Key)
+			}
+		case *gojq.ObjectKeyVal:
+			if strings.HasPrefix(x.Key, "$") {
+				l.vars[x.Key]++
+			}
+		}
+		l.walk(v.Elem())
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				l.walk(v.Field(i))
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			l.walk(v.Index(i))
+		}
+	}
+}
+
+func (l *linter) bind(name string) {
+	for _, n := range l.bindings {
+		if n == name {
+			return
+		}
+	}
+	l.bindings = append(l.bindings, name)
+}
@@ -0,0 +1,85 @@
+package lint_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/rturpen/gojq"
+	"github.com/rturpen/gojq/internal/lint"
+)
+
+func TestLint(t *testing.T) {
+	testCases := []struct {
+		src      string
+		expected []*lint.Problem
+	}{
+		{
+			src: "def f: 1; def g: f; 2",
+			expected: []*lint.Problem{
+				{"unused-function", "function g/0 is defined but not used", 10, 15},
+			},
+		},
+		{
+			src: "def f(x): x; def f: 1; f(2)",
+			expected: []*lint.Problem{
+				{"unused-function", "function f/0 is defined but not used", 0, 5},
+			},
+		},
+		{
+			src: ". as [$a, {b: $b, $c}] | $a + $c",
+			expected: []*lint.Problem{
+				{"unused-variable", "variable $b is bound but not used", 14, 16},
+			},
+		},
+		{
+			src: "def f($x; $y): x; f(1; 2) | reduce .[] as $z (0; .) | {$z}",
+			expected: []*lint.Problem{
+				{"unused-variable", "variable $y is bound but not used", 10, 12},
+			},
+		},
+		{
+			src:      "def f: 1; def g: 2;",
+			expected: nil,
+		},
+		{
+			src:      `module {}; def f: g; def g: 1;`,
+			expected: nil,
+		},
+		{
+			src: "def f: def g: 1; 2; f",
+			expected: []*lint.Problem{
+				{"unused-function", "function g/0 is defined but not used", 7, 12},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.src, func(t *testing.T) {
+			q, err := gojq.Parse(tc.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := lint.Lint(tc.src, q); !cmp.Equal(got, tc.expected) {
+				t.Errorf("%s", cmp.Diff(tc.expected, got))
+			}
+		})
+	}
+}
+
+func TestFindFuncDef(t *testing.T) {
+	testCases := []struct {
+		src, name  string
+		start, end int
+	}{
+		{"def f: 1; def fg: 2;", "fg", 10, 16},
+		{"def  f: 1;", "f", 0, 6},
+		{"def ff: 1;", "f", -1, -1},
+		{"[f]", "f", -1, -1},
+	}
+	for _, tc := range testCases {
+		if start, end := lint.FindFuncDef(tc.src, tc.name); start != tc.start || end != tc.end {
+			t.Errorf("FindFuncDef(%q, %q): expected: (%d, %d), got: (%d, %d)",
+				tc.src, tc.name, tc.start, tc.end, start, end)
+		}
+	}
+}
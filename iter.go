@@ -37,6 +37,45 @@ func (iter *unitIter) Next() (any, bool) {
 	return iter.value, true
 }
 
+type inputPeeker interface {
+	Iter
+	Peek() (any, bool)
+}
+
+// NewPeekIter creates a new [Iter] which implements Peek() (any, bool) method
+// to read the next value ahead. When the inputs given by [WithInputIter] are
+// also fed to [Code.Run], use the returned iterator for both, so that the value
+// read ahead by peek_input function is not lost.
+func NewPeekIter(iter Iter) Iter {
+	if _, ok := iter.(inputPeeker); ok {
+		return iter
+	}
+	return &peekIter{iter: iter}
+}
+
+type peekIter struct {
+	iter   Iter
+	value  any
+	ok     bool
+	peeked bool
+}
+
+func (iter *peekIter) Next() (any, bool) {
+	if iter.peeked {
+		iter.peeked = false
+		return iter.value, iter.ok
+	}
+	return iter.iter.Next()
+}
+
+func (iter *peekIter) Peek() (any, bool) {
+	if !iter.peeked {
+		iter.value, iter.ok = iter.iter.Next()
+		iter.peeked = true
+	}
+	return iter.value, iter.ok
+}
+
 type sliceIter []any
 
 func (iter *sliceIter) Next() (any, bool) {
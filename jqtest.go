@@ -0,0 +1,151 @@
+package gojq
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+)
+
+// TestCase is a test case of the test file format of jq, parsed by
+// [ParseTests].
+type TestCase struct {
+	// The line number of the program in the test file.
+	Line int
+	// The program under test.
+	Program string
+	// The input value in JSON. This is empty if Fail is true.
+	Input string
+	// The expected output values in JSON.
+	Expected []string
+	// Whether the program is expected to fail on compilation (%%FAIL).
+	Fail bool
+	// The expected error message of the failing program. Since error messages
+	// of gojq differ from jq, this is not compared on running the test.
+	Error string
+}
+
+// ParseTests parses the test file format of jq. Each test case consists of a
+// program, an input, and the expected outputs on each line, which continue
+// until a blank line. A test case starting with %%FAIL line consists of a
+// program failing on compilation, and the expected error message. Lines
+// starting with # between the test cases are comments.
+func ParseTests(r io.Reader) ([]*TestCase, error) {
+	var tcs []*TestCase
+	var tc *TestCase
+	var line int
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 1024*1024*1024)
+	for s.Scan() {
+		line++
+		l := strings.TrimSuffix(s.Text(), "\r")
+		if strings.TrimSpace(l) == "" {
+			if tc != nil {
+				if tc.Program == "" || !tc.Fail && tc.Input == "" {
+					return nil, &testParseError{tc.Line, "incomplete test case"}
+				}
+				tcs, tc = append(tcs, tc), nil
+			}
+			continue
+		}
+		if tc == nil {
+			if strings.HasPrefix(l, "#") {
+				continue
+			}
+			tc = &TestCase{}
+			if strings.HasPrefix(l, "%%FAIL") {
+				tc.Fail = true
+				continue
+			}
+		}
+		switch {
+		case tc.Program == "":
+			tc.Line, tc.Program = line, l
+		case tc.Fail:
+			if tc.Error != "" {
+				tc.Error += "\n"
+			}
+			tc.Error += l
+		case tc.Input == "":
+			tc.Input = l
+		default:
+			tc.Expected = append(tc.Expected, l)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if tc != nil {
+		if tc.Program == "" || !tc.Fail && tc.Input == "" {
+			return nil, &testParseError{tc.Line, "incomplete test case"}
+		}
+		tcs = append(tcs, tc)
+	}
+	return tcs, nil
+}
+
+// Run runs the test case with the compiler options, and returns an error if
+// the outputs of the program differ from the expected values.
+func (tc *TestCase) Run(options ...CompilerOption) error {
+	q, err := Parse(tc.Program)
+	if err == nil {
+		var code *Code
+		if code, err = Compile(q, options...); err == nil && !tc.Fail {
+			return tc.run(code)
+		}
+	}
+	if tc.Fail {
+		if err == nil {
+			return &testCaseError{tc.Line, tc.Program, "expected an error but compiled successfully"}
+		}
+		return nil
+	}
+	return &testCaseError{tc.Line, tc.Program, err.Error()}
+}
+
+func (tc *TestCase) run(code *Code) error {
+	v, err := parseTestJSON(tc.Input)
+	if err != nil {
+		return &testCaseError{tc.Line, tc.Program, "invalid input: " + err.Error()}
+	}
+	iter := code.Run(v)
+	for i := 0; ; i++ {
+		v, ok := iter.Next()
+		if !ok {
+			if i < len(tc.Expected) {
+				return &testCaseError{tc.Line, tc.Program,
+					"expected " + tc.Expected[i] + " but got no more output"}
+			}
+			return nil
+		}
+		if err, ok := v.(error); ok {
+			return &testCaseError{tc.Line, tc.Program, err.Error()}
+		}
+		if i >= len(tc.Expected) {
+			return &testCaseError{tc.Line, tc.Program,
+				"expected no more output but got " + jsonMarshal(v)}
+		}
+		w, err := parseTestJSON(tc.Expected[i])
+		if err != nil {
+			return &testCaseError{tc.Line, tc.Program, "invalid expected value: " + err.Error()}
+		}
+		if compare(v, w) != 0 {
+			return &testCaseError{tc.Line, tc.Program,
+				"expected " + jsonMarshal(w) + " but got " + jsonMarshal(v)}
+		}
+	}
+}
+
+func parseTestJSON(s string) (any, error) {
+	var v any
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("invalid character after top-level value")
+	}
+	return normalizeNumbers(v), nil
+}
@@ -0,0 +1,116 @@
+package gojq_test
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rturpen/gojq"
+)
+
+func ExampleParseTests() {
+	tcs, err := gojq.ParseTests(strings.NewReader(`# Tests of f.
+def f: . * 2; f
+3
+6
+
+def f: .[]; f
+[1, 2]
+1
+3
+
+%%FAIL
+def f: .; g
+jq: error: g/0 is not defined
+`))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	for _, tc := range tcs {
+		if err := tc.Run(); err != nil {
+			fmt.Println(err)
+			continue
+		}
+		fmt.Printf("test at line %d passed\n", tc.Line)
+	}
+
+	// Output:
+	// test at line 2 passed
+	// test at line 6 failed: def f: .[]; f: expected 3 but got 2
+	// test at line 12 passed
+}
+
+func TestParseTests(t *testing.T) {
+	tcs, err := gojq.ParseTests(strings.NewReader("\r\n# comment\r\n. \r\n{}\r\n{}\r\n\r\n%%FAIL\r\n.[\r\nerror\r\nmessage"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []*gojq.TestCase{
+		{Line: 3, Program: ". ", Input: "{}", Expected: []string{"{}"}},
+		{Line: 8, Program: ".[", Fail: true, Error: "error\nmessage"},
+	}
+	if !reflect.DeepEqual(tcs, expected) {
+		t.Errorf("expected: %+v, got: %+v", expected, tcs)
+	}
+}
+
+func TestParseTests_Error(t *testing.T) {
+	for _, src := range []string{".\n\n", "%%FAIL\n\n", ".\n1\n\n.[\n"} {
+		t.Run(src, func(t *testing.T) {
+			_, err := gojq.ParseTests(strings.NewReader(src))
+			if err == nil {
+				t.Fatal("expected an error but got no error")
+			}
+			if expected := "incomplete test case"; !strings.Contains(err.Error(), expected) {
+				t.Errorf("expected: %v, got: %v", expected, err)
+			}
+		})
+	}
+}
+
+func TestTestCaseRun(t *testing.T) {
+	testCases := []struct {
+		tc       gojq.TestCase
+		expected string
+	}{
+		{
+			tc: gojq.TestCase{Program: "[., .]", Input: "1", Expected: []string{"[1, 1.0]"}},
+		},
+		{
+			tc:       gojq.TestCase{Program: ".", Input: "1 2", Expected: []string{"1"}},
+			expected: "invalid input",
+		},
+		{
+			tc:       gojq.TestCase{Program: ".", Input: "1", Expected: []string{"["}},
+			expected: "invalid expected value",
+		},
+		{
+			tc:       gojq.TestCase{Program: "error", Input: `"x"`},
+			expected: "x",
+		},
+		{
+			tc:       gojq.TestCase{Program: "empty", Input: "1", Expected: []string{"1"}},
+			expected: "expected 1 but got no more output",
+		},
+		{
+			tc:       gojq.TestCase{Program: "g", Input: "1", Expected: []string{"1"}},
+			expected: "function not defined: g/0",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.tc.Program, func(t *testing.T) {
+			err := tc.tc.Run()
+			if tc.expected == "" {
+				if err != nil {
+					t.Errorf("expected no error but got: %v", err)
+				}
+			} else if err == nil {
+				t.Errorf("expected an error but got no error")
+			} else if !strings.Contains(err.Error(), tc.expected) {
+				t.Errorf("expected: %v, got: %v", tc.expected, err)
+			}
+		})
+	}
+}
@@ -2,17 +2,33 @@ package gojq
 
 import (
 	"encoding/json"
+	"reflect"
+	"strconv"
 	"unicode/utf8"
 )
 
 type lexer struct {
-	source    string
-	offset    int
-	result    *Query
-	token     string
+	source          string
+	offset          int
+	result          *Query
+	token           string
+	tokenType       int
+	inString        bool
+	err             error
+	maxStringLength int
+	maxArrayLength  int
+	maxQueryDepth   int
+	brackets        []bracket
+	fileName        string
+	locs            map[*location]int // the offsets of $__loc__
+}
+
+type bracket struct {
 	tokenType int
-	inString  bool
-	err       error
+	offset    int
+	commas    int
+	array     bool // array construction, not indexing or destructuring
+	pattern   bool // destructuring pattern
 }
 
 func newLexer(src string) *lexer {
@@ -46,12 +62,18 @@ var keywords = map[string]int{
 }
 
 func (l *lexer) Lex(lval *yySymType) (tokenType int) {
-	defer func() { l.tokenType = tokenType }()
+	defer func() {
+		if l.maxStringLength > 0 || l.maxArrayLength > 0 {
+			tokenType = l.checkLimits(tokenType, lval.token)
+		}
+		l.tokenType = tokenType
+	}()
 	if len(l.source) == l.offset {
 		l.token = ""
 		return eof
 	}
 	if l.inString {
+		lval.offset = l.offset
 		tok, str := l.scanString(l.offset)
 		lval.token = str
 		return tok
@@ -61,6 +83,7 @@ func (l *lexer) Lex(lval *yySymType) (tokenType int) {
 		l.token = ""
 		return eof
 	}
+	lval.offset = l.offset - 1
 	switch {
 	case isIdent(ch, false):
 		i := l.offset - 1
@@ -119,6 +142,14 @@ func (l *lexer) Lex(lval *yySymType) (tokenType int) {
 			if isModule {
 				return tokModuleVariable
 			}
+			if l.token == "$__loc__" {
+				loc := l.location(i)
+				if l.locs == nil {
+					l.locs = make(map[*location]int)
+				}
+				l.locs[loc] = i
+				lval.value = loc
+			}
 			return tokVariable
 		}
 	case '|':
@@ -202,6 +233,8 @@ func (l *lexer) Lex(lval *yySymType) (tokenType int) {
 			lval.operator = OpNe
 			return tokCompareOp
 		}
+		l.token = "!"
+		return tokInvalid
 	case '>':
 		if l.peek() == '=' {
 			l.offset++
@@ -228,20 +261,148 @@ func (l *lexer) Lex(lval *yySymType) (tokenType int) {
 			lval.token = l.token
 			return tokFormat
 		}
+		l.token = "@"
+		return tokInvalid
 	case '"':
 		tok, str := l.scanString(l.offset - 1)
 		lval.token = str
 		return tok
+	case '(', ')', '[', ']', '{', '}', ':', ';', ',':
 	default:
+		// Stray bytes are invalid tokens; the parser would otherwise take NUL
+		// for the end of the query, and report the others as unexpected tokens.
+		i := l.offset - 1
 		if ch >= utf8.RuneSelf {
-			r, size := utf8.DecodeRuneInString(l.source[l.offset-1:])
-			l.offset += size
-			l.token = string(r)
+			_, size := utf8.DecodeRuneInString(l.source[i:])
+			l.offset += size - 1
 		}
+		l.token = l.source[i:l.offset]
+		return tokInvalid
 	}
 	return int(ch)
 }
 
+// Returns the location of $__loc__ at the offset, which is the file name and
+// the line number (starting from 1), like jq.
+func (l *lexer) location(offset int) *location {
+	line := 1
+	for _, ch := range []byte(l.source[:offset]) {
+		if ch == '\n' {
+			line++
+		}
+	}
+	return &location{l.fileName, line}
+}
+
+// Checks the sizes of the literals against the limits configured by the parse
+// options. The elements of array literals are counted by the commas directly
+// inside the brackets, so that the check runs without building the query. The
+// brackets of indexing (after a term) and destructuring patterns (after as and
+// ?//, or inside a pattern) are told apart by the previous token.
+func (l *lexer) checkLimits(tokenType int, token string) int {
+	switch tokenType {
+	case tokString:
+		if l.maxStringLength > 0 && len(token) > l.maxStringLength {
+			start := l.offset - len(l.token)
+			line, column := l.position(start)
+			l.err = &literalLimitError{"string", l.source[start : start+1], start + 1,
+				len(token), l.maxStringLength, line, column}
+			return tokInvalid
+		}
+	case '[', '(', '{', tokStringQuery:
+		if l.maxArrayLength > 0 {
+			b := bracket{tokenType: tokenType, offset: l.offset - 1}
+			if tokenType == '[' || tokenType == '{' {
+				switch l.tokenType {
+				case tokAs, tokDestAltOp:
+					b.pattern = true
+				case '[', ',', ':':
+					i := len(l.brackets) - 1
+					b.pattern = i >= 0 && l.brackets[i].pattern
+				}
+			}
+			b.array = tokenType == '[' && !b.pattern && !endsTerm(l.tokenType)
+			l.brackets = append(l.brackets, b)
+		}
+	case ',':
+		if i := len(l.brackets) - 1; i >= 0 && l.brackets[i].array {
+			l.brackets[i].commas++
+		}
+	case ']', ')', '}':
+		if i := len(l.brackets) - 1; i >= 0 {
+			b := l.brackets[i]
+			l.brackets = l.brackets[:i]
+			if b.array && b.commas >= l.maxArrayLength {
+				line, column := l.position(b.offset)
+				l.err = &literalLimitError{"array", "[", b.offset + 1,
+					b.commas + 1, l.maxArrayLength, line, column}
+				return tokInvalid
+			}
+		}
+	}
+	return tokenType
+}
+
+// Reports whether the token can be the last token of a term, which is followed
+// by the brackets of indexing.
+func endsTerm(tokenType int) bool {
+	switch tokenType {
+	case tokIdent, tokVariable, tokModuleIdent, tokModuleVariable, tokIndex,
+		tokNumber, tokFormat, tokString, tokStringEnd, tokNull, tokTrue, tokFalse,
+		tokEnd, tokRecurse, '.', '?', ')', ']', '}':
+		return true
+	default:
+		return false
+	}
+}
+
+const defaultMaxQueryDepth = 10000
+
+// Checks the nesting depth of the parsed query against the limit. Each level
+// takes at least one byte of the source, so short queries are not walked.
+func (l *lexer) checkDepth() error {
+	if l.maxQueryDepth <= 0 || len(l.source) <= l.maxQueryDepth {
+		return nil
+	}
+	if exceedsDepth(reflect.ValueOf(l.result), l.maxQueryDepth) {
+		line, column := l.position(len(l.source))
+		return &queryDepthError{l.maxQueryDepth, len(l.source), line, column}
+	}
+	return nil
+}
+
+var queryType = reflect.TypeOf(Query{})
+
+// Reports whether the value has the queries nested deeper than the depth. The
+// walk stops on exceeding the depth, so it does not exhaust the stack.
+func exceedsDepth(v reflect.Value, depth int) bool {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return false
+		}
+		if v = v.Elem(); v.Type() == queryType {
+			if depth--; depth < 0 {
+				return true
+			}
+		}
+		return exceedsDepth(v, depth)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if exceedsDepth(v.Field(i), depth) {
+				return true
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if exceedsDepth(v.Index(i), depth) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (l *lexer) next() (byte, bool) {
 	for {
 		ch := l.source[l.offset]
@@ -506,9 +667,10 @@ func quoteAndEscape(src string, quote bool, controls int) []byte {
 }
 
 type parseError struct {
-	offset    int
-	token     string
-	tokenType int
+	offset       int
+	token        string
+	tokenType    int
+	line, column int
 }
 
 func (err *parseError) Error() string {
@@ -530,12 +692,103 @@ func (err *parseError) Token() (string, int) {
 	return err.token, err.offset
 }
 
+func (err *parseError) TokenKind() TokenKind {
+	return toTokenKind(err.tokenType)
+}
+
+func (err *parseError) Position() (int, int) {
+	return err.line, err.column
+}
+
+type literalLimitError struct {
+	typ          string
+	token        string
+	offset       int
+	length, max  int
+	line, column int
+}
+
+func (err *literalLimitError) Error() string {
+	return err.typ + " literal length " + strconv.Itoa(err.length) +
+		" exceeds the limit " + strconv.Itoa(err.max)
+}
+
+func (err *literalLimitError) Token() (string, int) {
+	return err.token, err.offset
+}
+
+func (err *literalLimitError) TokenKind() TokenKind {
+	if err.typ == "string" {
+		return TokenKindString
+	}
+	return TokenKindPunct
+}
+
+func (err *literalLimitError) Position() (int, int) {
+	return err.line, err.column
+}
+
+type queryDepthError struct {
+	max          int
+	offset       int
+	line, column int
+}
+
+func (err *queryDepthError) Error() string {
+	return "query nesting depth exceeds the limit " + strconv.Itoa(err.max)
+}
+
+func (err *queryDepthError) Token() (string, int) {
+	return "", err.offset
+}
+
+func (err *queryDepthError) TokenKind() TokenKind {
+	return TokenKindEOF
+}
+
+func (err *queryDepthError) Position() (int, int) {
+	return err.line, err.column
+}
+
+// Reports the error on binding $__loc__, which is not a variable. The location
+// is the value of the token, which tells the offset.
+func (l *lexer) bindLocError(loc any) {
+	if l.err != nil {
+		return
+	}
+	offset := l.locs[loc.(*location)]
+	line, column := l.position(offset)
+	l.err = &parseError{offset + len("$__loc__"), "$__loc__", tokVariable, line, column}
+}
+
 func (l *lexer) Error(string) {
+	if l.err != nil {
+		return
+	}
 	offset, token := l.offset, l.token
 	if l.tokenType != eof && l.tokenType < utf8.RuneSelf {
 		token = string(rune(l.tokenType))
 	}
-	l.err = &parseError{offset, token, l.tokenType}
+	line, column := l.position(offset - len(token))
+	l.err = &parseError{offset, token, l.tokenType, line, column}
+}
+
+// position returns the line and column of the byte offset in the source.
+// The newlines are LF, CRLF, and CR, in the same manner as the lexer.
+func (l *lexer) position(offset int) (line, column int) {
+	if offset > len(l.source) {
+		offset = len(l.source)
+	} else if offset < 0 {
+		offset = 0
+	}
+	line, start := 1, 0
+	for i := 0; i < offset; i++ {
+		if ch := l.source[i]; ch == '\n' ||
+			ch == '\r' && (i+1 == len(l.source) || l.source[i+1] != '\n') {
+			line, start = line+1, i+1
+		}
+	}
+	return line, utf8.RuneCountInString(l.source[start:offset]) + 1
 }
 
 func isWhite(ch byte) bool {
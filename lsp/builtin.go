@@ -0,0 +1,204 @@
+package lsp
+
+// The short descriptions of the builtin functions, shown on hover.
+var builtinDocs = map[string]string{
+	"ALL":               "Emits true if all the values are true; `ALL`, `ALL(cond)`, and `ALL(generator; cond)`.",
+	"ANY":               "Emits true if any of the values is true; `ANY`, `ANY(cond)`, and `ANY(generator; cond)`.",
+	"GROUP_BY":          "Groups the elements of the array by the key; an alias of `group_by`.",
+	"IN":                "Emits true if the input (or any value of the source) appears in the stream.",
+	"INDEX":             "Builds an object of the values keyed by the index expression.",
+	"JOIN":              "Joins the values with the index object, like SQL JOIN.",
+	"UNIQUE_BY":         "Emits the unique elements of the array by the key; an alias of `unique_by`.",
+	"abs":               "Emits the absolute value of the number.",
+	"acos":              "Emits the arc cosine of the number.",
+	"acosh":             "Emits the inverse hyperbolic cosine of the number.",
+	"add":               "Adds the elements of the array (or the values of the stream) together.",
+	"all":               "Emits true if all the elements satisfy the condition.",
+	"any":               "Emits true if any of the elements satisfies the condition.",
+	"arrays":            "Selects the arrays.",
+	"ascii":             "Emits the character of the code point.",
+	"ascii_downcase":    "Converts the ASCII characters of the string to lower case.",
+	"ascii_upcase":      "Converts the ASCII characters of the string to upper case.",
+	"asin":              "Emits the arc sine of the number.",
+	"asinh":             "Emits the inverse hyperbolic sine of the number.",
+	"atan":              "Emits the arc tangent of the number.",
+	"atan2":             "Emits the arc tangent of y/x, using the signs to determine the quadrant.",
+	"atanh":             "Emits the inverse hyperbolic tangent of the number.",
+	"booleans":          "Selects the booleans.",
+	"bsearch":           "Emits the index of the value in the sorted array, or (-1 - insertion point).",
+	"builtins":          "Emits the names of the builtin functions in the form of name/arity.",
+	"capture":           "Emits an object of the named capture groups of the regular expression match.",
+	"cbrt":              "Emits the cube root of the number.",
+	"ceil":              "Emits the smallest integer not less than the number.",
+	"combinations":      "Emits the combinations of the elements of the arrays.",
+	"contains":          "Emits true if the input contains the argument completely.",
+	"copysign":          "Emits the number with the magnitude of the first and the sign of the second.",
+	"cos":               "Emits the cosine of the number.",
+	"cosh":              "Emits the hyperbolic cosine of the number.",
+	"date":              "Formats the seconds since the Unix epoch in ISO 8601; an alias of `todate`.",
+	"dateadd":           "Adds the seconds to the time; the unit argument is ignored.",
+	"datesub":           "Subtracts the seconds from the time; the unit argument is ignored.",
+	"debug":             "Emits the input as is, and prints it to the debug output.",
+	"del":               "Deletes the values at the paths.",
+	"delpaths":          "Deletes the values at the array of the paths.",
+	"drem":              "Emits the remainder of the division, rounding the quotient to nearest.",
+	"empty":             "Emits nothing.",
+	"endswith":          "Emits true if the string ends with the argument.",
+	"env":               "Emits the object of the environment variables.",
+	"erf":               "Emits the error function of the number.",
+	"erfc":              "Emits the complementary error function of the number.",
+	"error":             "Raises an error with the input or the argument.",
+	"exp":               "Emits e raised to the power of the number.",
+	"exp10":             "Emits 10 raised to the power of the number.",
+	"exp2":              "Emits 2 raised to the power of the number.",
+	"explode":           "Converts the string to the array of the code points.",
+	"expm1":             "Emits e raised to the power of the number, minus 1.",
+	"fabs":              "Emits the absolute value of the number.",
+	"fdim":              "Emits the positive difference of the numbers.",
+	"finites":           "Selects the finite numbers.",
+	"first":             "Emits the first element of the array, or the first value of the stream.",
+	"flatten":           "Flattens the nested arrays, optionally up to the depth.",
+	"floor":             "Emits the largest integer not greater than the number.",
+	"fma":               "Emits x*y+z computed with only one rounding.",
+	"fmax":              "Emits the larger of the numbers.",
+	"fmin":              "Emits the smaller of the numbers.",
+	"fmod":              "Emits the floating-point remainder of the division.",
+	"format":            "Applies the format by name; `format(\"base64\")` is `@base64`.",
+	"frexp":             "Splits the number into the mantissa and the exponent of two.",
+	"from_entries":      "Converts the array of key-value entries to an object.",
+	"fromdate":          "Parses the ISO 8601 date string to the seconds since the Unix epoch.",
+	"fromdateiso8601":   "Parses the ISO 8601 date string to the seconds since the Unix epoch.",
+	"fromjson":          "Parses the string as JSON.",
+	"fromstream":        "Constructs the values from the stream of the path-value events.",
+	"gamma":             "Emits the gamma function of the number.",
+	"getpath":           "Emits the value at the path.",
+	"gmtime":            "Converts the seconds since the Unix epoch to the broken down time in UTC.",
+	"group_by":          "Groups the elements of the array by the key.",
+	"gsub":              "Replaces all the matches of the regular expression in the string.",
+	"halt":              "Stops the program with exit code 0.",
+	"halt_error":        "Stops the program printing the input to the standard error, with exit code 5 or the argument.",
+	"has":               "Emits true if the object has the key, or the array has the index.",
+	"hypot":             "Emits the square root of the sum of the squares of the numbers.",
+	"implode":           "Converts the array of the code points to the string.",
+	"in":                "Emits true if the argument has the input as a key or an index.",
+	"index":             "Emits the index of the first occurrence of the argument.",
+	"indices":           "Emits the indices of the occurrences of the argument.",
+	"infinite":          "Emits the positive infinity.",
+	"input":             "Emits the next input.",
+	"input_filename":    "Emits the name of the file being read, or null.",
+	"input_line_number": "Emits the number of the lines consumed from the input.",
+	"inputs":            "Emits the remaining inputs.",
+	"inside":            "Emits true if the argument contains the input completely.",
+	"isempty":           "Emits true if the stream emits no values.",
+	"isfinite":          "Emits true if the number is not infinite.",
+	"isinfinite":        "Emits true if the number is infinite.",
+	"isnan":             "Emits true if the number is NaN.",
+	"isnormal":          "Emits true if the number is a normal number.",
+	"iterables":         "Selects the arrays and the objects.",
+	"j0":                "Emits the Bessel function of the first kind of order 0.",
+	"j1":                "Emits the Bessel function of the first kind of order 1.",
+	"jn":                "Emits the Bessel function of the first kind of order n.",
+	"join":              "Joins the elements of the array with the separator.",
+	"keys":              "Emits the sorted keys of the object, or the indices of the array.",
+	"last":              "Emits the last element of the array, or the last value of the stream.",
+	"ldexp":             "Emits the number multiplied by 2 raised to the power of the exponent.",
+	"leaf_paths":        "Emits the paths to the scalar values.",
+	"length":            "Emits the length of the string, the array, or the object, or the absolute value of the number.",
+	"lgamma":            "Emits the natural logarithm of the absolute value of the gamma function.",
+	"lgamma_r":          "Emits the natural logarithm of the absolute value of the gamma function, and the sign.",
+	"limit":             "Emits at most the number of the values of the stream.",
+	"localtime":         "Converts the seconds since the Unix epoch to the broken down time in the local time zone.",
+	"log":               "Emits the natural logarithm of the number.",
+	"log10":             "Emits the base 10 logarithm of the number.",
+	"log1p":             "Emits the natural logarithm of 1 plus the number.",
+	"log2":              "Emits the base 2 logarithm of the number.",
+	"logb":              "Emits the binary exponent of the number.",
+	"ltrim":             "Removes the leading white spaces of the string.",
+	"ltrimstr":          "Removes the prefix of the string.",
+	"map":               "Applies the filter to the elements of the array.",
+	"map_values":        "Applies the filter to the values of the object or the array.",
+	"match":             "Emits the match objects of the regular expression.",
+	"max":               "Emits the maximum element of the array.",
+	"max_by":            "Emits the maximum element of the array by the key.",
+	"min":               "Emits the minimum element of the array.",
+	"min_by":            "Emits the minimum element of the array by the key.",
+	"mktime":            "Converts the broken down time to the seconds since the Unix epoch.",
+	"modf":              "Splits the number into the fractional and the integral parts.",
+	"modulemeta":        "Emits the metadata of the module by the name.",
+	"nan":               "Emits NaN.",
+	"nearbyint":         "Rounds the number to the nearest integer, ties to even.",
+	"nextafter":         "Emits the next representable number after x towards y.",
+	"nexttoward":        "Emits the next representable number after x towards y.",
+	"normals":           "Selects the normal numbers.",
+	"not":               "Emits the negation of the truthiness of the input.",
+	"now":               "Emits the current time in the seconds since the Unix epoch.",
+	"nth":               "Emits the element at the index of the array, or the value of the stream.",
+	"nulls":             "Selects null.",
+	"numbers":           "Selects the numbers.",
+	"objects":           "Selects the objects.",
+	"path":              "Emits the paths of the values the filter refers to.",
+	"paths":             "Emits the paths of all the values, or the values satisfying the condition.",
+	"peek_input":        "Emits the next input without consuming it.",
+	"pick":              "Emits the object or the array with only the values at the paths.",
+	"pow":               "Emits the first number raised to the power of the second.",
+	"pow10":             "Emits 10 raised to the power of the number.",
+	"range":             "Emits the numbers in the range, with the optional step.",
+	"recurse":           "Emits the values recursively, by the filter and the condition.",
+	"remainder":         "Emits the remainder of the division, rounding the quotient to nearest.",
+	"repeat":            "Applies the filter repeatedly and emits the values.",
+	"reverse":           "Reverses the array or the string.",
+	"rindex":            "Emits the index of the last occurrence of the argument.",
+	"rint":              "Rounds the number to the nearest integer, ties to even.",
+	"round":             "Rounds the number to the nearest integer, ties away from zero.",
+	"rtrim":             "Removes the trailing white spaces of the string.",
+	"rtrimstr":          "Removes the suffix of the string.",
+	"scalars":           "Selects the values other than the arrays and the objects.",
+	"scalb":             "Emits the number multiplied by 2 raised to the power of the exponent.",
+	"scalbln":           "Emits the number multiplied by 2 raised to the power of the exponent.",
+	"scan":              "Emits the matched strings (or the capture groups) of the regular expression.",
+	"select":            "Emits the input if the condition is true.",
+	"setpath":           "Sets the value at the path.",
+	"significand":       "Emits the mantissa of the number in the range [1, 2).",
+	"sin":               "Emits the sine of the number.",
+	"sinh":              "Emits the hyperbolic sine of the number.",
+	"sort":              "Sorts the elements of the array.",
+	"sort_by":           "Sorts the elements of the array by the key.",
+	"split":             "Splits the string by the separator, or the regular expression.",
+	"splits":            "Emits the strings split by the regular expression.",
+	"sqrt":              "Emits the square root of the number.",
+	"startswith":        "Emits true if the string starts with the argument.",
+	"stderr":            "Emits the input as is, and prints it to the standard error.",
+	"strflocaltime":     "Formats the time in the local time zone.",
+	"strftime":          "Formats the time in UTC.",
+	"strings":           "Selects the strings.",
+	"strptime":          "Parses the string to the broken down time by the format.",
+	"sub":               "Replaces the first match of the regular expression in the string.",
+	"tan":               "Emits the tangent of the number.",
+	"tanh":              "Emits the hyperbolic tangent of the number.",
+	"test":              "Emits true if the string matches the regular expression.",
+	"tgamma":            "Emits the gamma function of the number.",
+	"to_entries":        "Converts the object to the array of key-value entries.",
+	"toarray":           "Wraps the input in an array unless it is an array.",
+	"todate":            "Formats the seconds since the Unix epoch in ISO 8601.",
+	"todateiso8601":     "Formats the seconds since the Unix epoch in ISO 8601.",
+	"tojson":            "Encodes the value as a JSON string.",
+	"tonumber":          "Parses the string as a number.",
+	"tostream":          "Emits the path-value events of the value.",
+	"tostring":          "Converts the value to a string; strings are emitted as is.",
+	"transpose":         "Transposes the array of the arrays.",
+	"trim":              "Removes the leading and the trailing white spaces of the string.",
+	"trunc":             "Rounds the number toward zero.",
+	"truncate_stream":   "Removes the leading elements of the paths of the stream events by the depth.",
+	"type":              "Emits the type name of the value.",
+	"unique":            "Sorts the elements of the array and removes the duplicates.",
+	"unique_by":         "Emits the unique elements of the array by the key.",
+	"until":             "Applies the update repeatedly until the condition is true.",
+	"utf8bytelength":    "Emits the number of the bytes of the string in UTF-8.",
+	"values":            "Selects the values other than null.",
+	"walk":              "Applies the filter to all the values recursively, bottom up.",
+	"while":             "Emits the values of applying the update repeatedly while the condition is true.",
+	"with_entries":      "Applies the filter to the key-value entries of the object.",
+	"y0":                "Emits the Bessel function of the second kind of order 0.",
+	"y1":                "Emits the Bessel function of the second kind of order 1.",
+	"yn":                "Emits the Bessel function of the second kind of order n.",
+}
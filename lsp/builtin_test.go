@@ -0,0 +1,17 @@
+package lsp
+
+import "testing"
+
+func TestBuiltinDocs(t *testing.T) {
+	builtins := listBuiltins()
+	for name := range builtins {
+		if builtinDocs[name] == "" {
+			t.Errorf("builtin function %s should be documented", name)
+		}
+	}
+	for name := range builtinDocs {
+		if _, ok := builtins[name]; !ok {
+			t.Errorf("documented function %s is not a builtin function", name)
+		}
+	}
+}
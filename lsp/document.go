@@ -0,0 +1,392 @@
+package lsp
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rturpen/gojq"
+	"github.com/rturpen/gojq/internal/lint"
+)
+
+type document struct {
+	uri, text string
+	query     *gojq.Query
+	err       error
+}
+
+func newDocument(uri, text string) *document {
+	d := &document{uri: uri, text: text}
+	d.query, d.err = gojq.Parse(text)
+	return d
+}
+
+// The position in the document; the character offset counts in UTF-16 code
+// units, as the protocol specifies by default.
+type position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type textRange struct {
+	Start position `json:"start"`
+	End   position `json:"end"`
+}
+
+type location struct {
+	URI   string    `json:"uri"`
+	Range textRange `json:"range"`
+}
+
+func (d *document) position(offset int) position {
+	var p position
+	for _, r := range d.text[:offset] {
+		if r == '\n' {
+			p.Line, p.Character = p.Line+1, 0
+		} else {
+			p.Character += utf16Len(r)
+		}
+	}
+	return p
+}
+
+func (d *document) offset(p position) int {
+	var line, character int
+	for i, r := range d.text {
+		if line == p.Line && (character >= p.Character || r == '\n') {
+			return i
+		}
+		if r == '\n' {
+			line, character = line+1, 0
+		} else {
+			character += utf16Len(r)
+		}
+	}
+	return len(d.text)
+}
+
+func (d *document) textRange(start, end int) textRange {
+	return textRange{d.position(start), d.position(end)}
+}
+
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+// Returns the module search paths, including the directory of the document.
+func (s *Server) searchPaths(d *document) []string {
+	paths := s.modulePaths
+	if path := uriToPath(d.uri); path != "" {
+		paths = append(paths[:len(paths):len(paths)], filepath.Dir(path))
+	}
+	return paths
+}
+
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return ""
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+func pathToURI(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
+
+type diagnostic struct {
+	Range    textRange `json:"range"`
+	Severity int       `json:"severity"`
+	Code     string    `json:"code"`
+	Source   string    `json:"source"`
+	Message  string    `json:"message"`
+}
+
+// The severities of the diagnostics.
+const (
+	severityError   = 1
+	severityWarning = 2
+)
+
+func (s *Server) diagnose(d *document) []*diagnostic {
+	if d.err != nil {
+		start, end := 0, len(d.text)
+		if err, ok := d.err.(interface{ Token() (string, int) }); ok {
+			var token string
+			token, end = err.Token()
+			if end > len(d.text) {
+				end = len(d.text)
+			}
+			if start = end - len(token); start < 0 {
+				start = 0
+			}
+		}
+		return []*diagnostic{{
+			d.textRange(start, end), severityError, "parse-error", "gojq", d.err.Error(),
+		}}
+	}
+	options := []gojq.CompilerOption{
+		gojq.WithModuleLoader(gojq.NewModuleLoader(s.searchPaths(d))),
+		gojq.WithInputIter(gojq.NewIter()),
+	}
+	for _, name := range commandFuncs {
+		options = append(options, gojq.WithFunction(name, 0, 0, func(any, []any) any { return nil }))
+	}
+	if _, err := gojq.Compile(d.query, options...); err != nil {
+		start, end := 0, 0
+		if err, ok := err.(interface{ Token() (string, int) }); ok {
+			if token, offset := err.Token(); offset >= len(token) && offset <= len(d.text) {
+				start, end = offset-len(token), offset
+			}
+		}
+		return []*diagnostic{{
+			d.textRange(start, end), severityError, "compile-error", "gojq", err.Error(),
+		}}
+	}
+	var ds []*diagnostic
+	for _, p := range lint.Lint(d.text, d.query) {
+		r := d.textRange(0, 0)
+		if p.Start >= 0 {
+			r = d.textRange(p.Start, p.End)
+		}
+		ds = append(ds, &diagnostic{r, severityWarning, p.Code, "gojq", p.Message})
+	}
+	return ds
+}
+
+// Returns the byte offsets of the identifier (including the variables and the
+// module prefixes) at the offset.
+func wordAt(text string, offset int) (int, int) {
+	isWord := func(i int) bool {
+		switch ch := text[i]; {
+		case 'a' <= ch && ch <= 'z', 'A' <= ch && ch <= 'Z', '0' <= ch && ch <= '9', ch == '_':
+			return true
+		case ch == ':':
+			return i > 0 && text[i-1] == ':' || i+1 < len(text) && text[i+1] == ':'
+		default:
+			return false
+		}
+	}
+	start, end := offset, offset
+	for start > 0 && isWord(start-1) {
+		start--
+	}
+	for end < len(text) && isWord(end) {
+		end++
+	}
+	if start > 0 && (text[start-1] == '$' || text[start-1] == '@') {
+		start--
+	}
+	return start, end
+}
+
+// Returns the function definitions in the document; by the query, or by the
+// regular expression when the document has a parse error.
+func (d *document) funcDefs() map[string][]string {
+	defs := make(map[string][]string)
+	if d.query != nil {
+		var walk func(*gojq.Query)
+		walk = func(q *gojq.Query) {
+			for _, fd := range q.FuncDefs {
+				sig := fd.Name
+				if len(fd.Args) > 0 {
+					sig += "(" + strings.Join(fd.Args, "; ") + ")"
+				}
+				defs[fd.Name] = append(defs[fd.Name], "def "+sig+":")
+				walk(fd.Body)
+			}
+		}
+		walk(d.query)
+		return defs
+	}
+	for _, m := range funcDefPattern.FindAllStringSubmatch(d.text, -1) {
+		defs[m[1]] = append(defs[m[1]], m[0])
+	}
+	return defs
+}
+
+var (
+	funcDefPattern  = regexp.MustCompile(`\bdef\s+([a-zA-Z_][a-zA-Z0-9_]*)\s*(?:\([^)]*\))?\s*:`)
+	variablePattern = regexp.MustCompile(`\$[a-zA-Z_][a-zA-Z0-9_]*\b`)
+)
+
+type hover struct {
+	Contents struct {
+		Kind  string `json:"kind"`
+		Value string `json:"value"`
+	} `json:"contents"`
+	Range textRange `json:"range"`
+}
+
+func (s *Server) hover(d *document, offset int) *hover {
+	start, end := wordAt(d.text, offset)
+	if start == end {
+		return nil
+	}
+	word := d.text[start:end]
+	var value string
+	if word[0] == '$' {
+		value = "```jq\n" + word + "\n```\nvariable"
+	} else if word[0] == '@' {
+		value = "```jq\n" + word + "\n```\nformat string"
+	} else if i := strings.Index(word, "::"); i >= 0 {
+		path := d.importPath(word[:i])
+		if path == "" {
+			return nil
+		}
+		value = "```jq\n" + word + "\n```\nfunction in module " + strconv.Quote(path)
+	} else if sigs := d.funcDefs()[word]; len(sigs) > 0 {
+		value = "```jq\n" + strings.Join(sigs, "\n") + "\n```"
+	} else if arities := s.builtins[word]; len(arities) > 0 {
+		xs := make([]string, len(arities))
+		for i, arity := range arities {
+			xs[i] = word + "/" + strconv.Itoa(arity)
+		}
+		value = "```jq\n" + strings.Join(xs, "\n") + "\n```\nbuiltin function"
+		if doc := builtinDocs[word]; doc != "" {
+			value += ": " + doc
+		}
+	} else {
+		return nil
+	}
+	h := &hover{Range: d.textRange(start, end)}
+	h.Contents.Kind, h.Contents.Value = "markdown", value
+	return h
+}
+
+func (d *document) importPath(alias string) string {
+	if d.query != nil {
+		for _, i := range d.query.Imports {
+			if i.ImportAlias == alias {
+				return i.ImportPath
+			}
+		}
+	}
+	return ""
+}
+
+type completionList struct {
+	IsIncomplete bool              `json:"isIncomplete"`
+	Items        []*completionItem `json:"items"`
+}
+
+type completionItem struct {
+	Label  string `json:"label"`
+	Kind   int    `json:"kind"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// The kinds of the completion items.
+const (
+	completionKindFunction = 3
+	completionKindVariable = 6
+	completionKindKeyword  = 14
+)
+
+var keywords = []string{
+	"and", "as", "break", "catch", "def", "elif", "else", "end", "false",
+	"foreach", "if", "import", "include", "label", "module", "null", "or",
+	"reduce", "then", "true", "try",
+}
+
+func (s *Server) complete(d *document) *completionList {
+	items := []*completionItem{}
+	defs := d.funcDefs()
+	var names []string
+	for name := range defs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		items = append(items, &completionItem{
+			name, completionKindFunction, strings.Join(defs[name], " "),
+		})
+	}
+	names = names[:0]
+	for name := range s.builtins {
+		if _, ok := defs[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		xs := make([]string, len(s.builtins[name]))
+		for i, arity := range s.builtins[name] {
+			xs[i] = name + "/" + strconv.Itoa(arity)
+		}
+		items = append(items, &completionItem{
+			name, completionKindFunction, strings.Join(xs, ", "),
+		})
+	}
+	vars := map[string]bool{"$ENV": true, "$__loc__": true}
+	for _, name := range variablePattern.FindAllString(d.text, -1) {
+		vars[name] = true
+	}
+	names = names[:0]
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		items = append(items, &completionItem{Label: name, Kind: completionKindVariable})
+	}
+	for _, keyword := range keywords {
+		items = append(items, &completionItem{Label: keyword, Kind: completionKindKeyword})
+	}
+	return &completionList{Items: items}
+}
+
+func (s *Server) definition(d *document, offset int) *location {
+	start, end := wordAt(d.text, offset)
+	if start == end {
+		return nil
+	}
+	word := d.text[start:end]
+	if i := strings.Index(word, "::"); i >= 0 {
+		path := d.importPath(word[:i])
+		if path == "" {
+			return nil
+		}
+		for _, base := range s.searchPaths(d) {
+			for _, fname := range []string{
+				filepath.Join(base, path+".jq"),
+				filepath.Join(base, path, filepath.Base(path)+".jq"),
+			} {
+				cnt, err := os.ReadFile(fname)
+				if err != nil {
+					continue
+				}
+				m := newDocument(pathToURI(fname), string(cnt))
+				if start, end := lint.FindFuncDef(m.text, word[i+2:]); start >= 0 {
+					return &location{m.uri, m.textRange(start, end)}
+				}
+				return &location{m.uri, m.textRange(0, 0)}
+			}
+		}
+		return nil
+	}
+	if word[0] == '$' {
+		if d.query == nil {
+			return nil
+		}
+		offset := d.query.VariableBinding(start)
+		if offset < 0 {
+			return nil
+		}
+		return &location{d.uri, d.textRange(offset, offset+len(word))}
+	}
+	if start, end := lint.FindFuncDef(d.text, word); start >= 0 {
+		return &location{d.uri, d.textRange(start, end)}
+	}
+	return nil
+}
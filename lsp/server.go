@@ -0,0 +1,287 @@
+// Package lsp implements a language server for jq queries, which provides the
+// diagnostics (the parse and compile errors, and the unused definitions), the
+// hover information and the completion of the functions and the variables, and
+// the definitions of the variables, and the functions in the documents and the
+// modules.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rturpen/gojq"
+)
+
+// Server is a language server communicating with the client by JSON-RPC over
+// the stream (like the standard input and output), with the full document
+// synchronization. Do not use a server concurrently.
+type Server struct {
+	modulePaths []string
+	builtins    map[string][]int
+	docs        map[string]*document
+	w           io.Writer
+	shutdown    bool
+}
+
+// NewServer creates a new language server. The module paths are used to load
+// the modules on compiling the documents, and to locate the definitions of the
+// module functions.
+func NewServer(modulePaths []string) *Server {
+	return &Server{
+		modulePaths: modulePaths,
+		builtins:    listBuiltins(),
+		docs:        make(map[string]*document),
+	}
+}
+
+// The functions provided by the command, which take no arguments.
+var commandFuncs = []string{"input_filename", "input_line_number"}
+
+func listBuiltins() map[string][]int {
+	builtins := make(map[string][]int)
+	q, _ := gojq.Parse("builtins[]")
+	iter := q.Run(nil)
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			break
+		}
+		if s, ok := v.(string); ok {
+			if i := strings.LastIndexByte(s, '/'); i > 0 {
+				arity, _ := strconv.Atoi(s[i+1:])
+				builtins[s[:i]] = append(builtins[s[:i]], arity)
+			}
+		}
+	}
+	for _, name := range commandFuncs {
+		builtins[name] = []int{0}
+	}
+	for _, arities := range builtins {
+		sort.Ints(arities)
+	}
+	return builtins
+}
+
+type request struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// The response has either the result (which may be null) or the error.
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result"`
+}
+
+type errorResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Error   *responseError  `json:"error"`
+}
+
+type notification struct {
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  any    `json:"params"`
+}
+
+type responseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// The error codes defined by JSON-RPC and the protocol.
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+)
+
+// The maximum length of a message, to avoid allocating a huge buffer for the
+// malformed header. The documents of the queries are far smaller than this.
+const maxContentLength = 64 << 20
+
+// Serve reads the messages from the reader, and writes the responses and the
+// notifications to the writer, until the client sends the exit notification or
+// the reader reaches EOF.
+func (s *Server) Serve(r io.Reader, w io.Writer) error {
+	s.w = w
+	br := textproto.NewReader(bufio.NewReader(r))
+	for {
+		header, err := br.ReadMIMEHeader()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		length, err := strconv.Atoi(header.Get("Content-Length"))
+		if err != nil || length < 0 {
+			return fmt.Errorf("invalid Content-Length: %q", header.Get("Content-Length"))
+		} else if length > maxContentLength {
+			return fmt.Errorf("too large Content-Length: %d", length)
+		}
+		body := make([]byte, length)
+		if _, err := io.ReadFull(br.R, body); err != nil {
+			return err
+		}
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			if err := s.reply(nil, nil, &responseError{codeParseError, err.Error()}); err != nil {
+				return err
+			}
+			continue
+		}
+		if req.Method == "exit" {
+			return nil
+		}
+		result, rerr := s.handle(&req)
+		if req.ID == nil {
+			continue // notification
+		}
+		if err := s.reply(req.ID, result, rerr); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) handle(req *request) (any, *responseError) {
+	if s.shutdown && req.Method != "shutdown" {
+		return nil, &responseError{codeInvalidRequest, "server is shut down"}
+	}
+	var err error
+	switch req.Method {
+	case "initialize":
+		return map[string]any{
+			"capabilities": map[string]any{
+				"textDocumentSync":   1,
+				"hoverProvider":      true,
+				"completionProvider": map[string]any{"triggerCharacters": []string{"$"}},
+				"definitionProvider": true,
+			},
+			"serverInfo": map[string]any{"name": "gojq"},
+		}, nil
+	case "initialized", "$/cancelRequest", "$/setTrace":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var params struct {
+			TextDocument struct {
+				URI  string `json:"uri"`
+				Text string `json:"text"`
+			} `json:"textDocument"`
+		}
+		if err = json.Unmarshal(req.Params, &params); err == nil {
+			err = s.update(params.TextDocument.URI, params.TextDocument.Text)
+		}
+	case "textDocument/didChange":
+		var params struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+			ContentChanges []struct {
+				Text string `json:"text"`
+			} `json:"contentChanges"`
+		}
+		if err = json.Unmarshal(req.Params, &params); err == nil {
+			if n := len(params.ContentChanges); n > 0 {
+				err = s.update(params.TextDocument.URI, params.ContentChanges[n-1].Text)
+			}
+		}
+	case "textDocument/didClose":
+		var params struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+		}
+		if err = json.Unmarshal(req.Params, &params); err == nil {
+			delete(s.docs, params.TextDocument.URI)
+			err = s.publishDiagnostics(params.TextDocument.URI, nil)
+		}
+	case "textDocument/hover", "textDocument/completion", "textDocument/definition":
+		var params struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+			Position position `json:"position"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &responseError{codeInvalidParams, err.Error()}
+		}
+		d := s.docs[params.TextDocument.URI]
+		if d == nil {
+			return nil, nil
+		}
+		offset := d.offset(params.Position)
+		switch req.Method {
+		case "textDocument/hover":
+			return s.hover(d, offset), nil
+		case "textDocument/completion":
+			return s.complete(d), nil
+		default:
+			return s.definition(d, offset), nil
+		}
+	default:
+		if req.ID != nil {
+			return nil, &responseError{codeMethodNotFound, "method not found: " + req.Method}
+		}
+		return nil, nil
+	}
+	if err != nil {
+		return nil, &responseError{codeInvalidParams, err.Error()}
+	}
+	return nil, nil
+}
+
+func (s *Server) reply(id json.RawMessage, result any, err *responseError) error {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	if err != nil {
+		return s.write(&errorResponse{JSONRPC: "2.0", ID: id, Error: err})
+	}
+	return s.write(&response{JSONRPC: "2.0", ID: id, Result: result})
+}
+
+func (s *Server) notify(method string, params any) error {
+	return s.write(&notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+func (s *Server) write(v any) error {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(s.w, "Content-Length: %d\r\n\r\n", len(bs)); err != nil {
+		return err
+	}
+	_, err = s.w.Write(bs)
+	return err
+}
+
+func (s *Server) update(uri, text string) error {
+	d := newDocument(uri, text)
+	s.docs[uri] = d
+	return s.publishDiagnostics(uri, s.diagnose(d))
+}
+
+func (s *Server) publishDiagnostics(uri string, ds []*diagnostic) error {
+	if ds == nil {
+		ds = []*diagnostic{}
+	}
+	return s.notify("textDocument/publishDiagnostics", map[string]any{
+		"uri": uri, "diagnostics": ds,
+	})
+}
@@ -0,0 +1,415 @@
+package lsp_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/rturpen/gojq/lsp"
+)
+
+func frame(messages ...any) io.Reader {
+	var buf bytes.Buffer
+	for _, m := range messages {
+		bs, err := json.Marshal(m)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Fprintf(&buf, "Content-Length: %d\r\n\r\n%s", len(bs), bs)
+	}
+	return &buf
+}
+
+func readMessages(t *testing.T, r io.Reader) []map[string]any {
+	t.Helper()
+	br := textproto.NewReader(bufio.NewReader(r))
+	var ms []map[string]any
+	for {
+		header, err := br.ReadMIMEHeader()
+		if err == io.EOF {
+			return ms
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		length, err := strconv.Atoi(header.Get("Content-Length"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		body := make([]byte, length)
+		if _, err := io.ReadFull(br.R, body); err != nil {
+			t.Fatal(err)
+		}
+		var m map[string]any
+		if err := json.Unmarshal(body, &m); err != nil {
+			t.Fatal(err)
+		}
+		ms = append(ms, m)
+	}
+}
+
+func request(id int, method string, params any) map[string]any {
+	return map[string]any{"jsonrpc": "2.0", "id": id, "method": method, "params": params}
+}
+
+func notification(method string, params any) map[string]any {
+	return map[string]any{"jsonrpc": "2.0", "method": method, "params": params}
+}
+
+func textDocumentPosition(uri string, line, character int) map[string]any {
+	return map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"position":     map[string]any{"line": line, "character": character},
+	}
+}
+
+func serve(t *testing.T, s *lsp.Server, messages ...any) []map[string]any {
+	t.Helper()
+	var out bytes.Buffer
+	if err := s.Serve(frame(messages...), &out); err != nil {
+		t.Fatal(err)
+	}
+	return readMessages(t, &out)
+}
+
+func results(ms []map[string]any) map[float64]any {
+	rs := make(map[float64]any)
+	for _, m := range ms {
+		if id, ok := m["id"].(float64); ok {
+			if e, ok := m["error"]; ok {
+				rs[id] = e
+			} else {
+				rs[id] = m["result"]
+			}
+		}
+	}
+	return rs
+}
+
+func diagnostics(ms []map[string]any) []any {
+	var ds []any
+	for _, m := range ms {
+		if m["method"] == "textDocument/publishDiagnostics" {
+			ds = append(ds, m["params"].(map[string]any)["diagnostics"])
+		}
+	}
+	return ds
+}
+
+func TestServer(t *testing.T) {
+	const uri = "file:///tmp/test.jq"
+	ms := serve(t, lsp.NewServer(nil),
+		request(1, "initialize", map[string]any{}),
+		notification("initialized", map[string]any{}),
+		notification("textDocument/didOpen", map[string]any{
+			"textDocument": map[string]any{"uri": uri, "text": "def f: 1;\n. as $x | g"},
+		}),
+		notification("textDocument/didChange", map[string]any{
+			"textDocument":   map[string]any{"uri": uri},
+			"contentChanges": []any{map[string]any{"text": "def f(g): g;\n. as $x | f(length) | ("}},
+		}),
+		notification("textDocument/didChange", map[string]any{
+			"textDocument":   map[string]any{"uri": uri},
+			"contentChanges": []any{map[string]any{"text": "def f(g): g;\ndef h: 1;\n. as $x | f(length)"}},
+		}),
+		request(2, "textDocument/hover", textDocumentPosition(uri, 2, 11)),
+		request(3, "textDocument/hover", textDocumentPosition(uri, 2, 14)),
+		request(4, "textDocument/hover", textDocumentPosition(uri, 2, 6)),
+		request(5, "textDocument/hover", textDocumentPosition(uri, 2, 8)),
+		request(6, "textDocument/definition", textDocumentPosition(uri, 2, 10)),
+		request(7, "textDocument/definition", textDocumentPosition(uri, 2, 14)),
+		request(8, "textDocument/foo", map[string]any{}),
+		notification("textDocument/didClose", map[string]any{
+			"textDocument": map[string]any{"uri": uri},
+		}),
+		request(9, "textDocument/hover", textDocumentPosition(uri, 2, 11)),
+		request(10, "shutdown", nil),
+		request(11, "textDocument/hover", textDocumentPosition(uri, 2, 11)),
+		notification("exit", nil),
+		request(12, "shutdown", nil),
+	)
+	rs := results(ms)
+	if _, ok := rs[12]; ok {
+		t.Errorf("server should exit on exit notification")
+	}
+	if got, expected := rs[1].(map[string]any)["serverInfo"], any(map[string]any{"name": "gojq"}); !cmp.Equal(got, expected) {
+		t.Errorf("initialize: serverInfo should be %v but got %v", expected, got)
+	}
+	for _, tc := range []struct {
+		id       float64
+		expected any
+	}{
+		{
+			2,
+			map[string]any{
+				"contents": map[string]any{"kind": "markdown", "value": "```jq\ndef f(g):\n```"},
+				"range": map[string]any{
+					"start": map[string]any{"line": 2.0, "character": 10.0},
+					"end":   map[string]any{"line": 2.0, "character": 11.0},
+				},
+			},
+		},
+		{
+			3,
+			map[string]any{
+				"contents": map[string]any{"kind": "markdown", "value": "```jq\nlength/0\n```\nbuiltin function: " +
+					"Emits the length of the string, the array, or the object, or the absolute value of the number."},
+				"range": map[string]any{
+					"start": map[string]any{"line": 2.0, "character": 12.0},
+					"end":   map[string]any{"line": 2.0, "character": 18.0},
+				},
+			},
+		},
+		{
+			4,
+			map[string]any{
+				"contents": map[string]any{"kind": "markdown", "value": "```jq\n$x\n```\nvariable"},
+				"range": map[string]any{
+					"start": map[string]any{"line": 2.0, "character": 5.0},
+					"end":   map[string]any{"line": 2.0, "character": 7.0},
+				},
+			},
+		},
+		{5, nil},
+		{
+			6,
+			map[string]any{
+				"uri": uri,
+				"range": map[string]any{
+					"start": map[string]any{"line": 0.0, "character": 0.0},
+					"end":   map[string]any{"line": 0.0, "character": 5.0},
+				},
+			},
+		},
+		{7, nil},
+		{8, map[string]any{"code": -32601.0, "message": "method not found: textDocument/foo"}},
+		{9, nil},
+		{10, nil},
+		{11, map[string]any{"code": -32600.0, "message": "server is shut down"}},
+	} {
+		if got := rs[tc.id]; !cmp.Equal(got, tc.expected) {
+			t.Errorf("response %v:\n%s", tc.id, cmp.Diff(tc.expected, got))
+		}
+	}
+	for _, m := range ms {
+		if _, ok := m["id"]; !ok {
+			continue
+		}
+		_, hasResult := m["result"]
+		_, hasError := m["error"]
+		if hasResult == hasError {
+			t.Errorf("response should have either result or error: %v", m)
+		}
+	}
+	ds := diagnostics(ms)
+	if len(ds) != 4 {
+		t.Fatalf("expected 4 diagnostics notifications but got %d", len(ds))
+	}
+	for i, expected := range []any{
+		[]any{
+			map[string]any{
+				"range": map[string]any{
+					"start": map[string]any{"line": 1.0, "character": 10.0},
+					"end":   map[string]any{"line": 1.0, "character": 11.0},
+				},
+				"severity": 1.0, "code": "compile-error", "source": "gojq",
+				"message": "function not defined: g/0",
+			},
+		},
+		[]any{
+			map[string]any{
+				"range": map[string]any{
+					"start": map[string]any{"line": 1.0, "character": 23.0},
+					"end":   map[string]any{"line": 1.0, "character": 23.0},
+				},
+				"severity": 1.0, "code": "parse-error", "source": "gojq",
+				"message": "unexpected EOF",
+			},
+		},
+		[]any{
+			map[string]any{
+				"range": map[string]any{
+					"start": map[string]any{"line": 1.0, "character": 0.0},
+					"end":   map[string]any{"line": 1.0, "character": 5.0},
+				},
+				"severity": 2.0, "code": "unused-function", "source": "gojq",
+				"message": "function h/0 is defined but not used",
+			},
+			map[string]any{
+				"range": map[string]any{
+					"start": map[string]any{"line": 2.0, "character": 5.0},
+					"end":   map[string]any{"line": 2.0, "character": 7.0},
+				},
+				"severity": 2.0, "code": "unused-variable", "source": "gojq",
+				"message": "variable $x is bound but not used",
+			},
+		},
+		[]any{},
+	} {
+		if got := ds[i]; !cmp.Equal(got, expected) {
+			t.Errorf("diagnostics %d:\n%s", i, cmp.Diff(expected, got))
+		}
+	}
+}
+
+func TestServerCompletion(t *testing.T) {
+	const uri = "untitled:Untitled-1"
+	ms := serve(t, lsp.NewServer(nil),
+		request(1, "initialize", map[string]any{}),
+		notification("textDocument/didOpen", map[string]any{
+			"textDocument": map[string]any{"uri": uri, "text": "def map(f): 1; . as $foo | $"},
+		}),
+		request(2, "textDocument/completion", textDocumentPosition(uri, 0, 28)),
+	)
+	items := results(ms)[2].(map[string]any)["items"].([]any)
+	labels := map[string]map[string]any{}
+	for _, item := range items {
+		item := item.(map[string]any)
+		labels[item["label"].(string)] = item
+	}
+	for _, tc := range []struct {
+		label  string
+		kind   float64
+		detail any
+	}{
+		{"map", 3, "def map(f):"},
+		{"length", 3, "length/0"},
+		{"range", 3, "range/1, range/2, range/3"},
+		{"$foo", 6, nil},
+		{"$ENV", 6, nil},
+		{"$__loc__", 6, nil},
+		{"reduce", 14, nil},
+	} {
+		item, ok := labels[tc.label]
+		if !ok {
+			t.Errorf("completion should contain %q", tc.label)
+			continue
+		}
+		if item["kind"] != tc.kind || item["detail"] != tc.detail {
+			t.Errorf("completion of %q: got %v", tc.label, item)
+		}
+	}
+}
+
+func TestServerModule(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "lib"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "lib", "m.jq"),
+		[]byte("def g: 2;\ndef f: g + 1;\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	uri := "file://" + filepath.ToSlash(filepath.Join(dir, "main.jq"))
+	ms := serve(t, lsp.NewServer([]string{filepath.Join(dir, "lib")}),
+		request(1, "initialize", map[string]any{}),
+		notification("textDocument/didOpen", map[string]any{
+			"textDocument": map[string]any{"uri": uri, "text": `import "m" as m; m::f | m::h`},
+		}),
+		request(2, "textDocument/hover", textDocumentPosition(uri, 0, 19)),
+		request(3, "textDocument/definition", textDocumentPosition(uri, 0, 19)),
+	)
+	ds := diagnostics(ms)
+	if len(ds) != 1 || len(ds[0].([]any)) != 1 ||
+		!strings.Contains(ds[0].([]any)[0].(map[string]any)["message"].(string), "m::h/0") {
+		t.Errorf("diagnostics should report m::h/0 but got %v", ds)
+	}
+	rs := results(ms)
+	if got, expected := rs[2].(map[string]any)["contents"].(map[string]any)["value"],
+		"```jq\nm::f\n```\nfunction in module \"m\""; got != expected {
+		t.Errorf("hover should be %q but got %q", expected, got)
+	}
+	expected := map[string]any{
+		"uri": "file://" + filepath.ToSlash(filepath.Join(dir, "lib", "m.jq")),
+		"range": map[string]any{
+			"start": map[string]any{"line": 1.0, "character": 0.0},
+			"end":   map[string]any{"line": 1.0, "character": 5.0},
+		},
+	}
+	if got := rs[3]; !cmp.Equal(got, any(expected)) {
+		t.Errorf("definition:\n%s", cmp.Diff(expected, got))
+	}
+}
+
+func TestServerVariableDefinition(t *testing.T) {
+	const uri = "file:///tmp/test.jq"
+	ms := serve(t, lsp.NewServer(nil),
+		request(1, "initialize", map[string]any{}),
+		notification("textDocument/didOpen", map[string]any{
+			"textDocument": map[string]any{"uri": uri, "text": "def f($x): $x;\n. as $x | [$x, f($x)] | $y"},
+		}),
+		request(2, "textDocument/definition", textDocumentPosition(uri, 0, 12)),
+		request(3, "textDocument/definition", textDocumentPosition(uri, 1, 12)),
+		request(4, "textDocument/definition", textDocumentPosition(uri, 1, 18)),
+		request(5, "textDocument/definition", textDocumentPosition(uri, 1, 25)),
+	)
+	rs := results(ms)
+	for _, tc := range []struct {
+		id       float64
+		expected any
+	}{
+		{2, map[string]any{
+			"uri": uri,
+			"range": map[string]any{
+				"start": map[string]any{"line": 0.0, "character": 6.0},
+				"end":   map[string]any{"line": 0.0, "character": 8.0},
+			},
+		}},
+		{3, map[string]any{
+			"uri": uri,
+			"range": map[string]any{
+				"start": map[string]any{"line": 1.0, "character": 5.0},
+				"end":   map[string]any{"line": 1.0, "character": 7.0},
+			},
+		}},
+		{4, map[string]any{
+			"uri": uri,
+			"range": map[string]any{
+				"start": map[string]any{"line": 1.0, "character": 5.0},
+				"end":   map[string]any{"line": 1.0, "character": 7.0},
+			},
+		}},
+		{5, nil},
+	} {
+		if got := rs[tc.id]; !cmp.Equal(got, tc.expected) {
+			t.Errorf("definition %v:\n%s", tc.id, cmp.Diff(tc.expected, got))
+		}
+	}
+}
+
+func TestServerCommandFunctions(t *testing.T) {
+	const uri = "file:///tmp/test.jq"
+	ms := serve(t, lsp.NewServer(nil),
+		request(1, "initialize", map[string]any{}),
+		notification("textDocument/didOpen", map[string]any{
+			"textDocument": map[string]any{"uri": uri, "text": "[input_filename, input_line_number]"},
+		}),
+	)
+	if ds, expected := diagnostics(ms), []any{[]any{}}; !cmp.Equal(ds, expected) {
+		t.Errorf("diagnostics:\n%s", cmp.Diff(expected, ds))
+	}
+}
+
+func TestServerInvalidContentLength(t *testing.T) {
+	for _, tc := range []struct {
+		header, expected string
+	}{
+		{"Content-Length: x\r\n\r\n", `invalid Content-Length: "x"`},
+		{"Content-Length: -1\r\n\r\n", `invalid Content-Length: "-1"`},
+		{"Content-Length: 9223372036854775807\r\n\r\n", "too large Content-Length: 9223372036854775807"},
+	} {
+		err := lsp.NewServer(nil).Serve(strings.NewReader(tc.header), io.Discard)
+		if err == nil || err.Error() != tc.expected {
+			t.Errorf("expected error %q but got: %v", tc.expected, err)
+		}
+	}
+}
@@ -1,17 +1,22 @@
 package gojq
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 )
 
 // ModuleLoader is the interface for loading modules.
 //
-// Implement following optional methods. Use [NewModuleLoader] to load local modules.
+// Implement following optional methods. Use [NewModuleLoader] to load local modules,
+// or [NewFSModuleLoader] to load modules from a file system like [embed.FS].
 //
 //	LoadModule(string) (*Query, error)
 //	LoadModuleWithMeta(string, map[string]any) (*Query, error)
@@ -21,23 +26,65 @@ import (
 type ModuleLoader any
 
 // NewModuleLoader creates a new [ModuleLoader] reading local modules in the paths.
+// The paths are searched in order on importing modules, and the path named .jq
+// (like ~/.jq) is loaded as an init module if it is a file. Like jq, the path
+// starting with ~ is relative to the home directory, and the one starting with
+// $ORIGIN is relative to the directory of the executable. Use [WithInitModules]
+// to disable loading the init modules.
 func NewModuleLoader(paths []string) ModuleLoader {
-	return &moduleLoader{expandHomeDir(paths)}
+	return &moduleLoader{nil, expandPaths(paths)}
+}
+
+// NewFSModuleLoader creates a new [ModuleLoader] reading modules in the paths of
+// the file system. The paths are slash-separated and unrooted (use "." for the
+// root directory), as [fs.ValidPath] reports, and so is the search path in the
+// module directive relative to the importing module.
+func NewFSModuleLoader(fsys fs.FS, paths []string) ModuleLoader {
+	return &moduleLoader{fsys, paths}
 }
 
 type moduleLoader struct {
+	fsys  fs.FS
 	paths []string
 }
 
+func (l *moduleLoader) stat(name string) (fs.FileInfo, error) {
+	if l.fsys != nil {
+		return fs.Stat(l.fsys, name)
+	}
+	return os.Stat(name)
+}
+
+func (l *moduleLoader) readFile(name string) ([]byte, error) {
+	if l.fsys != nil {
+		return fs.ReadFile(l.fsys, name)
+	}
+	return os.ReadFile(name)
+}
+
+func (l *moduleLoader) join(elem ...string) string {
+	if l.fsys != nil {
+		return path.Join(elem...)
+	}
+	return filepath.Join(elem...)
+}
+
+func (l *moduleLoader) base(name string) string {
+	if l.fsys != nil {
+		return path.Base(name)
+	}
+	return filepath.Base(name)
+}
+
 func (l *moduleLoader) LoadInitModules() ([]*Query, error) {
 	var qs []*Query
 	for _, path := range l.paths {
-		if filepath.Base(path) != ".jq" {
+		if l.base(path) != ".jq" {
 			continue
 		}
-		fi, err := os.Stat(path)
+		fi, err := l.stat(path)
 		if err != nil {
-			if os.IsNotExist(err) {
+			if errors.Is(err, fs.ErrNotExist) {
 				continue
 			}
 			return nil, err
@@ -45,7 +92,7 @@ func (l *moduleLoader) LoadInitModules() ([]*Query, error) {
 		if fi.IsDir() {
 			continue
 		}
-		cnt, err := os.ReadFile(path)
+		cnt, err := l.readFile(path)
 		if err != nil {
 			return nil, err
 		}
@@ -63,7 +110,7 @@ func (l *moduleLoader) LoadModuleWithMeta(name string, meta map[string]any) (*Qu
 	if err != nil {
 		return nil, err
 	}
-	cnt, err := os.ReadFile(path)
+	cnt, err := l.readFile(path)
 	if err != nil {
 		return nil, err
 	}
@@ -79,13 +126,12 @@ func (l *moduleLoader) LoadJSONWithMeta(name string, meta map[string]any) (any,
 	if err != nil {
 		return nil, err
 	}
-	f, err := os.Open(path)
+	cnt, err := l.readFile(path)
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
 	vals := []any{}
-	dec := json.NewDecoder(f)
+	dec := json.NewDecoder(bytes.NewReader(cnt))
 	dec.UseNumber()
 	for {
 		var val any
@@ -93,13 +139,6 @@ func (l *moduleLoader) LoadJSONWithMeta(name string, meta map[string]any) (any,
 			if err == io.EOF {
 				break
 			}
-			if _, err := f.Seek(0, io.SeekStart); err != nil {
-				return nil, err
-			}
-			cnt, er := io.ReadAll(f)
-			if er != nil {
-				return nil, er
-			}
 			return nil, &jsonParseError{path, string(cnt), err}
 		}
 		vals = append(vals, val)
@@ -109,16 +148,16 @@ func (l *moduleLoader) LoadJSONWithMeta(name string, meta map[string]any) (any,
 
 func (l *moduleLoader) lookupModule(name, extension string, meta map[string]any) (string, error) {
 	paths := l.paths
-	if path := searchPath(meta); path != "" {
+	if path := l.searchPath(meta); path != "" {
 		paths = append([]string{path}, paths...)
 	}
 	for _, base := range paths {
-		path := filepath.Clean(filepath.Join(base, name+extension))
-		if _, err := os.Stat(path); err == nil {
+		path := l.join(base, name+extension)
+		if _, err := l.stat(path); err == nil {
 			return path, err
 		}
-		path = filepath.Clean(filepath.Join(base, name, filepath.Base(name)+extension))
-		if _, err := os.Stat(path); err == nil {
+		path = l.join(base, name, l.base(name)+extension)
+		if _, err := l.stat(path); err == nil {
 			return path, err
 		}
 	}
@@ -127,7 +166,7 @@ func (l *moduleLoader) lookupModule(name, extension string, meta map[string]any)
 
 // This is a dirty hack to implement the "search" field.
 func parseModule(path, cnt string) (*Query, error) {
-	q, err := Parse(cnt)
+	q, err := ParseWithOptions(cnt, WithFileName(path))
 	if err != nil {
 		return nil, err
 	}
@@ -146,7 +185,7 @@ func parseModule(path, cnt string) (*Query, error) {
 	return q, nil
 }
 
-func searchPath(meta map[string]any) string {
+func (l *moduleLoader) searchPath(meta map[string]any) string {
 	x, ok := meta["search"]
 	if !ok {
 		return ""
@@ -155,36 +194,43 @@ func searchPath(meta map[string]any) string {
 	if !ok {
 		return ""
 	}
-	if filepath.IsAbs(s) {
-		return s
-	}
-	if strings.HasPrefix(s, "~") {
-		if homeDir, err := os.UserHomeDir(); err == nil {
-			return filepath.Join(homeDir, s[1:])
+	if l.fsys == nil {
+		if s = expandPath(s); filepath.IsAbs(s) {
+			return s
 		}
 	}
-	var path string
+	var p string
 	if x, ok := meta["$$path"]; ok {
-		path, _ = x.(string)
+		p, _ = x.(string)
 	}
-	if path == "" {
+	if p == "" {
 		return s
 	}
-	return filepath.Join(filepath.Dir(path), s)
+	if l.fsys != nil {
+		return path.Join(path.Dir(p), s)
+	}
+	return filepath.Join(filepath.Dir(p), s)
 }
 
-func expandHomeDir(paths []string) []string {
-	var homeDir string
-	var err error
+func expandPaths(paths []string) []string {
+	xs := make([]string, len(paths))
 	for i, path := range paths {
-		if strings.HasPrefix(path, "~") {
-			if homeDir == "" && err == nil {
-				homeDir, err = os.UserHomeDir()
-			}
-			if homeDir != "" {
-				paths[i] = filepath.Join(homeDir, path[1:])
+		xs[i] = expandPath(path)
+	}
+	return xs
+}
+
+func expandPath(path string) string {
+	if strings.HasPrefix(path, "~") {
+		if homeDir, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(homeDir, path[1:])
+		}
+	} else if path == "$ORIGIN" || strings.HasPrefix(path, "$ORIGIN/") {
+		if executable, err := os.Executable(); err == nil {
+			if executable, err := filepath.EvalSymlinks(executable); err == nil {
+				return filepath.Join(filepath.Dir(executable), path[len("$ORIGIN"):])
 			}
 		}
 	}
-	return paths
+	return path
 }
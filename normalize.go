@@ -1,9 +1,15 @@
 package gojq
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"io"
 	"math"
 	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -25,7 +31,34 @@ func normalizeNumber(v json.Number) any {
 	return math.Inf(1)
 }
 
+// The containers being normalized are checked by scanning the path up to this
+// depth, and by the map after that, because allocating the map is costly for
+// the shallow values.
+const scanCyclesUntil = 32
+
+// Normalizes the numbers and raw JSON messages in v, and returns an error
+// value when v contains a reference to itself (otherwise the evaluation would
+// never terminate) or an invalid raw JSON message. Note that the slices and
+// the maps in v are normalized in place (except map[any]any, which is copied
+// into map[string]any), and they are left partially normalized on error.
 func normalizeNumbers(v any) any {
+	return (&normalizer{}).normalize(v)
+}
+
+// Normalizes v like normalizeNumbers, and stringifies the non-string keys of
+// map[any]any if stringifyKeys is true, otherwise they are invalid values.
+func normalizeValue(v any, stringifyKeys bool) any {
+	return (&normalizer{stringifyKeys: stringifyKeys}).normalize(v)
+}
+
+type normalizer struct {
+	path          [scanCyclesUntil]uintptr
+	depth         int
+	seen          map[uintptr]struct{}
+	stringifyKeys bool
+}
+
+func (n *normalizer) normalize(v any) any {
 	switch v := v.(type) {
 	case json.Number:
 		return normalizeNumber(v)
@@ -68,17 +101,227 @@ func normalizeNumbers(v any) any {
 		return int(v)
 	case float32:
 		return float64(v)
+	case json.RawMessage:
+		w, err := decodeRawMessage(v)
+		if err != nil {
+			return &invalidValueError{v, nil, err}
+		}
+		return n.normalize(w)
 	case []any:
+		if len(v) == 0 {
+			return v
+		}
+		if err := n.enter(v); err != nil {
+			return err
+		}
 		for i, x := range v {
-			v[i] = normalizeNumbers(x)
+			if x = n.normalize(x); isNormalizeError(x) {
+				return x
+			}
+			v[i] = x
 		}
+		n.leave(v)
 		return v
 	case map[string]any:
+		if len(v) == 0 {
+			return v
+		}
+		if err := n.enter(v); err != nil {
+			return err
+		}
 		for k, x := range v {
-			v[k] = normalizeNumbers(x)
+			if x = n.normalize(x); isNormalizeError(x) {
+				return x
+			}
+			v[k] = x
 		}
+		n.leave(v)
 		return v
+	case map[any]any:
+		if err := n.enter(v); err != nil {
+			return err
+		}
+		w := make(map[string]any, len(v))
+		for k, x := range v {
+			s, ok := k.(string)
+			if !ok {
+				if !n.stringifyKeys {
+					return &invalidValueError{v, nil, &objectKeyNotStringError{k}}
+				}
+				if k = n.normalize(k); isNormalizeError(k) {
+					return k
+				}
+				s = jsonMarshal(k)
+			}
+			if _, ok := w[s]; ok {
+				return &invalidValueError{v, nil, &objectKeyDuplicateError{s}}
+			}
+			w[s] = x
+		}
+		x := n.normalize(w)
+		n.leave(v)
+		return x
 	default:
 		return v
 	}
 }
+
+func (n *normalizer) enter(v any) error {
+	ptr := reflect.ValueOf(v).Pointer()
+	l := n.depth
+	if l > scanCyclesUntil {
+		l = scanCyclesUntil
+	}
+	for _, p := range n.path[:l] {
+		if p == ptr {
+			return &cycleError{v}
+		}
+	}
+	if _, ok := n.seen[ptr]; ok {
+		return &cycleError{v}
+	}
+	if n.depth < scanCyclesUntil {
+		n.path[n.depth] = ptr
+	} else {
+		if n.seen == nil {
+			n.seen = make(map[uintptr]struct{})
+		}
+		n.seen[ptr] = struct{}{}
+	}
+	n.depth++
+	return nil
+}
+
+func (n *normalizer) leave(v any) {
+	if n.depth--; n.depth >= scanCyclesUntil {
+		delete(n.seen, reflect.ValueOf(v).Pointer())
+	}
+}
+
+func isNormalizeError(v any) bool {
+	switch v.(type) {
+	case *cycleError, *invalidValueError:
+		return true
+	default:
+		return false
+	}
+}
+
+func decodeRawMessage(v json.RawMessage) (any, error) {
+	var w any
+	dec := json.NewDecoder(bytes.NewReader(v))
+	dec.UseNumber()
+	if err := dec.Decode(&w); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("unexpected trailing data")
+	}
+	return w, nil
+}
+
+// ValidateValue validates v as an input or a variable value of a query. The
+// valid values are nil, bool, string, int and other sized integer types,
+// float32, float64, *big.Int, json.Number, json.RawMessage, and []any and
+// map[string]any (or map[any]any with string keys, which is common in the
+// values decoded from YAML) of valid values without cycles. Although [Code.Run] accepts
+// values of other types and passes them to the custom functions, built-in
+// functions and operators fail to handle them. Use this function to check
+// values provided by the host application before running a query.
+func ValidateValue(v any) error {
+	return (&validator{}).validate(v)
+}
+
+type validator struct {
+	path []any
+	seen map[uintptr]struct{}
+}
+
+func (va *validator) validate(v any) error {
+	switch v := v.(type) {
+	case nil, bool, string, int, int64, int32, int16, int8,
+		uint, uint64, uint32, uint16, uint8, float32, float64, *big.Int:
+		return nil
+	case json.Number:
+		if _, err := strconv.ParseFloat(v.String(), 64); err != nil &&
+			!errors.Is(err, strconv.ErrRange) {
+			return va.error(v, err)
+		}
+		return nil
+	case json.RawMessage:
+		if _, err := decodeRawMessage(v); err != nil {
+			return va.error(v, err)
+		}
+		return nil
+	case []any:
+		if len(v) == 0 {
+			return nil
+		}
+		return va.validateContainer(v, func() error {
+			for i, x := range v {
+				va.path = append(va.path, i)
+				if err := va.validate(x); err != nil {
+					return err
+				}
+				va.path = va.path[:len(va.path)-1]
+			}
+			return nil
+		})
+	case map[string]any:
+		if len(v) == 0 {
+			return nil
+		}
+		return va.validateContainer(v, func() error {
+			for _, k := range keys(v) {
+				va.path = append(va.path, k)
+				if err := va.validate(v[k]); err != nil {
+					return err
+				}
+				va.path = va.path[:len(va.path)-1]
+			}
+			return nil
+		})
+	case map[any]any:
+		if len(v) == 0 {
+			return nil
+		}
+		return va.validateContainer(v, func() error {
+			ks := make([]string, 0, len(v))
+			for k := range v {
+				s, ok := k.(string)
+				if !ok {
+					return va.error(v, &objectKeyNotStringError{k})
+				}
+				ks = append(ks, s)
+			}
+			sort.Strings(ks)
+			for _, k := range ks {
+				va.path = append(va.path, k)
+				if err := va.validate(v[k]); err != nil {
+					return err
+				}
+				va.path = va.path[:len(va.path)-1]
+			}
+			return nil
+		})
+	default:
+		return va.error(v, nil)
+	}
+}
+
+func (va *validator) validateContainer(v any, f func() error) error {
+	if va.seen == nil {
+		va.seen = make(map[uintptr]struct{})
+	}
+	ptr := reflect.ValueOf(v).Pointer()
+	if _, ok := va.seen[ptr]; ok {
+		return &cycleError{v}
+	}
+	va.seen[ptr] = struct{}{}
+	defer delete(va.seen, ptr)
+	return f()
+}
+
+func (va *validator) error(v any, err error) error {
+	return &invalidValueError{v, append([]any{}, va.path...), err}
+}
@@ -0,0 +1,165 @@
+package gojq_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/rturpen/gojq"
+)
+
+func ExampleValidateValue() {
+	for _, v := range []any{
+		map[string]any{"foo": []any{1, json.Number("2.5"), json.RawMessage(`{"bar":3}`)}},
+		map[string]any{"foo": []any{1, []string{"bar"}}},
+		json.RawMessage(`{"foo":`),
+	} {
+		fmt.Println(gojq.ValidateValue(v))
+	}
+
+	// Output:
+	// <nil>
+	// invalid value at ["foo",1]: []string
+	// invalid value: unexpected EOF
+}
+
+func TestValidateValue(t *testing.T) {
+	xs := []any{1, nil}
+	xs[1] = xs
+	shared := []any{1}
+	testCases := []struct {
+		value    any
+		expected string
+	}{
+		{nil, ""},
+		{[]any{true, "x", int8(1), uint64(2), float32(3), new(big.Int)}, ""},
+		{map[string]any{"a": shared, "b": shared}, ""},
+		{json.Number("1e1000"), ""},
+		{json.Number("x"), `invalid value: strconv.ParseFloat: parsing "x": invalid syntax`},
+		{json.RawMessage(`1 2`), "invalid value: unexpected trailing data"},
+		{map[string]any{"a": map[string]string{}}, `invalid value at ["a"]: map[string]string`},
+		{map[any]any{"a": 1, "b": map[any]any{"c": []any{}}}, ""},
+		{map[string]any{"a": map[any]any{1: "x"}}, `invalid value at ["a"]: expected a string for object key but got: number (1)`},
+		{map[any]any{"a": map[any]any{"b": []int{}}}, `invalid value at ["a","b"]: []int`},
+		{xs, "encountered a cycle in value: array ([1,[1,[1,[1,[1,[1,[1,[1,[ ...])"},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprint(tc.expected), func(t *testing.T) {
+			err := gojq.ValidateValue(tc.value)
+			if tc.expected == "" {
+				if err != nil {
+					t.Errorf("expected no error but got: %v", err)
+				}
+			} else if err == nil || err.Error() != tc.expected {
+				t.Errorf("expected: %v, got: %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestCodeRun_MapAnyKeys(t *testing.T) {
+	query, err := gojq.Parse("keys, .[]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		options  []gojq.CompilerOption
+		value    any
+		expected []any
+	}{
+		{
+			nil,
+			map[any]any{"b": 1, "a": map[any]any{"c": json.Number("2")}},
+			[]any{[]any{"a", "b"}, map[string]any{"c": 2}, 1},
+		},
+		{
+			nil,
+			map[any]any{"a": 1, 2: 3},
+			[]any{"invalid value: expected a string for object key but got: number (2)"},
+		},
+		{
+			[]gojq.CompilerOption{gojq.WithStringifiedMapKeys()},
+			map[any]any{"a": 1, 2: 3, true: 4, nil: 5, 1.5: 6},
+			[]any{[]any{"1.5", "2", "a", "null", "true"}, 6, 3, 1, 5, 4},
+		},
+		{
+			[]gojq.CompilerOption{gojq.WithStringifiedMapKeys()},
+			[]any{map[any]any{"1": 1, 1: 2}},
+			[]any{`invalid value: duplicate object key: "1"`},
+		},
+	} {
+		code, err := gojq.Compile(query, tc.options...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		iter := code.Run(tc.value)
+		var got []any
+		for {
+			v, ok := iter.Next()
+			if !ok {
+				break
+			}
+			if err, ok := v.(error); ok {
+				v = err.Error()
+			}
+			got = append(got, v)
+		}
+		if !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("expected: %v, got: %v", tc.expected, got)
+		}
+	}
+}
+
+func TestCodeRun_RawMessage(t *testing.T) {
+	query, err := gojq.Parse(".foo + $x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := gojq.Compile(query, gojq.WithVariables([]string{"$x"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	iter := code.Run(json.RawMessage(`{"foo":[1]}`), json.RawMessage("[2.5]"))
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			break
+		}
+		if err, ok := v.(error); ok {
+			t.Fatal(err)
+		}
+		if expected := []any{1, 2.5}; !reflect.DeepEqual(v, expected) {
+			t.Errorf("expected: %v, got: %v", expected, v)
+		}
+	}
+}
+
+func TestCodeRun_VariableValuesNotModified(t *testing.T) {
+	query, err := gojq.Parse("$x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := gojq.Compile(query, gojq.WithVariables([]string{"$x"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := []any{json.Number("1")}
+	iter := code.Run(nil, values...)
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			break
+		}
+		if err, ok := v.(error); ok {
+			t.Fatal(err)
+		}
+	}
+	if _, err := code.RunBatch([]any{nil}, values...); err != nil {
+		t.Fatal(err)
+	}
+	if expected := []any{json.Number("1")}; !reflect.DeepEqual(values, expected) {
+		t.Errorf("expected: %v, got: %v", expected, values)
+	}
+}
@@ -292,6 +292,10 @@ func funcOpPlus(v any) any {
 func funcOpNegate(v any) any {
 	switch v := v.(type) {
 	case int:
+		if v == math.MinInt {
+			x := big.NewInt(int64(v))
+			return x.Neg(x)
+		}
 		return -v
 	case float64:
 		return -v
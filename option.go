@@ -1,10 +1,93 @@
 package gojq
 
-import "fmt"
+import (
+	"fmt"
+	"io"
+	"time"
+)
 
 // CompilerOption is a compiler option.
 type CompilerOption func(*compiler)
 
+// ParseOption is an option for [ParseWithOptions].
+type ParseOption func(*lexer)
+
+// WithMaxStringLiteralLength is a parse option to limit the length of string
+// literals in bytes, to guard against the queries embedding enormous strings.
+// Each part of a string interpolation is checked separately. The parser fails
+// with an error on exceeding the limit. Zero or negative means no limit.
+func WithMaxStringLiteralLength(n int) ParseOption {
+	return func(l *lexer) {
+		l.maxStringLength = n
+	}
+}
+
+// WithMaxArrayLiteralLength is a parse option to limit the number of elements
+// of array literals, which are the comma-separated queries directly inside the
+// brackets of array construction (not indexing nor destructuring patterns).
+// The parser fails with an error on exceeding the limit. Zero or negative means
+// no limit.
+func WithMaxArrayLiteralLength(n int) ParseOption {
+	return func(l *lexer) {
+		l.maxArrayLength = n
+	}
+}
+
+// WithMaxQueryDepth is a parse option to limit the nesting depth of the query,
+// to guard against the queries exhausting the stack on compiling or printing.
+// Each subquery, like the operands of operators and the queries in brackets,
+// counts as a level. The default limit is 10000. Zero or negative means no
+// limit.
+func WithMaxQueryDepth(n int) ParseOption {
+	return func(l *lexer) {
+		l.maxQueryDepth = n
+	}
+}
+
+// WithFileName is a parse option to set the file name of $__loc__, which is
+// "<top-level>" by default. The module loaders created by [NewModuleLoader]
+// and [NewFSModuleLoader] set the paths of the module files, like jq.
+func WithFileName(name string) ParseOption {
+	return func(l *lexer) {
+		l.fileName = name
+	}
+}
+
+// RunOption is an option for [Code.RunWithOptions].
+type RunOption func(*runOptions)
+
+type runOptions struct {
+	variables     map[string]any
+	isolateErrors bool
+}
+
+// WithRunVariables is a run option for the variable values by their names.
+// The names should be declared on compiling the query using [WithVariables]
+// (including the leading $), but the values are given on each run, so they
+// are convenient for values which change on each run, like request ids.
+func WithRunVariables(variables map[string]any) RunOption {
+	return func(o *runOptions) {
+		if o.variables == nil {
+			o.variables = make(map[string]any, len(variables))
+		}
+		for name, v := range variables {
+			o.variables[name] = v
+		}
+	}
+}
+
+// WithIsolatedErrors is a run option for [Code.RunInputs] to continue with the
+// next input when an input causes an error, instead of stopping the iterator.
+// The error is emitted as an [InputError] to tell which input causes it. This
+// is useful for the pipelines of the inputs like log records, where an invalid
+// input should not stop processing the following ones. The halt and
+// halt_error functions, and cancelling the context still stop the iterator.
+func WithIsolatedErrors() RunOption {
+	return func(o *runOptions) {
+		o.isolateErrors = true
+	}
+}
+
 // WithModuleLoader is a compiler option for module loader.
 // If you want to load modules from the filesystem, use [NewModuleLoader].
 func WithModuleLoader(moduleLoader ModuleLoader) CompilerOption {
@@ -13,15 +96,40 @@ func WithModuleLoader(moduleLoader ModuleLoader) CompilerOption {
 	}
 }
 
+// WithInitModules is a compiler option to enable or disable loading the init
+// modules by LoadInitModules of the module loader (like ~/.jq loaded by the
+// loader created by [NewModuleLoader]). The init modules are loaded by default,
+// so specify false to make the query independent of the user configuration.
+func WithInitModules(enabled bool) CompilerOption {
+	return func(c *compiler) {
+		c.skipInit = !enabled
+	}
+}
+
 // WithEnvironLoader is a compiler option for environment variables loader.
 // The OS environment variables are not accessible by default due to security
 // reasons. You can specify [os.Environ] as argument if you allow to access.
+// The loader is called on compiling the query, and the later value of the same
+// name takes precedence. Specify nil to disable the loader of an earlier option.
 func WithEnvironLoader(environLoader func() []string) CompilerOption {
 	return func(c *compiler) {
 		c.environLoader = environLoader
 	}
 }
 
+// WithDeterministic is a compiler option to make the results of the query
+// reproducible, for example, on taking snapshots of the outputs in CI. The now
+// function emits the given time, the environment variables are not accessible
+// even with [WithEnvironLoader], and the local time functions (localtime and
+// strflocaltime) use UTC instead of the local time zone. Note that the object
+// keys are always sorted and there are no random functions, so nothing else
+// depends on the running environment.
+func WithDeterministic(now time.Time) CompilerOption {
+	return func(c *compiler) {
+		c.deterministic, c.now = true, timeToEpoch(now)
+	}
+}
+
 // WithVariables is a compiler option for variable names. The variables can be
 // used in the query. You have to give the values to [*Code.Run] in the same order.
 func WithVariables(variables []string) CompilerOption {
@@ -40,7 +148,7 @@ func WithVariables(variables []string) CompilerOption {
 // accept a filter for its argument, or call another built-in function, then
 // use LoadInitModules of the module loader.
 func WithFunction(name string, minarity, maxarity int, f func(any, []any) any) CompilerOption {
-	return withFunction(name, minarity, maxarity, false, f)
+	return withFunction(name, minarity, maxarity, false, false, f)
 }
 
 // WithIterFunction is a compiler option for adding a custom iterator function.
@@ -49,14 +157,32 @@ func WithFunction(name string, minarity, maxarity int, f func(any, []any) any) C
 // non-iterator functions of the same name (with possibly different arities).
 // See also [NewIter], which can be used to convert values or an error to an Iter.
 func WithIterFunction(name string, minarity, maxarity int, f func(any, []any) Iter) CompilerOption {
-	return withFunction(name, minarity, maxarity, true,
+	return withFunction(name, minarity, maxarity, true, false,
 		func(v any, args []any) any {
 			return f(v, args)
 		},
 	)
 }
 
-func withFunction(name string, minarity, maxarity int, iter bool, f func(any, []any) any) CompilerOption {
+// WithFilterFunction is a compiler option for adding a custom function which
+// accepts filters for its arguments. This is like the [WithFunction] option,
+// but the arguments are not evaluated before calling the function. Instead,
+// the function receives [Filter] values, which can be applied to any values
+// by [Filter.Run], like `def f(g): [.[] | g];` applies g to the elements. You
+// cannot define both filter and non-filter functions of the same name.
+func WithFilterFunction(name string, minarity, maxarity int, f func(any, []Filter) any) CompilerOption {
+	return withFunction(name, minarity, maxarity, false, true,
+		func(v any, args []any) any {
+			filters := make([]Filter, len(args))
+			for i, arg := range args {
+				filters[i] = arg.(Filter)
+			}
+			return f(v, filters)
+		},
+	)
+}
+
+func withFunction(name string, minarity, maxarity int, iter, filter bool, f func(any, []any) any) CompilerOption {
 	if !(0 <= minarity && minarity <= maxarity && maxarity <= 30) {
 		panic(fmt.Sprintf("invalid arity for %q: %d, %d", name, minarity, maxarity))
 	}
@@ -69,6 +195,9 @@ func withFunction(name string, minarity, maxarity int, iter bool, f func(any, []
 			if fn.iter != iter {
 				panic(fmt.Sprintf("cannot define both iterator and non-iterator functions for %q", name))
 			}
+			if c.filterFuncs[name] != filter {
+				panic(fmt.Sprintf("cannot define both filter and non-filter functions for %q", name))
+			}
 			c.customFuncs[name] = function{
 				argcount | fn.argcount, iter,
 				func(x any, xs []any) any {
@@ -80,7 +209,90 @@ func withFunction(name string, minarity, maxarity int, iter bool, f func(any, []
 			}
 		} else {
 			c.customFuncs[name] = function{argcount, iter, f}
+			if filter {
+				if c.filterFuncs == nil {
+					c.filterFuncs = make(map[string]bool)
+				}
+				c.filterFuncs[name] = true
+			}
+		}
+	}
+}
+
+// WithDebugHandler is a compiler option for the handler of debug function.
+// The debug function calls the handler with ["DEBUG:", v] for the input v (in
+// the same format as jq prints to the standard error), and emits the input as
+// it is. Also, debug(msg) calls the handler with each value of msg. When the
+// handler returns an error, the debug function emits the error instead of the
+// input. Without this option, the debug messages are discarded. Use this
+// option to route the debug messages to the logger of your application.
+func WithDebugHandler(f func(msg any) error) CompilerOption {
+	return func(c *compiler) {
+		c.debugHandler = f
+	}
+}
+
+// WithStderr is a compiler option for the writer of stderr function. The
+// stderr function writes the input to the writer without a newline, as it is
+// for a string or in the compact JSON for the other values, and emits the
+// input as it is. Without this option, the outputs are discarded.
+func WithStderr(w io.Writer) CompilerOption {
+	return func(c *compiler) {
+		c.stderr = w
+	}
+}
+
+// WithStructuredErrors is a compiler option to catch the errors raised by
+// built-in functions and operators as objects, instead of error messages.
+// The object has "message", "type", "value", "path" and "offset" fields; for
+// example, try (1 + "a") catch . emits {"message": "cannot add: number (1)
+// and string (\"a\")", "type": "type_error", "value": [1, "a"], "path": null,
+// "offset": 5}. The type is one of "type_error", "index_error",
+// "arithmetic_error", "path_error", "function_error" and "error". The path is
+// the path of the value where the error is raised while tracking the paths
+// (like in path and the update-assignment operators), and the offset is the
+// byte offset of the failing query in the source text; they are null if
+// unknown (the offset is also unknown for the errors raised in the builtin
+// functions, and is the offset in the module source for the modules).
+// The errors raised by the error function and custom functions returning
+// [ValueError] are caught as their values as usual.
+func WithStructuredErrors() CompilerOption {
+	return func(c *compiler) {
+		c.structErrors = true
+	}
+}
+
+// WithStringifiedMapKeys is a compiler option to accept the inputs and the
+// variable values of map[any]any (like the values decoded by YAML decoders)
+// with non-string keys, by converting the keys to strings in JSON (like tojson
+// for numbers, true, false and null; the string keys are kept as they are).
+// Without this option, map[any]any with string keys is accepted, but a
+// non-string key is reported as an invalid value. Also, a key converted to the
+// same string as another key is reported as an invalid value.
+func WithStringifiedMapKeys() CompilerOption {
+	return func(c *compiler) {
+		c.stringifyKeys = true
+	}
+}
+
+// WithPrelude is a compiler option for the function definitions which are
+// available in the query, like the functions defined in the init modules of
+// the module loader. The source is parsed on calling this function, so create
+// the option once and reuse it on compiling the queries. Note that the
+// function definitions are compiled along with each query (the compiled code
+// is not shared between the queries), so a large prelude makes compiling every
+// query slower. The source can also include the module directives, but the
+// main query is ignored.
+func WithPrelude(src string) CompilerOption {
+	q, err := Parse(src)
+	return func(c *compiler) {
+		if err != nil {
+			if c.optionErr == nil {
+				c.optionErr = &queryParseError{"<prelude>", src, err}
+			}
+			return
 		}
+		c.preludes = append(c.preludes, q)
 	}
 }
 
@@ -89,8 +301,16 @@ func withFunction(name string, minarity, maxarity int, iter bool, f func(any, []
 // to distinguish the query input and the values for input(s) functions. For
 // example, consider using inputs with --null-input. If you want to allow
 // input(s) functions, create an [Iter] and use WithInputIter option.
+// The iterator is read on demand, so it can be a stream of unknown length.
+// When the iterator emits an error, input function emits it as an error.
+//
+// The peek_input function emits the next input without consuming it, or emits
+// nothing at the end of inputs. If the iterator implements Peek() (any, bool)
+// method, the function calls it, otherwise the next value is read ahead and
+// held until the following call of input; use [NewPeekIter] when the iterator
+// is also read outside the query.
 func WithInputIter(inputIter Iter) CompilerOption {
 	return func(c *compiler) {
-		c.inputIter = inputIter
+		c.inputIter = NewPeekIter(inputIter)
 	}
 }
@@ -0,0 +1,48 @@
+package gojq_test
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/rturpen/gojq"
+)
+
+func ExampleWithDebugHandler() {
+	query, err := gojq.Parse(`.[] | debug | debug("value: \(.)") | . * 2`)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	code, err := gojq.Compile(
+		query,
+		gojq.WithDebugHandler(func(msg any) error {
+			bs, err := gojq.Marshal(msg)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("log: %s\n", bs)
+			return nil
+		}),
+	)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	iter := code.Run([]any{1, 2})
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			break
+		}
+		if err, ok := v.(error); ok {
+			log.Fatalln(err)
+		}
+		fmt.Printf("%#v\n", v)
+	}
+
+	// Output:
+	// log: ["DEBUG:",1]
+	// log: ["DEBUG:","value: 1"]
+	// 2
+	// log: ["DEBUG:",2]
+	// log: ["DEBUG:","value: 2"]
+	// 4
+}
@@ -0,0 +1,43 @@
+package gojq_test
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/rturpen/gojq"
+)
+
+func ExampleWithDeterministic() {
+	query, err := gojq.Parse(`now, (now | todate), (now | strflocaltime("%H:%M %Z")), env`)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	code, err := gojq.Compile(
+		query,
+		gojq.WithDeterministic(time.Date(2015, 3, 5, 23, 51, 47, 0, time.UTC)),
+		gojq.WithEnvironLoader(func() []string {
+			return []string{"foo=42"}
+		}),
+	)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	iter := code.Run(nil)
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			break
+		}
+		if err, ok := v.(error); ok {
+			log.Fatalln(err)
+		}
+		fmt.Printf("%#v\n", v)
+	}
+
+	// Output:
+	// 1.425599507e+09
+	// "2015-03-05T23:51:47Z"
+	// "23:51 UTC"
+	// map[string]interface {}{}
+}
@@ -0,0 +1,58 @@
+package gojq_test
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/rturpen/gojq"
+)
+
+func ExampleWithFilterFunction() {
+	query, err := gojq.Parse(`.factor as $x | .values | sum_by(. * $x)`)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	code, err := gojq.Compile(
+		query,
+		gojq.WithFilterFunction("sum_by", 1, 1, func(v any, filters []gojq.Filter) any {
+			xs, ok := v.([]any)
+			if !ok {
+				return fmt.Errorf("sum_by cannot be applied to: %v", v)
+			}
+			var sum float64
+			for _, x := range xs {
+				iter := filters[0].Run(x)
+				for {
+					y, ok := iter.Next()
+					if !ok {
+						break
+					}
+					if err, ok := y.(error); ok {
+						return err
+					}
+					if y, ok := y.(int); ok {
+						sum += float64(y)
+					}
+				}
+			}
+			return sum
+		}),
+	)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	iter := code.Run(map[string]any{"factor": 10, "values": []any{1, 2, 3}})
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			break
+		}
+		if err, ok := v.(error); ok {
+			log.Fatalln(err)
+		}
+		fmt.Printf("%#v\n", v)
+	}
+
+	// Output:
+	// 60
+}
@@ -3,6 +3,7 @@ package gojq_test
 import (
 	"fmt"
 	"log"
+	"testing/fstest"
 
 	"github.com/rturpen/gojq"
 )
@@ -58,3 +59,40 @@ func ExampleWithModuleLoader() {
 	// Output:
 	// 42
 }
+
+func ExampleNewFSModuleLoader() {
+	fsys := fstest.MapFS{
+		"lib/math.jq":        {Data: []byte(`def double: . * 2;`)},
+		"lib/util/util.jq":   {Data: []byte(`include "math"; def quadruple: double | double;`)},
+		"lib/constants.json": {Data: []byte(`{"answer": 42}`)},
+	}
+	query, err := gojq.Parse(`
+		import "util" as util;
+		import "constants" as $c;
+		$c::c[0].answer | util::quadruple
+	`)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	code, err := gojq.Compile(
+		query,
+		gojq.WithModuleLoader(gojq.NewFSModuleLoader(fsys, []string{"lib"})),
+	)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	iter := code.Run(nil)
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			break
+		}
+		if err, ok := v.(error); ok {
+			log.Fatalln(err)
+		}
+		fmt.Printf("%#v\n", v)
+	}
+
+	// Output:
+	// 168
+}
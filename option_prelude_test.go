@@ -0,0 +1,42 @@
+package gojq_test
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/rturpen/gojq"
+)
+
+func ExampleWithPrelude() {
+	prelude := gojq.WithPrelude(`
+		def sum: reduce .[] as $x (0; . + $x);
+		def average: sum / length;
+	`)
+	for _, src := range []string{"sum", "average", "def sum: 0; sum, average"} {
+		query, err := gojq.Parse(src)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		code, err := gojq.Compile(query, prelude)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		iter := code.Run([]any{1, 2, 3, 4})
+		for {
+			v, ok := iter.Next()
+			if !ok {
+				break
+			}
+			if err, ok := v.(error); ok {
+				log.Fatalln(err)
+			}
+			fmt.Printf("%#v\n", v)
+		}
+	}
+
+	// Output:
+	// 10
+	// 2.5
+	// 0
+	// 2.5
+}
@@ -0,0 +1,47 @@
+package gojq_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/rturpen/gojq"
+)
+
+func ExampleWithRunVariables() {
+	query, err := gojq.Parse(`{id: $request_id, value: (. * $factor)}`)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	code, err := gojq.Compile(
+		query,
+		gojq.WithVariables([]string{
+			"$factor", "$request_id",
+		}),
+	)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	for i, id := range []string{"a", "b"} {
+		iter := code.RunWithOptions(context.Background(), i+1,
+			gojq.WithRunVariables(map[string]any{
+				"$request_id": id,
+				"$factor":     10,
+			}),
+		)
+		for {
+			v, ok := iter.Next()
+			if !ok {
+				break
+			}
+			if err, ok := v.(error); ok {
+				log.Fatalln(err)
+			}
+			fmt.Printf("%v\n", v)
+		}
+	}
+
+	// Output:
+	// map[id:a value:10]
+	// map[id:b value:20]
+}
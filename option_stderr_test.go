@@ -0,0 +1,38 @@
+package gojq_test
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/rturpen/gojq"
+)
+
+func ExampleWithStderr() {
+	query, err := gojq.Parse(`.[] | stderr | . + "!"`)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	var sb strings.Builder
+	code, err := gojq.Compile(query, gojq.WithStderr(&sb))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	iter := code.Run([]any{"a", "b"})
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			break
+		}
+		if err, ok := v.(error); ok {
+			log.Fatalln(err)
+		}
+		fmt.Printf("%#v\n", v)
+	}
+	fmt.Printf("%q\n", sb.String())
+
+	// Output:
+	// "a!"
+	// "b!"
+	// "ab"
+}
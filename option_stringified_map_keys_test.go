@@ -0,0 +1,40 @@
+package gojq_test
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/rturpen/gojq"
+)
+
+func ExampleWithStringifiedMapKeys() {
+	query, err := gojq.Parse(".ports | keys")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	code, err := gojq.Compile(
+		query,
+		gojq.WithStringifiedMapKeys(),
+	)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	// A value decoded from YAML like "ports: {80: http, 443: https}".
+	input := map[any]any{
+		"ports": map[any]any{80: "http", 443: "https"},
+	}
+	iter := code.Run(input)
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			break
+		}
+		if err, ok := v.(error); ok {
+			log.Fatalln(err)
+		}
+		fmt.Printf("%#v\n", v)
+	}
+
+	// Output:
+	// []interface {}{"443", "80"}
+}
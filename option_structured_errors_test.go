@@ -0,0 +1,38 @@
+package gojq_test
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/rturpen/gojq"
+)
+
+func ExampleWithStructuredErrors() {
+	query, err := gojq.Parse(`.[] | try (1 + .) catch (if .type == "type_error" then 0 else error end)`)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	code, err := gojq.Compile(
+		query,
+		gojq.WithStructuredErrors(),
+	)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	iter := code.Run([]any{1, "a", 2})
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			break
+		}
+		if err, ok := v.(error); ok {
+			log.Fatalln(err)
+		}
+		fmt.Printf("%#v\n", v)
+	}
+
+	// Output:
+	// 2
+	// 0
+	// 3
+}
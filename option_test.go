@@ -1,11 +1,16 @@
 package gojq_test
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+	"testing/fstest"
 
 	"github.com/rturpen/gojq"
 )
@@ -155,6 +160,49 @@ func (*moduleLoaderInitModules) LoadInitModules() ([]*gojq.Query, error) {
 	return []*gojq.Query{query}, nil
 }
 
+func TestWithModuleLoader_JSONOnly(t *testing.T) {
+	query, err := gojq.Parse(`import "module1" as m; m::f`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = gojq.Compile(
+		query,
+		gojq.WithModuleLoader(&moduleLoaderJSON{}),
+	)
+	if got, expected := fmt.Sprint(err), `module not found: "module1"`; got != expected {
+		t.Errorf("expected: %v, got: %v", expected, got)
+	}
+
+	query, err = gojq.Parse(`import "module1" as $m; $m::m, ("module1" | modulemeta)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := gojq.Compile(
+		query,
+		gojq.WithModuleLoader(&moduleLoaderJSON{}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []any
+	iter := code.Run(nil)
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			break
+		}
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		got = append(got, v)
+	}
+	if expected := []any{
+		[]any{1.0, 42, 123}, `module not found: "module1"`,
+	}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected: %v, got: %v", expected, got)
+	}
+}
+
 func TestWithModuleLoader_LoadInitModules(t *testing.T) {
 	query, err := gojq.Parse("g")
 	if err != nil {
@@ -179,6 +227,123 @@ func TestWithModuleLoader_LoadInitModules(t *testing.T) {
 	}
 }
 
+func TestWithInitModules(t *testing.T) {
+	query, err := gojq.Parse("g")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = gojq.Compile(
+		query,
+		gojq.WithModuleLoader(&moduleLoaderInitModules{}),
+		gojq.WithInitModules(false),
+	)
+	if got, expected := fmt.Sprint(err), "function not defined: g/0"; got != expected {
+		t.Errorf("expected: %v, got: %v", expected, got)
+	}
+	_, err = gojq.Compile(
+		query,
+		gojq.WithModuleLoader(&moduleLoaderInitModules{}),
+		gojq.WithInitModules(false),
+		gojq.WithInitModules(true),
+	)
+	if err != nil {
+		t.Errorf("should load the init modules: %v", err)
+	}
+}
+
+func TestNewModuleLoader_origin(t *testing.T) {
+	executable, err := os.Executable()
+	if err != nil {
+		t.Skip(err)
+	}
+	if executable, err = filepath.EvalSymlinks(executable); err != nil {
+		t.Skip(err)
+	}
+	dir, err := os.MkdirTemp(filepath.Dir(executable), "lib")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.WriteFile(filepath.Join(dir, "m.jq"), []byte(`def f: "origin";`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	query, err := gojq.Parse(`import "m" as m; m::f`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := gojq.Compile(
+		query,
+		gojq.WithModuleLoader(gojq.NewModuleLoader(
+			[]string{"$ORIGIN/" + filepath.Base(dir)},
+		)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := code.Run(nil).Next(); got != "origin" {
+		t.Errorf("expected: %v, got: %v", "origin", got)
+	}
+}
+
+func TestNewFSModuleLoader(t *testing.T) {
+	fsys := fstest.MapFS{
+		".jq":            {Data: []byte(`def init: "init";`)},
+		"lib/a.jq":       {Data: []byte(`import "b" as b {search: "../other"}; def f: b::g;`)},
+		"lib/c/c.jq":     {Data: []byte(`module {version: 1}; def h: "c";`)},
+		"lib/c/g/g.jq":   {Data: []byte(`def i: "g";`)},
+		"lib/d.json":     {Data: []byte(`1 [2]`)},
+		"lib/e.json":     {Data: []byte(`{`)},
+		"lib/f.jq":       {Data: []byte(`def f: `)},
+		"other/b.jq":     {Data: []byte(`def g: "b";`)},
+		"other/a.jq":     {Data: []byte(`def f: "other";`)},
+		"lib/skip/.jq/x": {Data: []byte(``)},
+	}
+	loader := gojq.NewFSModuleLoader(fsys, []string{".jq", "lib", "lib/skip/.jq"})
+	for _, tc := range []struct {
+		src      string
+		expected any
+		err      string
+	}{
+		{src: `init`, expected: "init"},
+		{src: `import "a" as a; a::f`, expected: "b"},
+		{src: `include "c"; h`, expected: "c"},
+		{src: `"c" | modulemeta | .version`, expected: 1},
+		{src: `import "c/g" as g; g::i`, expected: "g"},
+		{src: `import "d" as $d; $d::d`, expected: []any{1, []any{2}}},
+		{src: `import "b" as b; b::g`, err: `module not found: "b"`},
+		{src: `import "../other/b" as b; b::g`, expected: "b"},
+		{src: `import "../../b" as b; b::g`, err: `module not found: "../../b"`},
+		{src: `import "e" as $e; $e`, err: "invalid json: lib/e.json: unexpected EOF"},
+		{src: `include "f"; .`, err: "invalid query: lib/f.jq: unexpected EOF"},
+	} {
+		t.Run(tc.src, func(t *testing.T) {
+			query, err := gojq.Parse(tc.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			code, err := gojq.Compile(query, gojq.WithModuleLoader(loader))
+			if err != nil {
+				if tc.err == "" || err.Error() != tc.err {
+					t.Fatalf("expected: %v, got: %v", tc.err, err)
+				}
+				return
+			}
+			got, _ := code.Run(nil).Next()
+			if err, ok := got.(error); ok {
+				if tc.err == "" || err.Error() != tc.err {
+					t.Fatalf("expected: %v, got: %v", tc.err, err)
+				}
+				return
+			}
+			if tc.err != "" {
+				t.Errorf("expected error: %v, got: %v", tc.err, got)
+			} else if !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("expected: %v, got: %v", tc.expected, got)
+			}
+		})
+	}
+}
+
 func TestWithEnvironLoader(t *testing.T) {
 	query, err := gojq.Parse("env")
 	if err != nil {
@@ -206,6 +371,41 @@ func TestWithEnvironLoader(t *testing.T) {
 	}
 }
 
+func TestWithEnvironLoaderOverride(t *testing.T) {
+	query, err := gojq.Parse("[env, $ENV]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		options  []gojq.CompilerOption
+		expected map[string]any
+	}{
+		{
+			[]gojq.CompilerOption{
+				gojq.WithEnvironLoader(func() []string { return []string{"foo=42"} }),
+				gojq.WithEnvironLoader(func() []string { return []string{"bar=128", "bar=256"} }),
+			},
+			map[string]any{"bar": "256"},
+		},
+		{
+			[]gojq.CompilerOption{
+				gojq.WithEnvironLoader(func() []string { return []string{"foo=42"} }),
+				gojq.WithEnvironLoader(nil),
+			},
+			map[string]any{},
+		},
+	} {
+		code, err := gojq.Compile(query, tc.options...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, _ := code.Run(nil).Next()
+		if expected := []any{tc.expected, tc.expected}; !reflect.DeepEqual(got, expected) {
+			t.Errorf("expected: %v, got: %v", expected, got)
+		}
+	}
+}
+
 func TestWithEnvironLoaderEmpty(t *testing.T) {
 	query, err := gojq.Parse("env")
 	if err != nil {
@@ -373,6 +573,34 @@ func TestWithFunction(t *testing.T) {
 	}
 }
 
+func TestWithFunctionBuiltins(t *testing.T) {
+	query, err := gojq.Parse(
+		`builtins | length == (unique | length), . == sort, map(select(startswith("_") or startswith("debug/")))`,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := gojq.Compile(query,
+		gojq.WithFunction("debug", 0, 0, func(x any, _ []any) any { return x }),
+		gojq.WithFunction("_f", 0, 0, func(x any, _ []any) any { return x }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []any
+	iter := code.Run(nil)
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			break
+		}
+		got = append(got, v)
+	}
+	if expected := []any{true, true, []any{"debug/0", "debug/1"}}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected: %v, got: %v", expected, got)
+	}
+}
+
 func TestWithFunctionDuplicateName(t *testing.T) {
 	options := []gojq.CompilerOption{
 		gojq.WithFunction("f", 0, 0, func(x any, _ []any) any {
@@ -919,3 +1147,529 @@ func TestWithInputIter(t *testing.T) {
 		t.Errorf("expected: %v, got: %v", expected, n)
 	}
 }
+
+func TestWithInputIterNotAllowed(t *testing.T) {
+	for _, src := range []string{"input", "[inputs]"} {
+		query, err := gojq.Parse(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = gojq.Compile(query)
+		if expected := "input(s)/0 is not allowed"; err == nil || err.Error() != expected {
+			t.Errorf("%s: expected: %v, got: %v", src, expected, err)
+		}
+	}
+}
+
+type countIter struct {
+	xs    []any
+	count int
+}
+
+func (iter *countIter) Next() (any, bool) {
+	if len(iter.xs) == 0 {
+		return nil, false
+	}
+	iter.count++
+	v := iter.xs[0]
+	iter.xs = iter.xs[1:]
+	return v, true
+}
+
+func TestWithInputIterOnDemand(t *testing.T) {
+	query, err := gojq.Parse("first(inputs | select(. > 1)), input")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inputIter := &countIter{xs: []any{1, 2, 3, 4, 5}}
+	code, err := gojq.Compile(query, gojq.WithInputIter(inputIter))
+	if err != nil {
+		t.Fatal(err)
+	}
+	iter := code.Run(nil)
+	var got []any
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			break
+		}
+		if err, ok := v.(error); ok {
+			t.Fatal(err)
+		}
+		got = append(got, v)
+	}
+	if expected := []any{2, 3}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected: %v, got: %v", expected, got)
+	}
+	if expected := 3; inputIter.count != expected {
+		t.Errorf("expected %d inputs to be read but got: %d", expected, inputIter.count)
+	}
+}
+
+func TestNewPeekIter(t *testing.T) {
+	query, err := gojq.Parse("[., peek_input]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inputs := gojq.NewPeekIter(gojq.NewIter(1, 2, 3))
+	code, err := gojq.Compile(query, gojq.WithInputIter(inputs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []any
+	for {
+		v, ok := inputs.Next()
+		if !ok {
+			break
+		}
+		iter := code.Run(v)
+		for {
+			v, ok := iter.Next()
+			if !ok {
+				break
+			}
+			if err, ok := v.(error); ok {
+				t.Fatal(err)
+			}
+			got = append(got, v)
+		}
+	}
+	if expected := []any{[]any{1, 2}, []any{2, 3}, []any{3}}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected: %v, got: %v", expected, got)
+	}
+}
+
+func TestWithInputIterError(t *testing.T) {
+	query, err := gojq.Parse("try [inputs] catch ., [inputs]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := gojq.Compile(
+		query,
+		gojq.WithInputIter(gojq.NewIter(1, errors.New("invalid input"), 2)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iter := code.Run(nil)
+	var got []any
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			break
+		}
+		if err, ok := v.(error); ok {
+			t.Fatal(err)
+		}
+		got = append(got, v)
+	}
+	if expected := []any{"invalid input", []any{2}}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected: %v, got: %v", expected, got)
+	}
+}
+
+func TestWithStructuredErrors(t *testing.T) {
+	query, err := gojq.Parse(`.[] as $f | try ($f | fromjson) catch ., try error({x: $f}) catch ., try .[$f] catch ., try (1 / 0) catch ., try path(.[0] | .x) catch .`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := gojq.Compile(
+		query,
+		gojq.WithStructuredErrors(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iter := code.Run([]any{"{"})
+	for _, expected := range []any{
+		map[string]any{
+			"message": "fromjson cannot be applied to \"{\": unexpected EOF",
+			"type":    "function_error",
+			"value":   "{",
+			"path":    nil,
+			"offset":  22,
+		},
+		map[string]any{"x": "{"},
+		map[string]any{
+			"message": "expected an object but got: array ([\"{\"])",
+			"type":    "type_error",
+			"value":   []any{"{"},
+			"path":    nil,
+			"offset":  73,
+		},
+		map[string]any{
+			"message": "cannot divide number (1) by: number (0)",
+			"type":    "arithmetic_error",
+			"value":   []any{1, 0},
+			"path":    nil,
+			"offset":  93,
+		},
+		map[string]any{
+			"message": "expected an object but got: string (\"{\")",
+			"type":    "type_error",
+			"value":   "{",
+			"path":    []any{0},
+			"offset":  125,
+		},
+	} {
+		v, ok := iter.Next()
+		if !ok {
+			t.Fatalf("expected: %v, got no output", expected)
+		}
+		if !reflect.DeepEqual(v, expected) {
+			t.Errorf("expected: %v, got: %v", expected, v)
+		}
+	}
+	if v, ok := iter.Next(); ok {
+		t.Errorf("should not emit a value but got: %v", v)
+	}
+}
+
+func TestWithFilterFunction(t *testing.T) {
+	collect := func(v any, filters []gojq.Filter) any {
+		xs := []any{}
+		for _, f := range filters {
+			iter := f.Run(v)
+			for {
+				x, ok := iter.Next()
+				if !ok {
+					break
+				}
+				if err, ok := x.(error); ok {
+					return err
+				}
+				xs = append(xs, x)
+			}
+		}
+		return xs
+	}
+	testCases := []struct {
+		src      string
+		input    any
+		expected []any
+	}{
+		{
+			"collect(.[], empty; .[0] + 1)",
+			[]any{1, 2},
+			[]any{[]any{1, 2, 2}},
+		},
+		{
+			"1 as $x | 2 as $y | collect($x, $y, .)",
+			3,
+			[]any{[]any{1, 2, 3}},
+		},
+		{
+			"def f(g): 10 as $x | collect(g + $x); 1 as $x | f(. + $x)",
+			0,
+			[]any{[]any{11}},
+		},
+		{
+			"def f: if . < 3 then collect(. + 1 | f) else . end; f",
+			0,
+			[]any{[]any{[]any{[]any{3}}}},
+		},
+		{
+			"reduce .[] as $x ([]; . + collect($x * 2))",
+			[]any{1, 2, 3},
+			[]any{[]any{2, 4, 6}},
+		},
+		{
+			"collect(.[] | collect(range(.)))",
+			[]any{1, 2},
+			[]any{[]any{[]any{0}, []any{0, 1}}},
+		},
+		{
+			".[] as $x | collect($x, (label $out | 1, break $out, 2))",
+			[]any{1, 2},
+			[]any{[]any{1, 1}, []any{2, 1}},
+		},
+		{
+			"try collect(error(\"x\")) catch .",
+			nil,
+			[]any{"x"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.src, func(t *testing.T) {
+			query, err := gojq.Parse(tc.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			code, err := gojq.Compile(
+				query,
+				gojq.WithFilterFunction("collect", 1, 2, collect),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			iter := code.Run(tc.input)
+			got := []any{}
+			for {
+				v, ok := iter.Next()
+				if !ok {
+					break
+				}
+				if err, ok := v.(error); ok {
+					t.Fatal(err)
+				}
+				got = append(got, v)
+			}
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("expected: %v, got: %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestWithFilterFunctionDefineError(t *testing.T) {
+	query, err := gojq.Parse("f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		expected := `cannot define both filter and non-filter functions for "f"`
+		if got := recover(); got != expected {
+			t.Errorf("expected: %v, got: %v", expected, got)
+		}
+	}()
+	t.Fatal(gojq.Compile(query,
+		gojq.WithFunction("f", 0, 0, func(any, []any) any {
+			return 0
+		}),
+		gojq.WithFilterFunction("f", 1, 1, func(any, []gojq.Filter) any {
+			return 0
+		}),
+	))
+}
+
+func TestWithRunVariablesError(t *testing.T) {
+	query, err := gojq.Parse("[$x, $y]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := gojq.Compile(
+		query,
+		gojq.WithVariables([]string{"$x", "$y"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		variables map[string]any
+		expected  string
+	}{
+		{map[string]any{"$x": 1}, "variable defined but not bound: $y"},
+		{map[string]any{"$x": 1, "$y": 2, "$z": 3}, "variable not defined: $z"},
+		{map[string]any{"x": 1, "y": 2}, "variable defined but not bound: $x"},
+	} {
+		iter := code.RunWithOptions(context.Background(), nil,
+			gojq.WithRunVariables(tc.variables),
+		)
+		v, ok := iter.Next()
+		if !ok {
+			t.Fatal("should emit an error but got no output")
+		}
+		if err, ok := v.(error); ok {
+			if err.Error() != tc.expected {
+				t.Errorf("expected: %v, got: %v", tc.expected, err)
+			}
+		} else {
+			t.Errorf("should emit an error but got: %v", v)
+		}
+	}
+	iter := code.RunWithOptions(context.Background(), nil,
+		gojq.WithRunVariables(map[string]any{"$x": 1}),
+		gojq.WithRunVariables(map[string]any{"$y": 2}),
+	)
+	if v, _ := iter.Next(); !reflect.DeepEqual(v, []any{1, 2}) {
+		t.Errorf("expected: %v, got: %v", []any{1, 2}, v)
+	}
+}
+
+func TestWithPreludeError(t *testing.T) {
+	query, err := gojq.Parse("f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		src      string
+		expected string
+	}{
+		{"def f: ", "invalid query: <prelude>: unexpected EOF"},
+		{"def f: g;", "function not defined: g/0"},
+		{`import "m" as m; def f: m::g;`, `cannot load module: "m"`},
+	} {
+		_, err = gojq.Compile(query, gojq.WithPrelude(tc.src))
+		if err == nil || err.Error() != tc.expected {
+			t.Errorf("expected: %v, got: %v", tc.expected, err)
+		}
+	}
+}
+
+func TestWithDebugHandler(t *testing.T) {
+	query, err := gojq.Parse(`debug, debug(.[], "x"), [debug]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msgs []any
+	for _, tc := range []struct {
+		name     string
+		options  []gojq.CompilerOption
+		expected []any
+		messages []any
+	}{
+		{
+			name: "handler",
+			options: []gojq.CompilerOption{
+				gojq.WithDebugHandler(func(msg any) error { msgs = append(msgs, msg); return nil }),
+			},
+			expected: []any{[]any{1, 2}, []any{1, 2}, []any{[]any{1, 2}}},
+			messages: []any{
+				[]any{"DEBUG:", []any{1, 2}},
+				[]any{"DEBUG:", 1},
+				[]any{"DEBUG:", 2},
+				[]any{"DEBUG:", "x"},
+				[]any{"DEBUG:", []any{1, 2}},
+			},
+		},
+		{
+			name:     "no handler",
+			expected: []any{[]any{1, 2}, []any{1, 2}, []any{[]any{1, 2}}},
+		},
+		{
+			name: "custom function",
+			options: []gojq.CompilerOption{
+				gojq.WithDebugHandler(func(msg any) error { msgs = append(msgs, msg); return nil }),
+				gojq.WithFunction("debug", 0, 0, func(v any, _ []any) any {
+					return []any{"custom", v}
+				}),
+			},
+			expected: []any{
+				[]any{"custom", []any{1, 2}}, []any{1, 2},
+				[]any{[]any{"custom", []any{1, 2}}},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			msgs = nil
+			code, err := gojq.Compile(query, tc.options...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []any
+			iter := code.Run([]any{1, 2})
+			for {
+				v, ok := iter.Next()
+				if !ok {
+					break
+				}
+				if err, ok := v.(error); ok {
+					t.Fatal(err)
+				}
+				got = append(got, v)
+			}
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("expected: %v, got: %v", tc.expected, got)
+			}
+			if !reflect.DeepEqual(msgs, tc.messages) {
+				t.Errorf("expected messages: %v, got: %v", tc.messages, msgs)
+			}
+		})
+	}
+}
+
+func TestWithDebugHandlerError(t *testing.T) {
+	query, err := gojq.Parse(`[.[] | try debug catch "error: \(.)"]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := gojq.Compile(query,
+		gojq.WithDebugHandler(func(msg any) error {
+			if msg.([]any)[1] == 2 {
+				return errors.New("cannot write")
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iter := code.Run([]any{1, 2, 3})
+	v, _ := iter.Next()
+	if expected := []any{1, "error: cannot write", 3}; !reflect.DeepEqual(v, expected) {
+		t.Errorf("expected: %v, got: %v", expected, v)
+	}
+}
+
+type errWriter struct{}
+
+func (errWriter) Write([]byte) (int, error) {
+	return 0, errors.New("write error")
+}
+
+func TestWithStderr(t *testing.T) {
+	query, err := gojq.Parse(`stderr, [.[] | stderr]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sb strings.Builder
+	for _, tc := range []struct {
+		name     string
+		options  []gojq.CompilerOption
+		expected []any
+		output   string
+	}{
+		{
+			name:     "writer",
+			options:  []gojq.CompilerOption{gojq.WithStderr(&sb)},
+			expected: []any{[]any{"a", 1, nil}, []any{"a", 1, nil}},
+			output:   `["a",1,null]a1null`,
+		},
+		{
+			name:     "no writer",
+			expected: []any{[]any{"a", 1, nil}, []any{"a", 1, nil}},
+		},
+		{
+			name:     "write error",
+			options:  []gojq.CompilerOption{gojq.WithStderr(errWriter{})},
+			expected: []any{errors.New("write error")},
+		},
+		{
+			name: "custom function",
+			options: []gojq.CompilerOption{
+				gojq.WithStderr(&sb),
+				gojq.WithFunction("stderr", 0, 0, func(v any, _ []any) any {
+					return "custom"
+				}),
+			},
+			expected: []any{"custom", []any{"custom", "custom", "custom"}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sb.Reset()
+			code, err := gojq.Compile(query, tc.options...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []any
+			iter := code.Run([]any{"a", 1, nil})
+			for {
+				v, ok := iter.Next()
+				if !ok {
+					break
+				}
+				if err, ok := v.(error); ok {
+					got = append(got, errors.New(err.Error()))
+					break
+				}
+				got = append(got, v)
+			}
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("expected: %v, got: %v", tc.expected, got)
+			}
+			if got := sb.String(); got != tc.output {
+				t.Errorf("expected output: %q, got: %q", tc.output, got)
+			}
+		})
+	}
+}
@@ -5,19 +5,33 @@ package gojq
 
 import __yyfmt__ "fmt"
 
+//line parser.go.y:2
+
 // Parse a query string, and returns the query struct.
 //
-// If parsing failed, the returned error has the method Token() (string, int),
-// which reports the invalid token and the byte offset in the query string. The
-// token is empty if the error occurred after scanning the entire query string.
-// The byte offset is the scanned bytes when the error occurred.
-//
-//line parser.go.y:2
+// If parsing failed, the returned error implements [ParseError], which reports
+// the invalid token with its byte offset, line and column in the query string.
 func Parse(src string) (*Query, error) {
+	return ParseWithOptions(src)
+}
+
+// ParseWithOptions parses a query string with the parse options. Use this
+// function to limit the sizes of the literals in the queries from untrusted
+// sources, see [WithMaxStringLiteralLength] and [WithMaxArrayLiteralLength].
+// The nesting depth of the query is limited by default, see
+// [WithMaxQueryDepth].
+func ParseWithOptions(src string, options ...ParseOption) (*Query, error) {
 	l := newLexer(src)
-	if yyParse(l) > 0 {
+	l.maxQueryDepth = defaultMaxQueryDepth
+	for _, opt := range options {
+		opt(l)
+	}
+	if yyParse(l) > 0 || l.err != nil {
 		return nil, l.err
 	}
+	if err := l.checkDepth(); err != nil {
+		return nil, err
+	}
 	return l.result, nil
 }
 
@@ -35,12 +49,13 @@ func prependFuncDef(xs []*FuncDef, x *FuncDef) []*FuncDef {
 	return xs
 }
 
-//line parser.go.y:33
+//line parser.go.y:47
 type yySymType struct {
 	yys      int
 	value    any
 	token    string
 	operator Operator
+	offset   int
 }
 
 const tokAltOp = 57346
@@ -159,7 +174,7 @@ const yyEofCode = 1
 const yyErrCode = 2
 const yyInitialStackSize = 16
 
-//line parser.go.y:693
+//line parser.go.y:746
 
 //line yacctab:1
 var yyExca = [...]int16{
@@ -169,174 +184,174 @@ var yyExca = [...]int16{
 	-1, 97,
 	55, 0,
 	-2, 104,
-	-1, 130,
+	-1, 131,
 	5, 0,
 	-2, 32,
-	-1, 133,
+	-1, 134,
 	9, 0,
 	-2, 35,
-	-1, 194,
-	58, 114,
+	-1, 196,
+	58, 116,
 	-2, 54,
 }
 
 const yyPrivate = 57344
 
-const yyLast = 1127
+const yyLast = 1123
 
 var yyAct = [...]int16{
-	86, 214, 174, 112, 12, 203, 9, 175, 111, 31,
-	190, 6, 156, 140, 117, 47, 95, 97, 93, 94,
-	89, 141, 49, 7, 179, 180, 181, 240, 246, 264,
-	239, 103, 177, 106, 178, 227, 164, 119, 107, 108,
-	105, 245, 102, 75, 76, 113, 77, 78, 79, 123,
-	226, 163, 211, 225, 259, 210, 142, 179, 180, 181,
-	158, 159, 143, 182, 122, 177, 224, 178, 219, 7,
-	235, 234, 104, 127, 243, 128, 129, 130, 131, 132,
-	133, 134, 135, 136, 137, 138, 72, 74, 80, 81,
-	82, 83, 84, 147, 73, 88, 182, 196, 73, 229,
-	195, 145, 7, 150, 228, 161, 166, 165, 157, 126,
-	125, 124, 144, 88, 258, 167, 80, 81, 82, 83,
-	84, 206, 73, 44, 242, 91, 90, 92, 183, 184,
-	82, 83, 84, 154, 73, 153, 267, 186, 49, 173,
-	42, 43, 100, 91, 90, 92, 99, 191, 120, 197,
-	256, 257, 200, 192, 201, 202, 188, 75, 76, 207,
-	77, 78, 79, 198, 199, 209, 42, 43, 216, 92,
-	215, 215, 218, 213, 113, 98, 75, 76, 185, 77,
-	78, 79, 204, 205, 101, 221, 222, 170, 155, 171,
-	169, 3, 28, 27, 230, 96, 220, 232, 176, 46,
-	223, 11, 80, 81, 82, 83, 84, 11, 73, 78,
-	79, 157, 241, 110, 8, 152, 237, 255, 236, 72,
-	74, 80, 81, 82, 83, 84, 85, 73, 79, 278,
-	160, 191, 277, 121, 189, 253, 254, 192, 248, 247,
-	187, 139, 249, 250, 208, 262, 260, 261, 215, 263,
-	80, 81, 82, 83, 84, 149, 73, 268, 269, 10,
-	270, 5, 4, 2, 1, 88, 272, 273, 80, 81,
-	82, 83, 84, 0, 73, 279, 0, 0, 271, 280,
-	51, 52, 0, 53, 54, 55, 56, 57, 58, 59,
-	60, 61, 62, 115, 116, 91, 90, 92, 0, 0,
-	42, 43, 0, 87, 63, 64, 65, 66, 67, 68,
-	69, 70, 71, 88, 0, 20, 0, 17, 37, 24,
+	86, 216, 176, 112, 12, 192, 9, 205, 47, 31,
+	177, 157, 111, 141, 6, 118, 95, 97, 93, 94,
+	89, 75, 76, 49, 77, 78, 79, 7, 250, 243,
+	238, 103, 242, 106, 230, 165, 124, 120, 107, 108,
+	105, 249, 102, 75, 76, 113, 77, 78, 79, 229,
+	164, 123, 213, 237, 268, 212, 159, 160, 228, 104,
+	263, 142, 247, 232, 72, 74, 80, 81, 82, 83,
+	84, 227, 73, 128, 279, 129, 130, 131, 132, 133,
+	134, 135, 136, 137, 138, 139, 72, 74, 80, 81,
+	82, 83, 84, 148, 73, 231, 143, 198, 73, 219,
+	197, 146, 144, 167, 166, 162, 127, 181, 182, 183,
+	126, 158, 145, 125, 262, 179, 169, 180, 208, 80,
+	81, 82, 83, 84, 168, 73, 44, 245, 155, 185,
+	186, 154, 271, 175, 181, 182, 183, 82, 83, 84,
+	49, 73, 179, 100, 180, 188, 184, 99, 193, 79,
+	199, 222, 7, 202, 194, 203, 204, 190, 75, 76,
+	209, 77, 78, 79, 200, 201, 260, 261, 211, 218,
+	151, 217, 217, 184, 221, 113, 42, 43, 215, 7,
+	88, 206, 207, 77, 78, 79, 187, 224, 225, 80,
+	81, 82, 83, 84, 121, 73, 233, 101, 172, 235,
+	173, 171, 226, 80, 81, 82, 83, 84, 98, 73,
+	91, 90, 92, 156, 244, 3, 158, 240, 42, 43,
+	8, 246, 28, 27, 217, 80, 81, 82, 83, 84,
+	223, 73, 85, 150, 193, 88, 252, 178, 257, 258,
+	194, 46, 251, 88, 110, 253, 254, 92, 96, 266,
+	265, 264, 217, 267, 11, 153, 259, 239, 161, 87,
+	11, 272, 273, 122, 274, 91, 90, 92, 191, 88,
+	276, 277, 189, 91, 90, 92, 140, 210, 10, 283,
+	5, 4, 275, 284, 51, 52, 2, 53, 54, 55,
+	56, 57, 58, 59, 60, 61, 62, 116, 117, 91,
+	90, 92, 1, 114, 42, 43, 0, 0, 63, 64,
+	65, 66, 67, 68, 69, 70, 71, 0, 51, 52,
+	0, 53, 54, 55, 56, 57, 58, 59, 60, 61,
+	62, 116, 117, 0, 0, 0, 115, 114, 42, 43,
+	214, 0, 63, 64, 65, 66, 67, 68, 69, 70,
+	71, 0, 0, 20, 0, 17, 37, 24, 25, 26,
+	38, 40, 39, 41, 23, 29, 30, 42, 43, 0,
+	115, 15, 0, 0, 109, 0, 16, 0, 13, 14,
+	22, 0, 0, 0, 0, 0, 0, 0, 0, 33,
+	34, 0, 78, 79, 21, 0, 36, 0, 149, 32,
+	0, 147, 35, 18, 19, 20, 0, 17, 37, 24,
 	25, 26, 38, 40, 39, 41, 23, 29, 30, 42,
-	43, 0, 114, 15, 0, 0, 212, 0, 16, 0,
-	13, 14, 22, 91, 90, 92, 0, 0, 0, 0,
+	43, 0, 0, 15, 0, 0, 0, 0, 16, 0,
+	13, 14, 22, 80, 81, 82, 83, 84, 0, 73,
 	0, 33, 34, 0, 0, 0, 21, 0, 36, 0,
-	148, 32, 0, 146, 35, 51, 52, 0, 53, 54,
-	55, 56, 57, 58, 59, 60, 61, 62, 115, 116,
-	0, 0, 0, 0, 0, 42, 43, 0, 0, 63,
-	64, 65, 66, 67, 68, 69, 70, 71, 18, 19,
-	20, 0, 17, 37, 24, 25, 26, 38, 40, 39,
-	41, 23, 29, 30, 42, 43, 0, 114, 15, 0,
-	0, 109, 0, 16, 0, 13, 14, 22, 0, 0,
-	0, 0, 0, 0, 0, 0, 33, 34, 0, 0,
-	0, 21, 0, 36, 0, 0, 32, 0, 20, 35,
-	17, 37, 24, 25, 26, 38, 40, 39, 41, 23,
-	29, 30, 42, 43, 0, 0, 15, 0, 0, 0,
-	0, 16, 0, 13, 14, 22, 0, 0, 0, 0,
-	0, 0, 0, 0, 33, 34, 0, 0, 0, 21,
-	0, 36, 0, 0, 32, 0, 231, 35, 20, 0,
-	17, 37, 24, 25, 26, 38, 40, 39, 41, 23,
-	29, 30, 42, 43, 0, 0, 15, 0, 0, 0,
-	0, 16, 0, 13, 14, 22, 0, 0, 0, 0,
-	0, 0, 0, 0, 33, 34, 0, 0, 0, 21,
-	0, 36, 0, 0, 32, 0, 118, 35, 20, 0,
-	17, 37, 24, 25, 26, 38, 40, 39, 41, 23,
-	29, 30, 42, 43, 0, 0, 15, 0, 77, 78,
-	79, 16, 0, 13, 14, 22, 0, 0, 0, 0,
-	0, 0, 0, 0, 33, 34, 0, 0, 0, 21,
-	0, 36, 0, 0, 32, 51, 52, 35, 53, 54,
-	55, 56, 57, 58, 59, 60, 61, 62, 48, 0,
-	80, 81, 82, 83, 84, 50, 73, 0, 0, 63,
-	64, 65, 66, 67, 68, 69, 70, 71, 51, 52,
-	0, 53, 54, 55, 56, 57, 58, 59, 60, 61,
-	62, 48, 0, 0, 0, 0, 0, 0, 50, 0,
-	0, 172, 63, 64, 65, 66, 67, 68, 69, 70,
-	71, 51, 52, 0, 53, 54, 55, 56, 57, 58,
-	59, 60, 61, 62, 115, 194, 0, 0, 0, 0,
-	0, 42, 43, 0, 45, 63, 64, 65, 66, 67,
-	68, 69, 70, 71, 37, 24, 25, 26, 38, 40,
-	39, 41, 23, 29, 30, 42, 43, 75, 76, 0,
-	77, 78, 79, 193, 0, 0, 0, 0, 22, 0,
-	0, 0, 0, 0, 0, 0, 0, 33, 34, 0,
-	0, 0, 21, 0, 36, 0, 0, 32, 75, 76,
-	35, 77, 78, 79, 0, 0, 0, 0, 0, 0,
-	72, 74, 80, 81, 82, 83, 84, 0, 73, 0,
-	0, 0, 75, 76, 252, 77, 78, 79, 0, 0,
-	0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
-	0, 72, 74, 80, 81, 82, 83, 84, 0, 73,
-	0, 0, 0, 75, 76, 233, 77, 78, 79, 0,
+	0, 32, 0, 20, 35, 17, 37, 24, 25, 26,
+	38, 40, 39, 41, 23, 29, 30, 42, 43, 0,
+	0, 15, 0, 0, 0, 0, 16, 0, 13, 14,
+	22, 0, 0, 0, 0, 0, 0, 0, 0, 33,
+	34, 0, 0, 0, 21, 0, 36, 0, 0, 32,
+	0, 234, 35, 20, 0, 17, 37, 24, 25, 26,
+	38, 40, 39, 41, 23, 29, 30, 42, 43, 0,
+	0, 15, 0, 0, 0, 0, 16, 0, 13, 14,
+	22, 0, 0, 0, 0, 0, 0, 0, 0, 33,
+	34, 0, 0, 0, 21, 0, 36, 0, 0, 32,
+	0, 119, 35, 20, 0, 17, 37, 24, 25, 26,
+	38, 40, 39, 41, 23, 29, 30, 42, 43, 0,
+	0, 15, 0, 0, 0, 0, 16, 0, 13, 14,
+	22, 0, 0, 0, 0, 0, 0, 0, 0, 33,
+	34, 0, 0, 0, 21, 0, 36, 0, 0, 32,
+	51, 52, 35, 53, 54, 55, 56, 57, 58, 59,
+	60, 61, 62, 48, 0, 0, 0, 0, 0, 0,
+	50, 0, 0, 0, 63, 64, 65, 66, 67, 68,
+	69, 70, 71, 51, 52, 0, 53, 54, 55, 56,
+	57, 58, 59, 60, 61, 62, 48, 0, 0, 0,
+	0, 0, 0, 50, 0, 0, 174, 63, 64, 65,
+	66, 67, 68, 69, 70, 71, 51, 52, 0, 53,
+	54, 55, 56, 57, 58, 59, 60, 61, 62, 116,
+	196, 0, 0, 0, 0, 0, 42, 43, 0, 45,
+	63, 64, 65, 66, 67, 68, 69, 70, 71, 37,
+	24, 25, 26, 38, 40, 39, 41, 23, 29, 30,
+	42, 43, 75, 76, 0, 77, 78, 79, 195, 0,
+	0, 0, 0, 22, 0, 0, 75, 76, 0, 77,
+	78, 79, 33, 34, 0, 0, 0, 21, 0, 36,
+	0, 0, 32, 75, 76, 35, 77, 78, 79, 0,
 	0, 0, 0, 0, 0, 72, 74, 80, 81, 82,
-	83, 84, 0, 73, 0, 0, 0, 75, 76, 168,
-	77, 78, 79, 0, 0, 0, 0, 0, 0, 0,
-	0, 0, 0, 0, 0, 0, 72, 74, 80, 81,
-	82, 83, 84, 0, 73, 0, 0, 75, 76, 281,
+	83, 84, 0, 73, 0, 282, 0, 0, 281, 72,
+	74, 80, 81, 82, 83, 84, 0, 73, 0, 0,
+	0, 0, 0, 256, 0, 0, 72, 74, 80, 81,
+	82, 83, 84, 0, 73, 0, 0, 0, 75, 76,
+	236, 77, 78, 79, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 75, 76, 0, 77, 78, 79, 0,
+	0, 0, 0, 0, 0, 0, 0, 75, 76, 0,
 	77, 78, 79, 0, 0, 0, 0, 0, 0, 0,
+	0, 72, 74, 80, 81, 82, 83, 84, 0, 73,
+	0, 0, 0, 0, 0, 170, 72, 74, 80, 81,
+	82, 83, 84, 0, 73, 0, 0, 0, 0, 285,
 	72, 74, 80, 81, 82, 83, 84, 0, 73, 0,
-	0, 75, 76, 276, 77, 78, 79, 0, 0, 0,
-	0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
-	72, 74, 80, 81, 82, 83, 84, 0, 73, 0,
-	0, 75, 76, 251, 77, 78, 79, 0, 0, 0,
-	0, 0, 0, 0, 72, 74, 80, 81, 82, 83,
-	84, 0, 73, 0, 0, 75, 76, 244, 77, 78,
-	79, 0, 0, 0, 0, 0, 0, 0, 0, 0,
-	0, 0, 0, 0, 72, 74, 80, 81, 82, 83,
-	84, 0, 73, 0, 0, 75, 76, 217, 77, 78,
-	79, 0, 0, 0, 0, 0, 0, 0, 72, 74,
-	80, 81, 82, 83, 84, 0, 73, 0, 0, 75,
-	76, 162, 77, 78, 79, 0, 0, 0, 0, 0,
-	75, 76, 0, 77, 78, 79, 0, 0, 72, 74,
-	80, 81, 82, 83, 84, 0, 73, 0, 275, 75,
+	0, 75, 76, 280, 77, 78, 79, 0, 0, 0,
+	0, 0, 0, 0, 0, 75, 76, 0, 77, 78,
+	79, 0, 0, 0, 0, 0, 0, 0, 0, 75,
 	76, 0, 77, 78, 79, 0, 0, 0, 0, 0,
-	0, 0, 72, 74, 80, 81, 82, 83, 84, 0,
-	73, 0, 266, 72, 74, 80, 81, 82, 83, 84,
-	0, 73, 0, 265, 75, 76, 0, 77, 78, 79,
-	0, 0, 72, 74, 80, 81, 82, 83, 84, 0,
-	73, 0, 238, 0, 0, 0, 75, 76, 0, 77,
-	78, 79, 274, 0, 0, 75, 76, 0, 77, 78,
-	79, 0, 0, 0, 0, 0, 0, 72, 74, 80,
-	81, 82, 83, 84, 151, 73, 0, 0, 0, 0,
-	0, 0, 0, 0, 0, 0, 0, 0, 0, 72,
-	74, 80, 81, 82, 83, 84, 0, 73, 72, 74,
-	80, 81, 82, 83, 84, 0, 73,
+	0, 0, 0, 0, 72, 74, 80, 81, 82, 83,
+	84, 0, 73, 0, 0, 0, 0, 255, 72, 74,
+	80, 81, 82, 83, 84, 0, 73, 0, 0, 0,
+	0, 248, 72, 74, 80, 81, 82, 83, 84, 0,
+	73, 0, 0, 75, 76, 220, 77, 78, 79, 0,
+	0, 0, 0, 0, 0, 0, 0, 75, 76, 0,
+	77, 78, 79, 0, 0, 0, 0, 0, 75, 76,
+	0, 77, 78, 79, 0, 0, 0, 0, 0, 75,
+	76, 0, 77, 78, 79, 0, 72, 74, 80, 81,
+	82, 83, 84, 0, 73, 0, 0, 0, 0, 163,
+	72, 74, 80, 81, 82, 83, 84, 0, 73, 0,
+	270, 72, 74, 80, 81, 82, 83, 84, 0, 73,
+	0, 269, 72, 74, 80, 81, 82, 83, 84, 0,
+	73, 0, 241, 75, 76, 0, 77, 78, 79, 0,
+	0, 0, 75, 76, 0, 77, 78, 79, 0, 0,
+	0, 75, 76, 0, 77, 78, 79, 0, 0, 0,
+	0, 278, 0, 0, 0, 0, 0, 0, 0, 0,
+	152, 0, 0, 0, 0, 0, 72, 74, 80, 81,
+	82, 83, 84, 0, 73, 72, 74, 80, 81, 82,
+	83, 84, 0, 73, 72, 74, 80, 81, 82, 83,
+	84, 0, 73,
 }
 
 var yyPact = [...]int16{
-	181, -1000, -1000, -39, -1000, 387, 66, 621, -1000, 1071,
-	-1000, 535, 289, 678, 678, 535, 535, 154, 119, 115,
-	164, 113, -1000, -1000, -1000, -1000, -1000, 13, -1000, -1000,
-	139, -1000, 535, 678, 678, 358, 485, 127, -1000, -1000,
-	-1000, -1000, -1000, -1000, -1000, -1000, 1, -1000, 53, 52,
-	51, -1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000,
+	205, -1000, -1000, -35, -1000, 392, 69, 626, -1000, 1067,
+	-1000, 540, 245, 683, 683, 540, 540, 187, 120, 116,
+	177, 191, -1000, -1000, -1000, -1000, -1000, 0, -1000, -1000,
+	149, -1000, 540, 683, 683, 311, 490, 173, -1000, -1000,
+	-1000, -1000, -1000, -1000, -1000, -1000, -12, -1000, 55, 52,
+	48, -1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000,
 	-1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000,
-	-1000, -1000, 535, -1000, 535, 535, 535, 535, 535, 535,
-	535, 535, 535, 535, 535, -1000, 1071, 0, -1000, -1000,
-	-1000, 113, 302, 241, 89, 1062, 535, 98, 86, 174,
-	-39, 2, -1000, -1000, 535, -1000, 921, 71, 71, -1000,
-	-12, -1000, 49, 48, 535, -1000, -1000, -1000, -1000, 758,
-	-1000, 160, -1000, 588, 40, 40, 40, 1071, 153, 153,
-	561, 201, 219, 67, 79, 79, 43, 43, 43, 131,
-	-1000, -1000, 0, 654, -1000, -1000, -1000, 39, 535, 0,
-	0, 535, -1000, 535, 535, 162, 64, -1000, 535, 162,
-	-5, 1071, -1000, -1000, 273, 678, 678, 897, -1000, -1000,
-	-1000, 535, -1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000,
-	-1000, -1000, 7, -1000, -1000, 535, 0, 5, -1000, -13,
-	-1000, 46, 41, 535, -1000, -1000, 435, 734, 12, 11,
-	1071, -1000, 1071, -39, -1000, -1000, -1000, 1005, -30, -1000,
-	-1000, 535, -1000, -1000, 77, 71, 77, 16, 867, -1000,
-	-20, -1000, 1071, -1000, -1000, 0, -1000, 654, 0, 0,
-	843, -1000, 703, -1000, 535, 535, 117, 57, -1000, -4,
-	162, 1071, 678, 678, -1000, -1000, 40, -1000, -1000, -1000,
-	-1000, -29, -1000, 986, 975, 101, 535, 535, -1000, 535,
-	-1000, 71, 77, -1000, 0, 535, 535, -1000, 1040, 1071,
-	951, -1000, 813, 172, 535, -1000, -1000, -1000, 535, 1071,
-	789, -1000,
+	-1000, -1000, 540, -1000, 540, 540, 540, 540, 540, 540,
+	540, 540, 540, 540, 540, -1000, 1067, 40, -1000, -1000,
+	-1000, 191, 340, 219, 156, 1058, 540, 94, 81, 199,
+	-35, -2, -1000, -1000, 540, -1000, 959, 211, 211, -1000,
+	-13, -1000, 46, 45, 149, 540, -1000, -1000, -1000, -1000,
+	794, -1000, 171, -1000, 593, 117, 117, 117, 1067, 154,
+	154, 176, 384, 140, 70, 86, 86, 43, 43, 43,
+	139, -1000, -1000, 40, 659, -1000, -1000, -1000, 39, 540,
+	40, 40, 540, -1000, 540, 540, 161, 61, -1000, 540,
+	161, -5, 1067, -1000, -1000, 277, 683, 683, 41, 905,
+	-1000, -1000, -1000, 540, -1000, -1000, -1000, -1000, -1000, -1000,
+	-1000, -1000, -1000, -1000, 90, -1000, -1000, 540, 40, 10,
+	-1000, -14, -1000, 37, 5, 540, -1000, -1000, 440, 739,
+	-6, -29, 1067, -1000, 1067, -35, -1000, -1000, -1000, 995,
+	-28, -1000, -1000, 540, -1000, -1000, 80, 211, 80, 683,
+	4, 891, -1000, -20, -1000, 1067, -1000, -1000, 40, -1000,
+	659, 40, 40, 877, -1000, 722, -1000, 540, 540, 133,
+	57, -1000, 2, 161, 1067, 683, 80, 683, -1000, -1000,
+	117, -1000, -1000, -1000, -1000, -4, -1000, 984, 973, 97,
+	540, 540, -1000, 540, -1000, 211, 80, -1000, 40, 540,
+	540, -1000, 1049, 1067, 17, -1000, 823, 708, 540, -1000,
+	-1000, -1000, 540, 1067, 809, -1000,
 }
 
 var yyPgo = [...]int16{
-	0, 264, 263, 262, 261, 259, 12, 214, 195, 244,
-	0, 241, 13, 240, 234, 10, 4, 9, 233, 20,
-	230, 218, 217, 215, 213, 8, 1, 2, 7, 199,
-	15, 198, 196, 5, 193, 192, 14, 3,
+	0, 302, 286, 281, 280, 278, 11, 220, 248, 277,
+	0, 276, 13, 272, 268, 5, 4, 9, 263, 20,
+	258, 257, 256, 255, 244, 12, 1, 2, 10, 241,
+	8, 237, 230, 7, 223, 222, 15, 3,
 }
 
 var yyR1 = [...]int8{
@@ -351,11 +366,11 @@ var yyR1 = [...]int8{
 	16, 16, 16, 16, 17, 17, 18, 18, 18, 34,
 	34, 35, 35, 19, 19, 19, 19, 19, 20, 20,
 	21, 21, 22, 22, 23, 23, 24, 24, 25, 25,
-	25, 25, 25, 37, 37, 37, 26, 26, 27, 27,
-	27, 27, 27, 27, 27, 28, 28, 28, 29, 29,
-	30, 30, 30, 31, 31, 32, 32, 36, 36, 36,
+	25, 25, 25, 25, 25, 37, 37, 37, 26, 26,
+	27, 27, 27, 27, 27, 27, 27, 28, 28, 28,
+	29, 29, 30, 30, 30, 31, 31, 32, 32, 36,
+	36, 36, 36, 36, 36, 36, 36, 36, 36, 36,
 	36, 36, 36, 36, 36, 36, 36, 36, 36, 36,
-	36, 36, 36, 36, 36, 36, 36, 36,
 }
 
 var yyR2 = [...]int8{
@@ -370,11 +385,11 @@ var yyR2 = [...]int8{
 	2, 2, 3, 3, 1, 3, 0, 2, 4, 1,
 	1, 1, 1, 2, 3, 4, 4, 5, 1, 3,
 	0, 5, 0, 2, 0, 2, 1, 3, 3, 3,
-	5, 1, 1, 1, 1, 1, 1, 3, 1, 1,
-	1, 1, 1, 1, 1, 2, 3, 4, 1, 3,
-	3, 3, 3, 2, 3, 1, 3, 1, 1, 1,
+	4, 5, 1, 1, 2, 1, 1, 1, 1, 3,
+	1, 1, 1, 1, 1, 1, 1, 2, 3, 4,
+	1, 3, 3, 3, 3, 2, 3, 1, 3, 1,
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
 	1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
-	1, 1, 1, 1, 1, 1, 1, 1,
 }
 
 var yyChk = [...]int16{
@@ -389,24 +404,24 @@ var yyChk = [...]int16{
 	49, 50, 51, 52, 53, -7, -10, 14, 24, -19,
 	55, 54, 56, -16, -16, -10, -8, -10, 21, 27,
 	27, 20, -19, -17, 59, -17, -10, -16, -16, 63,
-	-24, -25, -37, -17, 59, 20, 21, -36, 61, -10,
-	21, -18, 63, 48, 58, 58, 58, -10, -10, -10,
-	-10, -10, -10, -10, -10, -10, -10, -10, -10, -11,
-	-12, 21, 56, 62, -19, -17, 61, -10, 58, 14,
-	14, 32, -23, 37, 47, 14, -6, -28, 58, 59,
-	-20, -10, 60, 63, 48, 58, 58, -10, 61, 30,
-	27, 29, 63, -30, -27, -28, -31, 25, 27, 17,
-	18, 19, 56, -27, -27, 47, 6, -13, -12, -14,
-	-15, -37, -17, 59, 21, 61, 58, -10, -12, -12,
-	-10, -10, -10, -33, 20, 21, 57, -10, -9, -33,
-	60, 57, 63, -25, -26, -16, -26, 60, -10, 61,
-	-32, -27, -10, -12, 61, 48, 63, 48, 58, 58,
-	-10, 61, -10, 61, 59, 59, -21, -6, 57, 60,
-	57, -10, 47, 58, 60, 61, 48, -12, -15, -12,
-	-12, 60, 61, -10, -10, -22, 33, 34, 57, 58,
-	-33, -16, -26, -27, 58, 57, 57, 35, -10, -10,
-	-10, -12, -10, -10, 32, 57, 60, 60, 57, -10,
-	-10, 60,
+	-24, -25, -37, -17, 26, 59, 20, 21, -36, 61,
+	-10, 21, -18, 63, 48, 58, 58, 58, -10, -10,
+	-10, -10, -10, -10, -10, -10, -10, -10, -10, -10,
+	-11, -12, 21, 56, 62, -19, -17, 61, -10, 58,
+	14, 14, 32, -23, 37, 47, 14, -6, -28, 58,
+	59, -20, -10, 60, 63, 48, 58, 58, -17, -10,
+	61, 30, 27, 29, 63, -30, -27, -28, -31, 25,
+	27, 17, 18, 19, 56, -27, -27, 47, 6, -13,
+	-12, -14, -15, -37, -17, 59, 21, 61, 58, -10,
+	-12, -12, -10, -10, -10, -33, 20, 21, 57, -10,
+	-9, -33, 60, 57, 63, -25, -26, -16, -26, 58,
+	60, -10, 61, -32, -27, -10, -12, 61, 48, 63,
+	48, 58, 58, -10, 61, -10, 61, 59, 59, -21,
+	-6, 57, 60, 57, -10, 47, -26, 58, 60, 61,
+	48, -12, -15, -12, -12, 60, 61, -10, -10, -22,
+	33, 34, 57, 58, -33, -16, -26, -27, 58, 57,
+	57, 35, -10, -10, -10, -12, -10, -10, 32, 57,
+	60, 60, 57, -10, -10, 60,
 }
 
 var yyDef = [...]int16{
@@ -414,31 +429,31 @@ var yyDef = [...]int16{
 	7, 12, 41, 0, 0, 0, 0, 0, 0, 0,
 	0, 55, 56, 57, 60, 61, 62, 63, 65, 66,
 	67, 69, 0, 0, 0, 0, 0, 0, 89, 90,
-	91, 92, 84, 86, 3, 125, 0, 128, 0, 0,
-	0, 137, 138, 139, 140, 141, 142, 143, 144, 145,
-	146, 147, 148, 149, 150, 151, 152, 153, 154, 155,
-	156, 157, 0, 29, 0, 0, 0, 0, 0, 0,
+	91, 92, 84, 86, 3, 127, 0, 130, 0, 0,
+	0, 139, 140, 141, 142, 143, 144, 145, 146, 147,
+	148, 149, 150, 151, 152, 153, 154, 155, 156, 157,
+	158, 159, 0, 29, 0, 0, 0, 0, 0, 0,
 	0, 0, 0, 0, 0, 13, 20, 0, 79, 80,
 	81, 0, 0, 0, 0, 0, 0, -2, 0, 0,
 	10, 0, 58, 59, 0, 68, 0, 71, 72, 73,
-	0, 106, 111, 112, 0, 113, 114, 115, 76, 0,
-	78, 0, 126, 0, 0, 0, 0, 21, 30, 31,
-	-2, 33, 34, -2, 36, 37, 38, 39, 40, 0,
-	42, 44, 0, 0, 82, 83, 93, 0, 0, 0,
-	0, 0, 27, 0, 0, 0, 0, 11, 0, 0,
-	0, 98, 70, 74, 0, 0, 0, 0, 77, 85,
-	87, 0, 127, 129, 130, 118, 119, 120, 121, 122,
-	123, 124, 0, 131, 132, 0, 0, 0, 47, 0,
-	49, 0, 0, 0, -2, 94, 0, 0, 0, 0,
-	100, 105, 28, 10, 18, 19, 9, 0, 0, 16,
-	64, 0, 75, 107, 108, 116, 109, 0, 0, 133,
-	0, 135, 22, 43, 45, 0, 46, 0, 0, 0,
-	0, 95, 0, 96, 0, 0, 102, 0, 14, 0,
-	0, 99, 0, 0, 88, 134, 0, 48, 50, 51,
-	52, 0, 97, 0, 0, 0, 0, 0, 8, 0,
-	17, 117, 110, 136, 0, 0, 0, 26, 0, 103,
-	0, 53, 0, 0, 0, 15, 23, 24, 0, 101,
-	0, 25,
+	0, 106, 112, 113, 0, 0, 115, 116, 117, 76,
+	0, 78, 0, 128, 0, 0, 0, 0, 21, 30,
+	31, -2, 33, 34, -2, 36, 37, 38, 39, 40,
+	0, 42, 44, 0, 0, 82, 83, 93, 0, 0,
+	0, 0, 0, 27, 0, 0, 0, 0, 11, 0,
+	0, 0, 98, 70, 74, 0, 0, 0, 114, 0,
+	77, 85, 87, 0, 129, 131, 132, 120, 121, 122,
+	123, 124, 125, 126, 0, 133, 134, 0, 0, 0,
+	47, 0, 49, 0, 0, 0, -2, 94, 0, 0,
+	0, 0, 100, 105, 28, 10, 18, 19, 9, 0,
+	0, 16, 64, 0, 75, 107, 108, 118, 109, 0,
+	0, 0, 135, 0, 137, 22, 43, 45, 0, 46,
+	0, 0, 0, 0, 95, 0, 96, 0, 0, 102,
+	0, 14, 0, 0, 99, 0, 110, 0, 88, 136,
+	0, 48, 50, 51, 52, 0, 97, 0, 0, 0,
+	0, 0, 8, 0, 17, 119, 111, 138, 0, 0,
+	0, 26, 0, 103, 0, 53, 0, 0, 0, 15,
+	23, 24, 0, 101, 0, 25,
 }
 
 var yyTok1 = [...]int8{
@@ -808,7 +823,7 @@ yydefault:
 
 	case 1:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line parser.go.y:73
+//line parser.go.y:88
 		{
 			if yyDollar[1].value != nil {
 				yyDollar[2].value.(*Query).Meta = yyDollar[1].value.(*ConstObject)
@@ -817,25 +832,25 @@ yydefault:
 		}
 	case 2:
 		yyDollar = yyS[yypt-0 : yypt+1]
-//line parser.go.y:80
+//line parser.go.y:95
 		{
 			yyVAL.value = nil
 		}
 	case 3:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line parser.go.y:84
+//line parser.go.y:99
 		{
 			yyVAL.value = yyDollar[2].value
 		}
 	case 4:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line parser.go.y:90
+//line parser.go.y:105
 		{
 			yyVAL.value = &Query{Imports: yyDollar[1].value.([]*Import), FuncDefs: reverseFuncDef(yyDollar[2].value.([]*FuncDef)), Term: &Term{Type: TermTypeIdentity}}
 		}
 	case 5:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line parser.go.y:94
+//line parser.go.y:109
 		{
 			if yyDollar[1].value != nil {
 				yyDollar[2].value.(*Query).Imports = yyDollar[1].value.([]*Import)
@@ -844,322 +859,344 @@ yydefault:
 		}
 	case 6:
 		yyDollar = yyS[yypt-0 : yypt+1]
-//line parser.go.y:101
+//line parser.go.y:116
 		{
 			yyVAL.value = []*Import(nil)
 		}
 	case 7:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line parser.go.y:105
+//line parser.go.y:120
 		{
 			yyVAL.value = append(yyDollar[1].value.([]*Import), yyDollar[2].value.(*Import))
 		}
 	case 8:
 		yyDollar = yyS[yypt-6 : yypt+1]
-//line parser.go.y:111
+//line parser.go.y:126
 		{
 			yyVAL.value = &Import{ImportPath: yyDollar[2].token, ImportAlias: yyDollar[4].token, Meta: yyDollar[5].value.(*ConstObject)}
 		}
 	case 9:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line parser.go.y:115
+//line parser.go.y:130
 		{
 			yyVAL.value = &Import{IncludePath: yyDollar[2].token, Meta: yyDollar[3].value.(*ConstObject)}
 		}
 	case 10:
 		yyDollar = yyS[yypt-0 : yypt+1]
-//line parser.go.y:121
+//line parser.go.y:136
 		{
 			yyVAL.value = (*ConstObject)(nil)
 		}
 	case 11:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:124
+//line parser.go.y:139
 		{
 		}
 	case 12:
 		yyDollar = yyS[yypt-0 : yypt+1]
-//line parser.go.y:128
+//line parser.go.y:143
 		{
 			yyVAL.value = []*FuncDef(nil)
 		}
 	case 13:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line parser.go.y:132
+//line parser.go.y:147
 		{
 			yyVAL.value = append(yyDollar[2].value.([]*FuncDef), yyDollar[1].value.(*FuncDef))
 		}
 	case 14:
 		yyDollar = yyS[yypt-5 : yypt+1]
-//line parser.go.y:138
+//line parser.go.y:153
 		{
 			yyVAL.value = &FuncDef{Name: yyDollar[2].token, Body: yyDollar[4].value.(*Query)}
 		}
 	case 15:
 		yyDollar = yyS[yypt-8 : yypt+1]
-//line parser.go.y:142
+//line parser.go.y:157
 		{
-			yyVAL.value = &FuncDef{yyDollar[2].token, yyDollar[4].value.([]string), yyDollar[7].value.(*Query)}
+			fd := yyDollar[4].value.(*FuncDef)
+			fd.Name, fd.Body = yyDollar[2].token, yyDollar[7].value.(*Query)
+			yyVAL.value = fd
 		}
 	case 16:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:148
+//line parser.go.y:165
 		{
-			yyVAL.value = []string{yyDollar[1].token}
+			if yyDollar[1].token == "$__loc__" {
+				yylex.(*lexer).bindLocError(yyDollar[1].value)
+			}
+			yyVAL.value = &FuncDef{Args: []string{yyDollar[1].token}, argOffsets: []int{yyDollar[1].offset + 1}}
 		}
 	case 17:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line parser.go.y:152
+//line parser.go.y:172
 		{
-			yyVAL.value = append(yyDollar[1].value.([]string), yyDollar[3].token)
+			if yyDollar[3].token == "$__loc__" {
+				yylex.(*lexer).bindLocError(yyDollar[3].value)
+			}
+			fd := yyDollar[1].value.(*FuncDef)
+			fd.Args, fd.argOffsets = append(fd.Args, yyDollar[3].token), append(fd.argOffsets, yyDollar[3].offset+1)
+			yyVAL.value = fd
 		}
 	case 18:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:157
+//line parser.go.y:182
 		{
 		}
 	case 19:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:158
+//line parser.go.y:183
 		{
 		}
 	case 20:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line parser.go.y:162
+//line parser.go.y:187
 		{
 			yyDollar[2].value.(*Query).FuncDefs = prependFuncDef(yyDollar[2].value.(*Query).FuncDefs, yyDollar[1].value.(*FuncDef))
 			yyVAL.value = yyDollar[2].value
 		}
 	case 21:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line parser.go.y:167
+//line parser.go.y:192
 		{
-			yyVAL.value = &Query{Left: yyDollar[1].value.(*Query), Op: OpPipe, Right: yyDollar[3].value.(*Query)}
+			yyVAL.value = &Query{Left: yyDollar[1].value.(*Query), Op: OpPipe, Right: yyDollar[3].value.(*Query), offset: yyDollar[1].offset + 1}
 		}
 	case 22:
 		yyDollar = yyS[yypt-5 : yypt+1]
-//line parser.go.y:171
+//line parser.go.y:196
 		{
 			yyDollar[1].value.(*Term).SuffixList = append(yyDollar[1].value.(*Term).SuffixList, &Suffix{Bind: &Bind{yyDollar[3].value.([]*Pattern), yyDollar[5].value.(*Query)}})
-			yyVAL.value = &Query{Term: yyDollar[1].value.(*Term)}
+			yyVAL.value = &Query{Term: yyDollar[1].value.(*Term), offset: yyDollar[1].offset + 1}
 		}
 	case 23:
 		yyDollar = yyS[yypt-9 : yypt+1]
-//line parser.go.y:176
+//line parser.go.y:201
 		{
-			yyVAL.value = &Query{Term: &Term{Type: TermTypeReduce, Reduce: &Reduce{yyDollar[2].value.(*Term), yyDollar[4].value.(*Pattern), yyDollar[6].value.(*Query), yyDollar[8].value.(*Query)}}}
+			yyVAL.value = &Query{Term: &Term{Type: TermTypeReduce, Reduce: &Reduce{yyDollar[2].value.(*Term), yyDollar[4].value.(*Pattern), yyDollar[6].value.(*Query), yyDollar[8].value.(*Query)}}, offset: yyDollar[1].offset + 1}
 		}
 	case 24:
 		yyDollar = yyS[yypt-9 : yypt+1]
-//line parser.go.y:180
+//line parser.go.y:205
 		{
-			yyVAL.value = &Query{Term: &Term{Type: TermTypeForeach, Foreach: &Foreach{yyDollar[2].value.(*Term), yyDollar[4].value.(*Pattern), yyDollar[6].value.(*Query), yyDollar[8].value.(*Query), nil}}}
+			yyVAL.value = &Query{Term: &Term{Type: TermTypeForeach, Foreach: &Foreach{yyDollar[2].value.(*Term), yyDollar[4].value.(*Pattern), yyDollar[6].value.(*Query), yyDollar[8].value.(*Query), nil}}, offset: yyDollar[1].offset + 1}
 		}
 	case 25:
 		yyDollar = yyS[yypt-11 : yypt+1]
-//line parser.go.y:184
+//line parser.go.y:209
 		{
-			yyVAL.value = &Query{Term: &Term{Type: TermTypeForeach, Foreach: &Foreach{yyDollar[2].value.(*Term), yyDollar[4].value.(*Pattern), yyDollar[6].value.(*Query), yyDollar[8].value.(*Query), yyDollar[10].value.(*Query)}}}
+			yyVAL.value = &Query{Term: &Term{Type: TermTypeForeach, Foreach: &Foreach{yyDollar[2].value.(*Term), yyDollar[4].value.(*Pattern), yyDollar[6].value.(*Query), yyDollar[8].value.(*Query), yyDollar[10].value.(*Query)}}, offset: yyDollar[1].offset + 1}
 		}
 	case 26:
 		yyDollar = yyS[yypt-7 : yypt+1]
-//line parser.go.y:188
+//line parser.go.y:213
 		{
-			yyVAL.value = &Query{Term: &Term{Type: TermTypeIf, If: &If{yyDollar[2].value.(*Query), yyDollar[4].value.(*Query), yyDollar[5].value.([]*IfElif), yyDollar[6].value.(*Query)}}}
+			yyVAL.value = &Query{Term: &Term{Type: TermTypeIf, If: &If{yyDollar[2].value.(*Query), yyDollar[4].value.(*Query), yyDollar[5].value.([]*IfElif), yyDollar[6].value.(*Query)}}, offset: yyDollar[1].offset + 1}
 		}
 	case 27:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line parser.go.y:192
+//line parser.go.y:217
 		{
-			yyVAL.value = &Query{Term: &Term{Type: TermTypeTry, Try: &Try{yyDollar[2].value.(*Query), yyDollar[3].value.(*Query)}}}
+			yyVAL.value = &Query{Term: &Term{Type: TermTypeTry, Try: &Try{yyDollar[2].value.(*Query), yyDollar[3].value.(*Query)}}, offset: yyDollar[1].offset + 1}
 		}
 	case 28:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line parser.go.y:196
+//line parser.go.y:221
 		{
-			yyVAL.value = &Query{Term: &Term{Type: TermTypeLabel, Label: &Label{yyDollar[2].token, yyDollar[4].value.(*Query)}}}
+			if yyDollar[2].token == "$__loc__" {
+				yylex.(*lexer).bindLocError(yyDollar[2].value)
+			}
+			yyVAL.value = &Query{Term: &Term{Type: TermTypeLabel, Label: &Label{yyDollar[2].token, yyDollar[4].value.(*Query)}}, offset: yyDollar[1].offset + 1}
 		}
 	case 29:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line parser.go.y:200
+//line parser.go.y:228
 		{
 			if t := yyDollar[1].value.(*Query).Term; t != nil {
 				t.SuffixList = append(t.SuffixList, &Suffix{Optional: true})
 			} else {
-				yyVAL.value = &Query{Term: &Term{Type: TermTypeQuery, Query: yyDollar[1].value.(*Query), SuffixList: []*Suffix{{Optional: true}}}}
+				yyVAL.value = &Query{Term: &Term{Type: TermTypeQuery, Query: yyDollar[1].value.(*Query), SuffixList: []*Suffix{{Optional: true}}}, offset: yyDollar[1].offset + 1}
 			}
 		}
 	case 30:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line parser.go.y:208
+//line parser.go.y:236
 		{
-			yyVAL.value = &Query{Left: yyDollar[1].value.(*Query), Op: OpComma, Right: yyDollar[3].value.(*Query)}
+			yyVAL.value = &Query{Left: yyDollar[1].value.(*Query), Op: OpComma, Right: yyDollar[3].value.(*Query), offset: yyDollar[1].offset + 1}
 		}
 	case 31:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line parser.go.y:212
+//line parser.go.y:240
 		{
-			yyVAL.value = &Query{Left: yyDollar[1].value.(*Query), Op: yyDollar[2].operator, Right: yyDollar[3].value.(*Query)}
+			yyVAL.value = &Query{Left: yyDollar[1].value.(*Query), Op: yyDollar[2].operator, Right: yyDollar[3].value.(*Query), offset: yyDollar[1].offset + 1}
 		}
 	case 32:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line parser.go.y:216
+//line parser.go.y:244
 		{
-			yyVAL.value = &Query{Left: yyDollar[1].value.(*Query), Op: yyDollar[2].operator, Right: yyDollar[3].value.(*Query)}
+			yyVAL.value = &Query{Left: yyDollar[1].value.(*Query), Op: yyDollar[2].operator, Right: yyDollar[3].value.(*Query), offset: yyDollar[1].offset + 1}
 		}
 	case 33:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line parser.go.y:220
+//line parser.go.y:248
 		{
-			yyVAL.value = &Query{Left: yyDollar[1].value.(*Query), Op: OpOr, Right: yyDollar[3].value.(*Query)}
+			yyVAL.value = &Query{Left: yyDollar[1].value.(*Query), Op: OpOr, Right: yyDollar[3].value.(*Query), offset: yyDollar[1].offset + 1}
 		}
 	case 34:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line parser.go.y:224
+//line parser.go.y:252
 		{
-			yyVAL.value = &Query{Left: yyDollar[1].value.(*Query), Op: OpAnd, Right: yyDollar[3].value.(*Query)}
+			yyVAL.value = &Query{Left: yyDollar[1].value.(*Query), Op: OpAnd, Right: yyDollar[3].value.(*Query), offset: yyDollar[1].offset + 1}
 		}
 	case 35:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line parser.go.y:228
+//line parser.go.y:256
 		{
-			yyVAL.value = &Query{Left: yyDollar[1].value.(*Query), Op: yyDollar[2].operator, Right: yyDollar[3].value.(*Query)}
+			yyVAL.value = &Query{Left: yyDollar[1].value.(*Query), Op: yyDollar[2].operator, Right: yyDollar[3].value.(*Query), offset: yyDollar[1].offset + 1}
 		}
 	case 36:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line parser.go.y:232
+//line parser.go.y:260
 		{
-			yyVAL.value = &Query{Left: yyDollar[1].value.(*Query), Op: OpAdd, Right: yyDollar[3].value.(*Query)}
+			yyVAL.value = &Query{Left: yyDollar[1].value.(*Query), Op: OpAdd, Right: yyDollar[3].value.(*Query), offset: yyDollar[1].offset + 1}
 		}
 	case 37:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line parser.go.y:236
+//line parser.go.y:264
 		{
-			yyVAL.value = &Query{Left: yyDollar[1].value.(*Query), Op: OpSub, Right: yyDollar[3].value.(*Query)}
+			yyVAL.value = &Query{Left: yyDollar[1].value.(*Query), Op: OpSub, Right: yyDollar[3].value.(*Query), offset: yyDollar[1].offset + 1}
 		}
 	case 38:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line parser.go.y:240
+//line parser.go.y:268
 		{
-			yyVAL.value = &Query{Left: yyDollar[1].value.(*Query), Op: OpMul, Right: yyDollar[3].value.(*Query)}
+			yyVAL.value = &Query{Left: yyDollar[1].value.(*Query), Op: OpMul, Right: yyDollar[3].value.(*Query), offset: yyDollar[1].offset + 1}
 		}
 	case 39:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line parser.go.y:244
+//line parser.go.y:272
 		{
-			yyVAL.value = &Query{Left: yyDollar[1].value.(*Query), Op: OpDiv, Right: yyDollar[3].value.(*Query)}
+			yyVAL.value = &Query{Left: yyDollar[1].value.(*Query), Op: OpDiv, Right: yyDollar[3].value.(*Query), offset: yyDollar[1].offset + 1}
 		}
 	case 40:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line parser.go.y:248
+//line parser.go.y:276
 		{
-			yyVAL.value = &Query{Left: yyDollar[1].value.(*Query), Op: OpMod, Right: yyDollar[3].value.(*Query)}
+			yyVAL.value = &Query{Left: yyDollar[1].value.(*Query), Op: OpMod, Right: yyDollar[3].value.(*Query), offset: yyDollar[1].offset + 1}
 		}
 	case 41:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:252
+//line parser.go.y:280
 		{
-			yyVAL.value = &Query{Term: yyDollar[1].value.(*Term)}
+			yyVAL.value = &Query{Term: yyDollar[1].value.(*Term), offset: yyDollar[1].offset + 1}
 		}
 	case 42:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:258
+//line parser.go.y:286
 		{
 			yyVAL.value = []*Pattern{yyDollar[1].value.(*Pattern)}
 		}
 	case 43:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line parser.go.y:262
+//line parser.go.y:290
 		{
 			yyVAL.value = append(yyDollar[1].value.([]*Pattern), yyDollar[3].value.(*Pattern))
 		}
 	case 44:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:268
+//line parser.go.y:296
 		{
-			yyVAL.value = &Pattern{Name: yyDollar[1].token}
+			if yyDollar[1].token == "$__loc__" {
+				yylex.(*lexer).bindLocError(yyDollar[1].value)
+			}
+			yyVAL.value = &Pattern{Name: yyDollar[1].token, offset: yyDollar[1].offset + 1}
 		}
 	case 45:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line parser.go.y:272
+//line parser.go.y:303
 		{
 			yyVAL.value = &Pattern{Array: yyDollar[2].value.([]*Pattern)}
 		}
 	case 46:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line parser.go.y:276
+//line parser.go.y:307
 		{
 			yyVAL.value = &Pattern{Object: yyDollar[2].value.([]*PatternObject)}
 		}
 	case 47:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:282
+//line parser.go.y:313
 		{
 			yyVAL.value = []*Pattern{yyDollar[1].value.(*Pattern)}
 		}
 	case 48:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line parser.go.y:286
+//line parser.go.y:317
 		{
 			yyVAL.value = append(yyDollar[1].value.([]*Pattern), yyDollar[3].value.(*Pattern))
 		}
 	case 49:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:292
+//line parser.go.y:323
 		{
 			yyVAL.value = []*PatternObject{yyDollar[1].value.(*PatternObject)}
 		}
 	case 50:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line parser.go.y:296
+//line parser.go.y:327
 		{
 			yyVAL.value = append(yyDollar[1].value.([]*PatternObject), yyDollar[3].value.(*PatternObject))
 		}
 	case 51:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line parser.go.y:302
+//line parser.go.y:333
 		{
-			yyVAL.value = &PatternObject{Key: yyDollar[1].token, Val: yyDollar[3].value.(*Pattern)}
+			if yyDollar[1].token == "$__loc__" {
+				yylex.(*lexer).bindLocError(yyDollar[1].value)
+			}
+			yyVAL.value = &PatternObject{Key: yyDollar[1].token, Val: yyDollar[3].value.(*Pattern), offset: yyDollar[1].offset + 1}
 		}
 	case 52:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line parser.go.y:306
+//line parser.go.y:340
 		{
 			yyVAL.value = &PatternObject{KeyString: yyDollar[1].value.(*String), Val: yyDollar[3].value.(*Pattern)}
 		}
 	case 53:
 		yyDollar = yyS[yypt-5 : yypt+1]
-//line parser.go.y:310
+//line parser.go.y:344
 		{
 			yyVAL.value = &PatternObject{KeyQuery: yyDollar[2].value.(*Query), Val: yyDollar[5].value.(*Pattern)}
 		}
 	case 54:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:314
+//line parser.go.y:348
 		{
-			yyVAL.value = &PatternObject{Key: yyDollar[1].token}
+			if yyDollar[1].token == "$__loc__" {
+				yylex.(*lexer).bindLocError(yyDollar[1].value)
+			}
+			yyVAL.value = &PatternObject{Key: yyDollar[1].token, offset: yyDollar[1].offset + 1}
 		}
 	case 55:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:320
+//line parser.go.y:357
 		{
 			yyVAL.value = &Term{Type: TermTypeIdentity}
 		}
 	case 56:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:324
+//line parser.go.y:361
 		{
 			yyVAL.value = &Term{Type: TermTypeRecurse}
 		}
 	case 57:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:328
+//line parser.go.y:365
 		{
 			yyVAL.value = &Term{Type: TermTypeIndex, Index: &Index{Name: yyDollar[1].token}}
 		}
 	case 58:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line parser.go.y:332
+//line parser.go.y:369
 		{
 			if yyDollar[2].value.(*Suffix).Iter {
 				yyVAL.value = &Term{Type: TermTypeIdentity, SuffixList: []*Suffix{yyDollar[2].value.(*Suffix)}}
@@ -1169,569 +1206,589 @@ yydefault:
 		}
 	case 59:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line parser.go.y:340
+//line parser.go.y:377
 		{
 			yyVAL.value = &Term{Type: TermTypeIndex, Index: &Index{Str: yyDollar[2].value.(*String)}}
 		}
 	case 60:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:344
+//line parser.go.y:381
 		{
 			yyVAL.value = &Term{Type: TermTypeNull}
 		}
 	case 61:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:348
+//line parser.go.y:385
 		{
 			yyVAL.value = &Term{Type: TermTypeTrue}
 		}
 	case 62:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:352
+//line parser.go.y:389
 		{
 			yyVAL.value = &Term{Type: TermTypeFalse}
 		}
 	case 63:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:356
+//line parser.go.y:393
 		{
-			yyVAL.value = &Term{Type: TermTypeFunc, Func: &Func{Name: yyDollar[1].token}}
+			yyVAL.value = &Term{Type: TermTypeFunc, Func: &Func{Name: yyDollar[1].token, offset: yyDollar[1].offset + 1}}
 		}
 	case 64:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line parser.go.y:360
+//line parser.go.y:397
 		{
-			yyVAL.value = &Term{Type: TermTypeFunc, Func: &Func{Name: yyDollar[1].token, Args: yyDollar[3].value.([]*Query)}}
+			yyVAL.value = &Term{Type: TermTypeFunc, Func: &Func{Name: yyDollar[1].token, Args: yyDollar[3].value.([]*Query), offset: yyDollar[1].offset + 1}}
 		}
 	case 65:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:364
+//line parser.go.y:401
 		{
-			yyVAL.value = &Term{Type: TermTypeFunc, Func: &Func{Name: yyDollar[1].token}}
+			if yyDollar[1].token == "$__loc__" {
+				yyVAL.value = &Term{Type: TermTypeFunc, Func: &Func{Name: yyDollar[1].token, loc: yyDollar[1].value.(*location)}}
+			} else {
+				yyVAL.value = &Term{Type: TermTypeFunc, Func: &Func{Name: yyDollar[1].token, offset: yyDollar[1].offset + 1}}
+			}
 		}
 	case 66:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:368
+//line parser.go.y:409
 		{
 			yyVAL.value = &Term{Type: TermTypeNumber, Number: yyDollar[1].token}
 		}
 	case 67:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:372
+//line parser.go.y:413
 		{
 			yyVAL.value = &Term{Type: TermTypeFormat, Format: yyDollar[1].token}
 		}
 	case 68:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line parser.go.y:376
+//line parser.go.y:417
 		{
 			yyVAL.value = &Term{Type: TermTypeFormat, Format: yyDollar[1].token, Str: yyDollar[2].value.(*String)}
 		}
 	case 69:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:380
+//line parser.go.y:421
 		{
 			yyVAL.value = &Term{Type: TermTypeString, Str: yyDollar[1].value.(*String)}
 		}
 	case 70:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line parser.go.y:384
+//line parser.go.y:425
 		{
 			yyVAL.value = &Term{Type: TermTypeQuery, Query: yyDollar[2].value.(*Query)}
 		}
 	case 71:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line parser.go.y:388
+//line parser.go.y:429
 		{
 			yyVAL.value = &Term{Type: TermTypeUnary, Unary: &Unary{OpAdd, yyDollar[2].value.(*Term)}}
 		}
 	case 72:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line parser.go.y:392
+//line parser.go.y:433
 		{
 			yyVAL.value = &Term{Type: TermTypeUnary, Unary: &Unary{OpSub, yyDollar[2].value.(*Term)}}
 		}
 	case 73:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line parser.go.y:396
+//line parser.go.y:437
 		{
 			yyVAL.value = &Term{Type: TermTypeObject, Object: &Object{}}
 		}
 	case 74:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line parser.go.y:400
+//line parser.go.y:441
 		{
 			yyVAL.value = &Term{Type: TermTypeObject, Object: &Object{yyDollar[2].value.([]*ObjectKeyVal)}}
 		}
 	case 75:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line parser.go.y:404
+//line parser.go.y:445
 		{
 			yyVAL.value = &Term{Type: TermTypeObject, Object: &Object{yyDollar[2].value.([]*ObjectKeyVal)}}
 		}
 	case 76:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line parser.go.y:408
+//line parser.go.y:449
 		{
 			yyVAL.value = &Term{Type: TermTypeArray, Array: &Array{}}
 		}
 	case 77:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line parser.go.y:412
+//line parser.go.y:453
 		{
 			yyVAL.value = &Term{Type: TermTypeArray, Array: &Array{yyDollar[2].value.(*Query)}}
 		}
 	case 78:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line parser.go.y:416
+//line parser.go.y:457
 		{
 			yyVAL.value = &Term{Type: TermTypeBreak, Break: yyDollar[2].token}
 		}
 	case 79:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line parser.go.y:420
+//line parser.go.y:461
 		{
 			yyDollar[1].value.(*Term).SuffixList = append(yyDollar[1].value.(*Term).SuffixList, &Suffix{Index: &Index{Name: yyDollar[2].token}})
 		}
 	case 80:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line parser.go.y:424
+//line parser.go.y:465
 		{
 			yyDollar[1].value.(*Term).SuffixList = append(yyDollar[1].value.(*Term).SuffixList, yyDollar[2].value.(*Suffix))
 		}
 	case 81:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line parser.go.y:428
+//line parser.go.y:469
 		{
 			yyDollar[1].value.(*Term).SuffixList = append(yyDollar[1].value.(*Term).SuffixList, &Suffix{Optional: true})
 		}
 	case 82:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line parser.go.y:432
+//line parser.go.y:473
 		{
 			yyDollar[1].value.(*Term).SuffixList = append(yyDollar[1].value.(*Term).SuffixList, yyDollar[3].value.(*Suffix))
 		}
 	case 83:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line parser.go.y:436
+//line parser.go.y:477
 		{
 			yyDollar[1].value.(*Term).SuffixList = append(yyDollar[1].value.(*Term).SuffixList, &Suffix{Index: &Index{Str: yyDollar[3].value.(*String)}})
 		}
 	case 84:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:442
+//line parser.go.y:483
 		{
 			yyVAL.value = &String{Str: yyDollar[1].token}
 		}
 	case 85:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line parser.go.y:446
+//line parser.go.y:487
 		{
 			yyVAL.value = &String{Queries: yyDollar[2].value.([]*Query)}
 		}
 	case 86:
 		yyDollar = yyS[yypt-0 : yypt+1]
-//line parser.go.y:452
+//line parser.go.y:493
 		{
 			yyVAL.value = []*Query{}
 		}
 	case 87:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line parser.go.y:456
+//line parser.go.y:497
 		{
 			yyVAL.value = append(yyDollar[1].value.([]*Query), &Query{Term: &Term{Type: TermTypeString, Str: &String{Str: yyDollar[2].token}}})
 		}
 	case 88:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line parser.go.y:460
+//line parser.go.y:501
 		{
 			yylex.(*lexer).inString = true
 			yyVAL.value = append(yyDollar[1].value.([]*Query), &Query{Term: &Term{Type: TermTypeQuery, Query: yyDollar[3].value.(*Query)}})
 		}
 	case 89:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:466
+//line parser.go.y:507
 		{
 		}
 	case 90:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:467
+//line parser.go.y:508
 		{
 		}
 	case 91:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:470
+//line parser.go.y:511
 		{
 		}
 	case 92:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:471
+//line parser.go.y:512
 		{
 		}
 	case 93:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line parser.go.y:475
+//line parser.go.y:516
 		{
 			yyVAL.value = &Suffix{Iter: true}
 		}
 	case 94:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line parser.go.y:479
+//line parser.go.y:520
 		{
 			yyVAL.value = &Suffix{Index: &Index{Start: yyDollar[2].value.(*Query)}}
 		}
 	case 95:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line parser.go.y:483
+//line parser.go.y:524
 		{
 			yyVAL.value = &Suffix{Index: &Index{Start: yyDollar[2].value.(*Query), IsSlice: true}}
 		}
 	case 96:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line parser.go.y:487
+//line parser.go.y:528
 		{
 			yyVAL.value = &Suffix{Index: &Index{End: yyDollar[3].value.(*Query), IsSlice: true}}
 		}
 	case 97:
 		yyDollar = yyS[yypt-5 : yypt+1]
-//line parser.go.y:491
+//line parser.go.y:532
 		{
 			yyVAL.value = &Suffix{Index: &Index{Start: yyDollar[2].value.(*Query), End: yyDollar[4].value.(*Query), IsSlice: true}}
 		}
 	case 98:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:497
+//line parser.go.y:538
 		{
 			yyVAL.value = []*Query{yyDollar[1].value.(*Query)}
 		}
 	case 99:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line parser.go.y:501
+//line parser.go.y:542
 		{
 			yyVAL.value = append(yyDollar[1].value.([]*Query), yyDollar[3].value.(*Query))
 		}
 	case 100:
 		yyDollar = yyS[yypt-0 : yypt+1]
-//line parser.go.y:507
+//line parser.go.y:548
 		{
 			yyVAL.value = []*IfElif(nil)
 		}
 	case 101:
 		yyDollar = yyS[yypt-5 : yypt+1]
-//line parser.go.y:511
+//line parser.go.y:552
 		{
 			yyVAL.value = append(yyDollar[1].value.([]*IfElif), &IfElif{yyDollar[3].value.(*Query), yyDollar[5].value.(*Query)})
 		}
 	case 102:
 		yyDollar = yyS[yypt-0 : yypt+1]
-//line parser.go.y:517
+//line parser.go.y:558
 		{
 			yyVAL.value = (*Query)(nil)
 		}
 	case 103:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line parser.go.y:521
+//line parser.go.y:562
 		{
 			yyVAL.value = yyDollar[2].value
 		}
 	case 104:
 		yyDollar = yyS[yypt-0 : yypt+1]
-//line parser.go.y:527
+//line parser.go.y:568
 		{
 			yyVAL.value = (*Query)(nil)
 		}
 	case 105:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line parser.go.y:531
+//line parser.go.y:572
 		{
 			yyVAL.value = yyDollar[2].value
 		}
 	case 106:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:537
+//line parser.go.y:578
 		{
 			yyVAL.value = []*ObjectKeyVal{yyDollar[1].value.(*ObjectKeyVal)}
 		}
 	case 107:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line parser.go.y:541
+//line parser.go.y:582
 		{
 			yyVAL.value = append(yyDollar[1].value.([]*ObjectKeyVal), yyDollar[3].value.(*ObjectKeyVal))
 		}
 	case 108:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line parser.go.y:547
+//line parser.go.y:588
 		{
-			yyVAL.value = &ObjectKeyVal{Key: yyDollar[1].token, Val: yyDollar[3].value.(*ObjectVal)}
+			yyVAL.value = &ObjectKeyVal{Key: yyDollar[1].token, Val: yyDollar[3].value.(*ObjectVal), offset: yyDollar[1].offset + 1}
 		}
 	case 109:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line parser.go.y:551
+//line parser.go.y:592
 		{
 			yyVAL.value = &ObjectKeyVal{KeyString: yyDollar[1].value.(*String), Val: yyDollar[3].value.(*ObjectVal)}
 		}
 	case 110:
-		yyDollar = yyS[yypt-5 : yypt+1]
-//line parser.go.y:555
+		yyDollar = yyS[yypt-4 : yypt+1]
+//line parser.go.y:596
 		{
-			yyVAL.value = &ObjectKeyVal{KeyQuery: yyDollar[2].value.(*Query), Val: yyDollar[5].value.(*ObjectVal)}
+			yyVAL.value = &ObjectKeyVal{KeyFormat: yyDollar[1].token, KeyString: yyDollar[2].value.(*String), Val: yyDollar[4].value.(*ObjectVal)}
 		}
 	case 111:
-		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:559
+		yyDollar = yyS[yypt-5 : yypt+1]
+//line parser.go.y:600
 		{
-			yyVAL.value = &ObjectKeyVal{Key: yyDollar[1].token}
+			yyVAL.value = &ObjectKeyVal{KeyQuery: yyDollar[2].value.(*Query), Val: yyDollar[5].value.(*ObjectVal)}
 		}
 	case 112:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:563
+//line parser.go.y:604
 		{
-			yyVAL.value = &ObjectKeyVal{KeyString: yyDollar[1].value.(*String)}
+			if yyDollar[1].token == "$__loc__" {
+				yyVAL.value = &ObjectKeyVal{Key: yyDollar[1].token, loc: yyDollar[1].value.(*location)}
+			} else {
+				yyVAL.value = &ObjectKeyVal{Key: yyDollar[1].token, offset: yyDollar[1].offset + 1}
+			}
 		}
 	case 113:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:568
+//line parser.go.y:612
 		{
+			yyVAL.value = &ObjectKeyVal{KeyString: yyDollar[1].value.(*String)}
 		}
 	case 114:
-		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:569
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line parser.go.y:616
 		{
+			yyVAL.value = &ObjectKeyVal{KeyFormat: yyDollar[1].token, KeyString: yyDollar[2].value.(*String)}
 		}
 	case 115:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:570
+//line parser.go.y:621
 		{
 		}
 	case 116:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:574
+//line parser.go.y:622
 		{
-			yyVAL.value = &ObjectVal{[]*Query{{Term: yyDollar[1].value.(*Term)}}}
 		}
 	case 117:
-		yyDollar = yyS[yypt-3 : yypt+1]
-//line parser.go.y:578
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line parser.go.y:623
 		{
-			yyVAL.value = &ObjectVal{append(yyDollar[1].value.(*ObjectVal).Queries, &Query{Term: yyDollar[3].value.(*Term)})}
 		}
 	case 118:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:584
+//line parser.go.y:627
 		{
-			yyVAL.value = &ConstTerm{Object: yyDollar[1].value.(*ConstObject)}
+			yyVAL.value = &ObjectVal{[]*Query{{Term: yyDollar[1].value.(*Term), offset: yyDollar[1].offset + 1}}}
 		}
 	case 119:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line parser.go.y:631
+		{
+			yyVAL.value = &ObjectVal{append(yyDollar[1].value.(*ObjectVal).Queries, &Query{Term: yyDollar[3].value.(*Term), offset: yyDollar[3].offset + 1})}
+		}
+	case 120:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:588
+//line parser.go.y:637
+		{
+			yyVAL.value = &ConstTerm{Object: yyDollar[1].value.(*ConstObject)}
+		}
+	case 121:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line parser.go.y:641
 		{
 			yyVAL.value = &ConstTerm{Array: yyDollar[1].value.(*ConstArray)}
 		}
-	case 120:
+	case 122:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:592
+//line parser.go.y:645
 		{
 			yyVAL.value = &ConstTerm{Number: yyDollar[1].token}
 		}
-	case 121:
+	case 123:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:596
+//line parser.go.y:649
 		{
 			yyVAL.value = &ConstTerm{Str: yyDollar[1].token}
 		}
-	case 122:
+	case 124:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:600
+//line parser.go.y:653
 		{
 			yyVAL.value = &ConstTerm{Null: true}
 		}
-	case 123:
+	case 125:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:604
+//line parser.go.y:657
 		{
 			yyVAL.value = &ConstTerm{True: true}
 		}
-	case 124:
+	case 126:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:608
+//line parser.go.y:661
 		{
 			yyVAL.value = &ConstTerm{False: true}
 		}
-	case 125:
+	case 127:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line parser.go.y:614
+//line parser.go.y:667
 		{
 			yyVAL.value = &ConstObject{}
 		}
-	case 126:
+	case 128:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line parser.go.y:618
+//line parser.go.y:671
 		{
 			yyVAL.value = &ConstObject{yyDollar[2].value.([]*ConstObjectKeyVal)}
 		}
-	case 127:
+	case 129:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line parser.go.y:622
+//line parser.go.y:675
 		{
 			yyVAL.value = &ConstObject{yyDollar[2].value.([]*ConstObjectKeyVal)}
 		}
-	case 128:
+	case 130:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:628
+//line parser.go.y:681
 		{
 			yyVAL.value = []*ConstObjectKeyVal{yyDollar[1].value.(*ConstObjectKeyVal)}
 		}
-	case 129:
+	case 131:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line parser.go.y:632
+//line parser.go.y:685
 		{
 			yyVAL.value = append(yyDollar[1].value.([]*ConstObjectKeyVal), yyDollar[3].value.(*ConstObjectKeyVal))
 		}
-	case 130:
+	case 132:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line parser.go.y:638
+//line parser.go.y:691
 		{
 			yyVAL.value = &ConstObjectKeyVal{Key: yyDollar[1].token, Val: yyDollar[3].value.(*ConstTerm)}
 		}
-	case 131:
+	case 133:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line parser.go.y:642
+//line parser.go.y:695
 		{
 			yyVAL.value = &ConstObjectKeyVal{Key: yyDollar[1].token, Val: yyDollar[3].value.(*ConstTerm)}
 		}
-	case 132:
+	case 134:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line parser.go.y:646
+//line parser.go.y:699
 		{
 			yyVAL.value = &ConstObjectKeyVal{KeyString: yyDollar[1].token, Val: yyDollar[3].value.(*ConstTerm)}
 		}
-	case 133:
+	case 135:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line parser.go.y:652
+//line parser.go.y:705
 		{
 			yyVAL.value = &ConstArray{}
 		}
-	case 134:
+	case 136:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line parser.go.y:656
+//line parser.go.y:709
 		{
 			yyVAL.value = &ConstArray{yyDollar[2].value.([]*ConstTerm)}
 		}
-	case 135:
+	case 137:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:662
+//line parser.go.y:715
 		{
 			yyVAL.value = []*ConstTerm{yyDollar[1].value.(*ConstTerm)}
 		}
-	case 136:
+	case 138:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line parser.go.y:666
+//line parser.go.y:719
 		{
 			yyVAL.value = append(yyDollar[1].value.([]*ConstTerm), yyDollar[3].value.(*ConstTerm))
 		}
-	case 137:
-		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:671
-		{
-		}
-	case 138:
-		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:672
-		{
-		}
 	case 139:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:673
+//line parser.go.y:724
 		{
 		}
 	case 140:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:674
+//line parser.go.y:725
 		{
 		}
 	case 141:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:675
+//line parser.go.y:726
 		{
 		}
 	case 142:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:676
+//line parser.go.y:727
 		{
 		}
 	case 143:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:677
+//line parser.go.y:728
 		{
 		}
 	case 144:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:678
+//line parser.go.y:729
 		{
 		}
 	case 145:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:679
+//line parser.go.y:730
 		{
 		}
 	case 146:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:680
+//line parser.go.y:731
 		{
 		}
 	case 147:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:681
+//line parser.go.y:732
 		{
 		}
 	case 148:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:682
+//line parser.go.y:733
 		{
 		}
 	case 149:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:683
+//line parser.go.y:734
 		{
 		}
 	case 150:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:684
+//line parser.go.y:735
 		{
 		}
 	case 151:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:685
+//line parser.go.y:736
 		{
 		}
 	case 152:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:686
+//line parser.go.y:737
 		{
 		}
 	case 153:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:687
+//line parser.go.y:738
 		{
 		}
 	case 154:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:688
+//line parser.go.y:739
 		{
 		}
 	case 155:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:689
+//line parser.go.y:740
 		{
 		}
 	case 156:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:690
+//line parser.go.y:741
 		{
 		}
 	case 157:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:691
+//line parser.go.y:742
+		{
+		}
+	case 158:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line parser.go.y:743
+		{
+		}
+	case 159:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line parser.go.y:744
 		{
 		}
 	}
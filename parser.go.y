@@ -3,15 +3,29 @@ package gojq
 
 // Parse a query string, and returns the query struct.
 //
-// If parsing failed, the returned error has the method Token() (string, int),
-// which reports the invalid token and the byte offset in the query string. The
-// token is empty if the error occurred after scanning the entire query string.
-// The byte offset is the scanned bytes when the error occurred.
+// If parsing failed, the returned error implements [ParseError], which reports
+// the invalid token with its byte offset, line and column in the query string.
 func Parse(src string) (*Query, error) {
+	return ParseWithOptions(src)
+}
+
+// ParseWithOptions parses a query string with the parse options. Use this
+// function to limit the sizes of the literals in the queries from untrusted
+// sources, see [WithMaxStringLiteralLength] and [WithMaxArrayLiteralLength].
+// The nesting depth of the query is limited by default, see
+// [WithMaxQueryDepth].
+func ParseWithOptions(src string, options ...ParseOption) (*Query, error) {
 	l := newLexer(src)
-	if yyParse(l) > 0 {
+	l.maxQueryDepth = defaultMaxQueryDepth
+	for _, opt := range options {
+		opt(l)
+	}
+	if yyParse(l) > 0 || l.err != nil {
 		return nil, l.err
 	}
+	if err := l.checkDepth(); err != nil {
+		return nil, err
+	}
 	return l.result, nil
 }
 
@@ -34,6 +48,7 @@ func prependFuncDef(xs []*FuncDef, x *FuncDef) []*FuncDef {
   value    any
   token    string
   operator Operator
+  offset   int
 }
 
 %type<value> program moduleheader programbody imports import metaopt funcdefs funcdef funcdefargs query
@@ -140,17 +155,27 @@ funcdef
     }
     | tokDef tokIdent '(' funcdefargs ')' ':' query ';'
     {
-        $$ = &FuncDef{$2, $4.([]string), $7.(*Query)}
+        fd := $4.(*FuncDef)
+        fd.Name, fd.Body = $2, $7.(*Query)
+        $$ = fd
     }
 
 funcdefargs
     : tokIdentVariable
     {
-        $$ = []string{$1}
+        if $1 == "$__loc__" {
+            yylex.(*lexer).bindLocError($<value>1)
+        }
+        $$ = &FuncDef{Args: []string{$1}, argOffsets: []int{$<offset>1 + 1}}
     }
     | funcdefargs ';' tokIdentVariable
     {
-        $$ = append($1.([]string), $3)
+        if $3 == "$__loc__" {
+            yylex.(*lexer).bindLocError($<value>3)
+        }
+        fd := $1.(*FuncDef)
+        fd.Args, fd.argOffsets = append(fd.Args, $3), append(fd.argOffsets, $<offset>3 + 1)
+        $$ = fd
     }
 
 tokIdentVariable
@@ -165,92 +190,95 @@ query
     }
     | query '|' query
     {
-        $$ = &Query{Left: $1.(*Query), Op: OpPipe, Right: $3.(*Query)}
+        $$ = &Query{Left: $1.(*Query), Op: OpPipe, Right: $3.(*Query), offset: $<offset>1 + 1}
     }
     | term tokAs bindpatterns '|' query
     {
         $1.(*Term).SuffixList = append($1.(*Term).SuffixList, &Suffix{Bind: &Bind{$3.([]*Pattern), $5.(*Query)}})
-        $$ = &Query{Term: $1.(*Term)}
+        $$ = &Query{Term: $1.(*Term), offset: $<offset>1 + 1}
     }
     | tokReduce term tokAs pattern '(' query ';' query ')'
     {
-        $$ = &Query{Term: &Term{Type: TermTypeReduce, Reduce: &Reduce{$2.(*Term), $4.(*Pattern), $6.(*Query), $8.(*Query)}}}
+        $$ = &Query{Term: &Term{Type: TermTypeReduce, Reduce: &Reduce{$2.(*Term), $4.(*Pattern), $6.(*Query), $8.(*Query)}}, offset: $<offset>1 + 1}
     }
     | tokForeach term tokAs pattern '(' query ';' query ')'
     {
-        $$ = &Query{Term: &Term{Type: TermTypeForeach, Foreach: &Foreach{$2.(*Term), $4.(*Pattern), $6.(*Query), $8.(*Query), nil}}}
+        $$ = &Query{Term: &Term{Type: TermTypeForeach, Foreach: &Foreach{$2.(*Term), $4.(*Pattern), $6.(*Query), $8.(*Query), nil}}, offset: $<offset>1 + 1}
     }
     | tokForeach term tokAs pattern '(' query ';' query ';' query ')'
     {
-        $$ = &Query{Term: &Term{Type: TermTypeForeach, Foreach: &Foreach{$2.(*Term), $4.(*Pattern), $6.(*Query), $8.(*Query), $10.(*Query)}}}
+        $$ = &Query{Term: &Term{Type: TermTypeForeach, Foreach: &Foreach{$2.(*Term), $4.(*Pattern), $6.(*Query), $8.(*Query), $10.(*Query)}}, offset: $<offset>1 + 1}
     }
     | tokIf query tokThen query ifelifs ifelse tokEnd
     {
-        $$ = &Query{Term: &Term{Type: TermTypeIf, If: &If{$2.(*Query), $4.(*Query), $5.([]*IfElif), $6.(*Query)}}}
+        $$ = &Query{Term: &Term{Type: TermTypeIf, If: &If{$2.(*Query), $4.(*Query), $5.([]*IfElif), $6.(*Query)}}, offset: $<offset>1 + 1}
     }
     | tokTry query trycatch
     {
-        $$ = &Query{Term: &Term{Type: TermTypeTry, Try: &Try{$2.(*Query), $3.(*Query)}}}
+        $$ = &Query{Term: &Term{Type: TermTypeTry, Try: &Try{$2.(*Query), $3.(*Query)}}, offset: $<offset>1 + 1}
     }
     | tokLabel tokVariable '|' query
     {
-        $$ = &Query{Term: &Term{Type: TermTypeLabel, Label: &Label{$2, $4.(*Query)}}}
+        if $2 == "$__loc__" {
+            yylex.(*lexer).bindLocError($<value>2)
+        }
+        $$ = &Query{Term: &Term{Type: TermTypeLabel, Label: &Label{$2, $4.(*Query)}}, offset: $<offset>1 + 1}
     }
     | query '?'
     {
         if t := $1.(*Query).Term; t != nil {
             t.SuffixList = append(t.SuffixList, &Suffix{Optional: true})
         } else {
-            $$ = &Query{Term: &Term{Type: TermTypeQuery, Query: $1.(*Query), SuffixList: []*Suffix{{Optional: true}}}}
+            $$ = &Query{Term: &Term{Type: TermTypeQuery, Query: $1.(*Query), SuffixList: []*Suffix{{Optional: true}}}, offset: $<offset>1 + 1}
         }
     }
     | query ',' query
     {
-        $$ = &Query{Left: $1.(*Query), Op: OpComma, Right: $3.(*Query)}
+        $$ = &Query{Left: $1.(*Query), Op: OpComma, Right: $3.(*Query), offset: $<offset>1 + 1}
     }
     | query tokAltOp query
     {
-        $$ = &Query{Left: $1.(*Query), Op: $2, Right: $3.(*Query)}
+        $$ = &Query{Left: $1.(*Query), Op: $2, Right: $3.(*Query), offset: $<offset>1 + 1}
     }
     | query tokUpdateOp query
     {
-        $$ = &Query{Left: $1.(*Query), Op: $2, Right: $3.(*Query)}
+        $$ = &Query{Left: $1.(*Query), Op: $2, Right: $3.(*Query), offset: $<offset>1 + 1}
     }
     | query tokOrOp query
     {
-        $$ = &Query{Left: $1.(*Query), Op: OpOr, Right: $3.(*Query)}
+        $$ = &Query{Left: $1.(*Query), Op: OpOr, Right: $3.(*Query), offset: $<offset>1 + 1}
     }
     | query tokAndOp query
     {
-        $$ = &Query{Left: $1.(*Query), Op: OpAnd, Right: $3.(*Query)}
+        $$ = &Query{Left: $1.(*Query), Op: OpAnd, Right: $3.(*Query), offset: $<offset>1 + 1}
     }
     | query tokCompareOp query
     {
-        $$ = &Query{Left: $1.(*Query), Op: $2, Right: $3.(*Query)}
+        $$ = &Query{Left: $1.(*Query), Op: $2, Right: $3.(*Query), offset: $<offset>1 + 1}
     }
     | query '+' query
     {
-        $$ = &Query{Left: $1.(*Query), Op: OpAdd, Right: $3.(*Query)}
+        $$ = &Query{Left: $1.(*Query), Op: OpAdd, Right: $3.(*Query), offset: $<offset>1 + 1}
     }
     | query '-' query
     {
-        $$ = &Query{Left: $1.(*Query), Op: OpSub, Right: $3.(*Query)}
+        $$ = &Query{Left: $1.(*Query), Op: OpSub, Right: $3.(*Query), offset: $<offset>1 + 1}
     }
     | query '*' query
     {
-        $$ = &Query{Left: $1.(*Query), Op: OpMul, Right: $3.(*Query)}
+        $$ = &Query{Left: $1.(*Query), Op: OpMul, Right: $3.(*Query), offset: $<offset>1 + 1}
     }
     | query '/' query
     {
-        $$ = &Query{Left: $1.(*Query), Op: OpDiv, Right: $3.(*Query)}
+        $$ = &Query{Left: $1.(*Query), Op: OpDiv, Right: $3.(*Query), offset: $<offset>1 + 1}
     }
     | query '%' query
     {
-        $$ = &Query{Left: $1.(*Query), Op: OpMod, Right: $3.(*Query)}
+        $$ = &Query{Left: $1.(*Query), Op: OpMod, Right: $3.(*Query), offset: $<offset>1 + 1}
     }
     | term %prec tokTermPost
     {
-        $$ = &Query{Term: $1.(*Term)}
+        $$ = &Query{Term: $1.(*Term), offset: $<offset>1 + 1}
     }
 
 bindpatterns
@@ -266,7 +294,10 @@ bindpatterns
 pattern
     : tokVariable
     {
-        $$ = &Pattern{Name: $1}
+        if $1 == "$__loc__" {
+            yylex.(*lexer).bindLocError($<value>1)
+        }
+        $$ = &Pattern{Name: $1, offset: $<offset>1 + 1}
     }
     | '[' arraypatterns ']'
     {
@@ -300,7 +331,10 @@ objectpatterns
 objectpattern
     : objectkey ':' pattern
     {
-        $$ = &PatternObject{Key: $1, Val: $3.(*Pattern)}
+        if $1 == "$__loc__" {
+            yylex.(*lexer).bindLocError($<value>1)
+        }
+        $$ = &PatternObject{Key: $1, Val: $3.(*Pattern), offset: $<offset>1 + 1}
     }
     | string ':' pattern
     {
@@ -312,7 +346,10 @@ objectpattern
     }
     | tokVariable
     {
-        $$ = &PatternObject{Key: $1}
+        if $1 == "$__loc__" {
+            yylex.(*lexer).bindLocError($<value>1)
+        }
+        $$ = &PatternObject{Key: $1, offset: $<offset>1 + 1}
     }
 
 term
@@ -354,15 +391,19 @@ term
     }
     | tokIdentModuleIdent
     {
-        $$ = &Term{Type: TermTypeFunc, Func: &Func{Name: $1}}
+        $$ = &Term{Type: TermTypeFunc, Func: &Func{Name: $1, offset: $<offset>1 + 1}}
     }
     | tokIdentModuleIdent '(' args ')'
     {
-        $$ = &Term{Type: TermTypeFunc, Func: &Func{Name: $1, Args: $3.([]*Query)}}
+        $$ = &Term{Type: TermTypeFunc, Func: &Func{Name: $1, Args: $3.([]*Query), offset: $<offset>1 + 1}}
     }
     | tokVariableModuleVariable
     {
-        $$ = &Term{Type: TermTypeFunc, Func: &Func{Name: $1}}
+        if $1 == "$__loc__" {
+            $$ = &Term{Type: TermTypeFunc, Func: &Func{Name: $1, loc: $<value>1.(*location)}}
+        } else {
+            $$ = &Term{Type: TermTypeFunc, Func: &Func{Name: $1, offset: $<offset>1 + 1}}
+        }
     }
     | tokNumber
     {
@@ -545,24 +586,36 @@ objectkeyvals
 objectkeyval
     : objectkey ':' objectval
     {
-        $$ = &ObjectKeyVal{Key: $1, Val: $3.(*ObjectVal)}
+        $$ = &ObjectKeyVal{Key: $1, Val: $3.(*ObjectVal), offset: $<offset>1 + 1}
     }
     | string ':' objectval
     {
         $$ = &ObjectKeyVal{KeyString: $1.(*String), Val: $3.(*ObjectVal)}
     }
+    | tokFormat string ':' objectval
+    {
+        $$ = &ObjectKeyVal{KeyFormat: $1, KeyString: $2.(*String), Val: $4.(*ObjectVal)}
+    }
     | '(' query ')' ':' objectval
     {
         $$ = &ObjectKeyVal{KeyQuery: $2.(*Query), Val: $5.(*ObjectVal)}
     }
     | objectkey
     {
-        $$ = &ObjectKeyVal{Key: $1}
+        if $1 == "$__loc__" {
+            $$ = &ObjectKeyVal{Key: $1, loc: $<value>1.(*location)}
+        } else {
+            $$ = &ObjectKeyVal{Key: $1, offset: $<offset>1 + 1}
+        }
     }
     | string
     {
         $$ = &ObjectKeyVal{KeyString: $1.(*String)}
     }
+    | tokFormat string
+    {
+        $$ = &ObjectKeyVal{KeyFormat: $1, KeyString: $2.(*String)}
+    }
 
 objectkey
     : tokIdent {}
@@ -572,11 +625,11 @@ objectkey
 objectval
     : term
     {
-        $$ = &ObjectVal{[]*Query{{Term: $1.(*Term)}}}
+        $$ = &ObjectVal{[]*Query{{Term: $1.(*Term), offset: $<offset>1 + 1}}}
     }
     | objectval '|' term
     {
-        $$ = &ObjectVal{append($1.(*ObjectVal).Queries, &Query{Term: $3.(*Term)})}
+        $$ = &ObjectVal{append($1.(*ObjectVal).Queries, &Query{Term: $3.(*Term), offset: $<offset>3 + 1})}
     }
 
 constterm
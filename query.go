@@ -15,6 +15,7 @@ type Query struct {
 	Op       Operator
 	Right    *Query
 	Func     string
+	offset   int // the offset in the source text plus one, or zero if unknown
 }
 
 // Run the query.
@@ -142,6 +143,8 @@ type FuncDef struct {
 	Name string
 	Args []string
 	Body *Query
+
+	argOffsets []int // the offsets of the arguments in the source text plus one
 }
 
 func (e *FuncDef) String() string {
@@ -392,6 +395,8 @@ type Pattern struct {
 	Name   string
 	Array  []*Pattern
 	Object []*PatternObject
+
+	offset int // the offset of the name in the source text plus one, or zero if unknown
 }
 
 func (e *Pattern) String() string {
@@ -430,6 +435,8 @@ type PatternObject struct {
 	KeyString *String
 	KeyQuery  *Query
 	Val       *Pattern
+
+	offset int // the offset of the key in the source text plus one, or zero if unknown
 }
 
 func (e *PatternObject) String() string {
@@ -552,6 +559,9 @@ func (e *Index) toIndices(xs []any) []any {
 type Func struct {
 	Name string
 	Args []*Query
+	loc  *location // the location of $__loc__
+
+	offset int // the offset in the source text plus one, or zero if unknown
 }
 
 func (e *Func) String() string {
@@ -581,12 +591,31 @@ func (e *Func) minify() {
 }
 
 func (e *Func) toFunc() string {
-	if len(e.Args) != 0 {
+	if len(e.Args) != 0 || e.loc != nil {
 		return ""
 	}
 	return e.Name
 }
 
+// The location of $__loc__, which the parser records since the queries do not
+// hold the offsets in the source text. The compiler resolves $__loc__ to the
+// object of the file name and the line number.
+type location struct {
+	file string
+	line int
+}
+
+func (loc *location) value() map[string]any {
+	file, line := "<top-level>", 1
+	if loc != nil {
+		if loc.file != "" {
+			file = loc.file
+		}
+		line = loc.line
+	}
+	return map[string]any{"file": file, "line": line}
+}
+
 // String ...
 type String struct {
 	Str     string
@@ -658,9 +687,13 @@ func (e *Object) minify() {
 // ObjectKeyVal ...
 type ObjectKeyVal struct {
 	Key       string
+	KeyFormat string
 	KeyString *String
 	KeyQuery  *Query
 	Val       *ObjectVal
+	loc       *location // the location of {$__loc__}
+
+	offset int // the offset of the key in the source text plus one, or zero if unknown
 }
 
 func (e *ObjectKeyVal) String() string {
@@ -673,6 +706,10 @@ func (e *ObjectKeyVal) writeTo(s *strings.Builder) {
 	if e.Key != "" {
 		s.WriteString(e.Key)
 	} else if e.KeyString != nil {
+		if e.KeyFormat != "" {
+			s.WriteString(e.KeyFormat)
+			s.WriteByte(' ')
+		}
 		e.KeyString.writeTo(s)
 	} else if e.KeyQuery != nil {
 		s.WriteByte('(')
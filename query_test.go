@@ -3,18 +3,25 @@ package gojq_test
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"go/ast"
+	"io"
 	"log"
 	"math"
 	"math/big"
 	"os"
+	"os/exec"
 	"reflect"
+	"regexp/syntax"
 	"strconv"
 	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/google/go-cmp/cmp"
+
 	"github.com/rturpen/gojq"
 )
 
@@ -93,6 +100,84 @@ func TestQueryRun_Errors(t *testing.T) {
 	}
 }
 
+func TestQueryRun_ErrorValue(t *testing.T) {
+	query, err := gojq.Parse(`try error({x: .}) catch error({y: .x})`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iter := query.Run([]any{1})
+	v, _ := iter.Next()
+	if err, ok := v.(gojq.ValueError); !ok {
+		t.Errorf("should emit a value error but got: %v", v)
+	} else if expected := map[string]any{"y": []any{1}}; !reflect.DeepEqual(err.Value(), expected) {
+		t.Errorf("expected: %v, got: %v", expected, err.Value())
+	} else if expected := `error: {"y":[1]}`; err.Error() != expected {
+		t.Errorf("expected: %v, got: %v", expected, err)
+	}
+	if v, ok := iter.Next(); ok {
+		t.Errorf("should not emit a value but got: %v", v)
+	}
+}
+
+func TestQueryRun_HaltError(t *testing.T) {
+	testCases := []struct {
+		src      string
+		values   []any
+		value    any
+		exitCode int
+	}{
+		{"1, halt, 2", []any{1}, nil, 0},
+		{`1, ("x" | halt_error), 2`, []any{1}, "x", 5},
+		{".[] | if . == 2 then {a: .} | halt_error(3) end", []any{1}, map[string]any{"a": 2}, 3},
+		{"try halt_error(1) catch 0", nil, []any{1, 2, 3}, 1},
+		{"label $l | (1, 2) as $x | if $x == 1 then halt else $x end", nil, nil, 0},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.src, func(t *testing.T) {
+			query, err := gojq.Parse(tc.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			iter := query.Run([]any{1, 2, 3})
+			var values []any
+			for {
+				v, ok := iter.Next()
+				if !ok {
+					t.Fatal("should emit a halt error")
+				}
+				if err, ok := v.(error); ok {
+					var herr gojq.HaltError
+					if !errors.As(err, &herr) {
+						t.Fatalf("should emit a halt error but got: %v", err)
+					}
+					if !reflect.DeepEqual(herr.Value(), tc.value) {
+						t.Errorf("expected: %v, got: %v", tc.value, herr.Value())
+					}
+					if herr.ExitCode() != tc.exitCode {
+						t.Errorf("expected: %v, got: %v", tc.exitCode, herr.ExitCode())
+					}
+					break
+				}
+				values = append(values, v)
+			}
+			if !reflect.DeepEqual(values, tc.values) {
+				t.Errorf("expected: %v, got: %v", tc.values, values)
+			}
+			if v, ok := iter.Next(); ok {
+				t.Errorf("should not emit a value after halting but got: %v", v)
+			}
+		})
+	}
+	query, err := gojq.Parse(`error("x")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, _ := query.Run(nil).Next()
+	if _, ok := v.(gojq.HaltError); ok {
+		t.Errorf("should not emit a halt error: %v", v)
+	}
+}
+
 func TestQueryRun_ObjectError(t *testing.T) {
 	query, err := gojq.Parse(".[] | {(.): 1}")
 	if err != nil {
@@ -179,6 +264,68 @@ func TestQueryRun_IteratorError(t *testing.T) {
 	}
 }
 
+func TestQueryRun_ErrorUnwrap(t *testing.T) {
+	query, err := gojq.Parse(`.[0] | fromjson`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iter := query.Run([]any{"{", "x"})
+	v, _ := iter.Next()
+	if err, ok := v.(error); !ok {
+		t.Errorf("should emit an error but got: %v", v)
+	} else if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("expected an error wrapping %v but got: %v", io.ErrUnexpectedEOF, err)
+	}
+	query, err = gojq.Parse(`.[1] | test("(")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iter = query.Run([]any{"{", "x"})
+	v, _ = iter.Next()
+	var serr *syntax.Error
+	if err, ok := v.(error); !ok {
+		t.Errorf("should emit an error but got: %v", v)
+	} else if !errors.As(err, &serr) {
+		t.Errorf("expected an error wrapping *syntax.Error but got: %v", err)
+	}
+}
+
+func TestQueryRun_QueryError(t *testing.T) {
+	for _, tc := range []struct {
+		src, query string
+	}{
+		{`.[] | tostring | . + 1`, ". + 1"},
+		{`def f: {} | .[0]; 1 | f`, ".[0]"},
+		{`try error("x") catch fromjson`, "fromjson"},
+		{`error("x")`, ""},
+		{`halt_error`, ""},
+	} {
+		t.Run(tc.src, func(t *testing.T) {
+			query, err := gojq.Parse(tc.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			v, _ := query.Run([]any{"{"}).Next()
+			err, ok := v.(error)
+			if !ok {
+				t.Fatalf("should emit an error but got: %v", v)
+			}
+			var qerr gojq.QueryError
+			if !errors.As(err, &qerr) {
+				if tc.query != "" {
+					t.Errorf("expected an error with query %q but got: %v", tc.query, err)
+				}
+				return
+			}
+			if tc.query == "" {
+				t.Errorf("expected an error without query but got: %v", qerr.Query())
+			} else if got := qerr.Query().String(); got != tc.query {
+				t.Errorf("expected: %v, got: %v", tc.query, got)
+			}
+		})
+	}
+}
+
 func TestQueryRun_Strings(t *testing.T) {
 	query, err := gojq.Parse(
 		"[\"\x00\\\\\", \"\x1f\\\"\", \"\n\\n\n\\(\"\\n\")\n\\n\", " +
@@ -205,6 +352,63 @@ func TestQueryRun_Strings(t *testing.T) {
 	}
 }
 
+func TestQueryRun_FormatSh(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh is not found")
+	}
+	query, err := gojq.Parse("@sh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	xs := []any{
+		"", " ", "a b", "'", "''", `"`, `\`, `\'`, "$HOME", "${HOME}", "$(echo x)",
+		"`echo x`", "a; echo x", "a && echo x", "a | echo x", "a > x", "*", "?",
+		"[a]", "~", "!", "#", "-n", "a\nb", "a\tb", "\x7f", "\xff", "あ", "'$(echo x)'",
+		`'"$(echo x)"'`, 1, 1.5, -1e100, true, false, nil,
+	}
+	iter := query.Run(xs)
+	v, ok := iter.Next()
+	if !ok {
+		t.Fatal("should emit a value")
+	}
+	s, ok := v.(string)
+	if !ok {
+		t.Fatalf("should emit a string but got: %v", v)
+	}
+	out, err := exec.Command(sh, "-c", `printf '%s\0' `+s).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00")
+	if len(got) != len(xs) {
+		t.Fatalf("expected %d arguments but got %d: %q", len(xs), len(got), got)
+	}
+	for i, x := range xs {
+		expected, ok := x.(string)
+		if !ok {
+			expected = gojq.Preview(x)
+			if x, ok := x.(float64); ok {
+				expected = strconv.FormatFloat(x, 'g', -1, 64)
+			}
+		}
+		if got[i] != expected {
+			t.Errorf("expected: %q, got: %q", expected, got[i])
+		}
+	}
+	for _, x := range []any{
+		map[string]any{"a": "$(echo x)"},
+		[]any{[]any{"a"}},
+		[]any{"a", map[string]any{}},
+	} {
+		iter := query.Run(x)
+		v, _ := iter.Next()
+		if _, ok := v.(error); !ok {
+			t.Errorf("should emit an error but got: %v", v)
+		}
+	}
+}
+
 func TestQueryRun_NumericTypes(t *testing.T) {
 	query, err := gojq.Parse(".[] + 0 != 0")
 	if err != nil {
@@ -231,6 +435,38 @@ func TestQueryRun_NumericTypes(t *testing.T) {
 	}
 }
 
+func TestQueryRun_CyclicValue(t *testing.T) {
+	query, err := gojq.Parse(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	xs := []any{1, nil}
+	xs[1] = xs
+	m := map[string]any{}
+	m["x"] = []any{m}
+	ys := []any{nil, nil}
+	ys[0], ys[1] = ys, ys
+	zs := make([]any, 10000)
+	for i := range zs {
+		zs[i] = map[string]any{"i": json.Number("1"), "x": []any{nil}}
+	}
+	zs[len(zs)-1].(map[string]any)["x"].([]any)[0] = zs
+	for _, v := range []any{xs, m, []any{0, map[string]any{"y": m}}, ys, zs} {
+		iter := query.Run(v)
+		v, ok := iter.Next()
+		if !ok {
+			t.Fatal("should emit an error but got no output")
+		}
+		if err, ok := v.(error); ok {
+			if expected := "encountered a cycle in value: "; !strings.HasPrefix(err.Error(), expected) {
+				t.Errorf("expected: %v, got: %v", expected, err)
+			}
+		} else {
+			t.Errorf("should emit an error but got: %v", v)
+		}
+	}
+}
+
 func TestQueryRun_Input(t *testing.T) {
 	query, err := gojq.Parse("input")
 	if err != nil {
@@ -319,11 +555,325 @@ func TestQueryString(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	if !reflect.DeepEqual(q, r) {
+	// The offsets in the source text differ after formatting.
+	ignoreUnexported := cmp.FilterPath(func(p cmp.Path) bool {
+		sf, ok := p.Last().(cmp.StructField)
+		return ok && !ast.IsExported(sf.Name())
+	}, cmp.Ignore())
+	if !cmp.Equal(q, r, ignoreUnexported) {
 		t.Errorf("\n%v\n%v", q, r)
 	}
 }
 
+func TestQueryString_Loc(t *testing.T) {
+	q, err := gojq.Parse("1 |\n$__loc__, {$__loc__}, \"\\($__loc__.line)\"")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `1 | $__loc__, { $__loc__ }, "\($__loc__.line)"`
+	if got := q.String(); got != expected {
+		t.Errorf("expected: %v, got: %v", expected, got)
+	}
+	r, err := gojq.Parse(q.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := r.String(); got != expected {
+		t.Errorf("expected: %v, got: %v", expected, got)
+	}
+	for _, tc := range []struct {
+		query    *gojq.Query
+		expected []any
+	}{
+		{q, []any{
+			map[string]any{"file": "<top-level>", "line": 2},
+			map[string]any{"__loc__": map[string]any{"file": "<top-level>", "line": 2}},
+			"2",
+		}},
+		{r, []any{
+			map[string]any{"file": "<top-level>", "line": 1},
+			map[string]any{"__loc__": map[string]any{"file": "<top-level>", "line": 1}},
+			"1",
+		}},
+	} {
+		var got []any
+		iter := tc.query.Run(nil)
+		for {
+			v, ok := iter.Next()
+			if !ok {
+				break
+			}
+			got = append(got, v)
+		}
+		if !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("expected: %v, got: %v", tc.expected, got)
+		}
+	}
+}
+
+func TestQueryVariableBinding(t *testing.T) {
+	const src = `def f($x; g): $x | g;
+. as [$x, {$y, a: $z}] ?// $w |
+reduce .[] as $x ($x; . + $x) |
+{$y, z: $z, w: "\($w)"} |
+f($x; $ENV) | $v`
+	// Returns the offset of the n-th occurrence (starting from 1) of the name.
+	index := func(name string, n int) int {
+		offset := -1
+		for ; n > 0; n-- {
+			offset += strings.Index(src[offset+1:], name) + 1
+		}
+		return offset
+	}
+	query, err := gojq.Parse(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		offset, expected int
+	}{
+		{index("$x", 2), index("$x", 1)},
+		{index("$x", 3), index("$x", 3)},
+		{index("$x", 5), index("$x", 3)},
+		{index("$x", 6), index("$x", 4)},
+		{index("$x", 7), index("$x", 3)},
+		{index("$y", 2), index("$y", 1)},
+		{index("$z", 2), index("$z", 1)},
+		{index("$w", 2), index("$w", 1)},
+		{index("$ENV", 1), -1},
+		{index("$v", 1), -1},
+		{index("reduce", 1), -1},
+	} {
+		if got := query.VariableBinding(tc.offset); got != tc.expected {
+			t.Errorf("VariableBinding(%d): expected: %d, got: %d", tc.offset, tc.expected, got)
+		}
+	}
+}
+
+func TestParse_TokenKind(t *testing.T) {
+	testCases := []struct {
+		src      string
+		token    string
+		expected gojq.TokenKind
+	}{
+		{".foo bar", "bar", gojq.TokenKindIdent},
+		{". 1", "1", gojq.TokenKindNumber},
+		{`1 "x"`, `"x"`, gojq.TokenKindString},
+		{". then", "then", gojq.TokenKindKeyword},
+		{". | | .", "|", gojq.TokenKindOp},
+		{". @base64", "@base64", gojq.TokenKindFormat},
+		{". $x", "$x", gojq.TokenKindVariable},
+		{". )", ")", gojq.TokenKindPunct},
+		{". +", "", gojq.TokenKindEOF},
+		{". 1.e", "1.e", gojq.TokenKindInvalid},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.src, func(t *testing.T) {
+			_, err := gojq.Parse(tc.src)
+			if err == nil {
+				t.Fatal("should emit an error but got no error")
+			}
+			e, ok := err.(interface {
+				Token() (string, int)
+				TokenKind() gojq.TokenKind
+			})
+			if !ok {
+				t.Fatalf("should have the methods Token and TokenKind: %v", err)
+			}
+			if token, _ := e.Token(); token != tc.token {
+				t.Errorf("expected: %q, got: %q", tc.token, token)
+			}
+			if kind := e.TokenKind(); kind != tc.expected {
+				t.Errorf("expected: %#v, got: %#v", tc.expected, kind)
+			}
+		})
+	}
+}
+
+func TestParse_Position(t *testing.T) {
+	testCases := []struct {
+		src          string
+		options      []gojq.ParseOption
+		line, column int
+	}{
+		{". )", nil, 1, 3},
+		{". +", nil, 1, 4},
+		{"1 +\n  2 )", nil, 2, 5},
+		{"1 +\r\n2\r3", nil, 3, 1},
+		{"# comment\n.foo bar", nil, 2, 6},
+		{`"あい" | "\x"`, nil, 1, 9},
+		{"[\n  1,\n  2 ]", []gojq.ParseOption{gojq.WithMaxArrayLiteralLength(1)}, 1, 1},
+		{`. | "abc"`, []gojq.ParseOption{gojq.WithMaxStringLiteralLength(2)}, 1, 5},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.src, func(t *testing.T) {
+			_, err := gojq.ParseWithOptions(tc.src, tc.options...)
+			var e gojq.ParseError
+			if !errors.As(err, &e) {
+				t.Fatalf("should emit a parse error but got: %v", err)
+			}
+			if line, column := e.Position(); line != tc.line || column != tc.column {
+				t.Errorf("expected: %d:%d, got: %d:%d", tc.line, tc.column, line, column)
+			}
+		})
+	}
+}
+
+func TestParse_MalformedQuery(t *testing.T) {
+	testCases := []struct {
+		src    string
+		err    string
+		token  string
+		offset int
+		kind   gojq.TokenKind
+	}{
+		{`"abc`, "unterminated string literal", "", 4, gojq.TokenKindInvalid},
+		{`"\(1`, "unexpected EOF", "", 4, gojq.TokenKindEOF},
+		{`"\x"`, `invalid escape sequence "\x" in string literal`, `\x`, 3, gojq.TokenKindInvalid},
+		{`"\u12"`, `invalid escape sequence "\u12" in string literal`, `\u12`, 5, gojq.TokenKindInvalid},
+		{". & .", `invalid token "&"`, "&", 3, gojq.TokenKindInvalid},
+		{". ! .", `invalid token "!"`, "!", 3, gojq.TokenKindInvalid},
+		{". @ .", `invalid token "@"`, "@", 3, gojq.TokenKindInvalid},
+		{"`.`", "invalid token \"`\"", "`", 1, gojq.TokenKindInvalid},
+		{". \x00 .", `invalid token "\u0000"`, "\x00", 3, gojq.TokenKindInvalid},
+		{".\x7f", `invalid token "\u007f"`, "\x7f", 2, gojq.TokenKindInvalid},
+		{". \u3042", "invalid token \"\u3042\"", "\u3042", 5, gojq.TokenKindInvalid},
+		{". \xff", `invalid token "\ufffd"`, "\xff", 3, gojq.TokenKindInvalid},
+		{strings.Repeat("[", 20000) + strings.Repeat("]", 20000),
+			"query nesting depth exceeds the limit 10000", "", 40000, gojq.TokenKindEOF},
+		{strings.Repeat(". | ", 20000) + ".",
+			"query nesting depth exceeds the limit 10000", "", 80001, gojq.TokenKindEOF},
+	}
+	for _, tc := range testCases {
+		name := tc.src
+		if len(name) > 20 {
+			name = name[:20]
+		}
+		t.Run(name, func(t *testing.T) {
+			_, err := gojq.Parse(tc.src)
+			if err == nil {
+				t.Fatal("should emit an error but got no error")
+			}
+			if err.Error() != tc.err {
+				t.Errorf("expected: %v, got: %v", tc.err, err)
+			}
+			e := err.(interface {
+				Token() (string, int)
+				TokenKind() gojq.TokenKind
+			})
+			if token, offset := e.Token(); token != tc.token || offset != tc.offset {
+				t.Errorf("expected: %q, %d, got: %q, %d", tc.token, tc.offset, token, offset)
+			}
+			if kind := e.TokenKind(); kind != tc.kind {
+				t.Errorf("expected: %v, got: %v", tc.kind, kind)
+			}
+		})
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	f.Add(`.foo | {a: .bar, "b\(1)": [.[]?, -1e3]} // empty`)
+	f.Add(`def f(g; $x): reduce g as [$a, {b: $c}] (0; . + $a) ?// $x; f(.; 1)`)
+	f.Add(`try error("x") catch . as $e | label $l | if $e then break $l else @base64 "\(.)" end`)
+	f.Add(`{$x, @base64 "k\(.)": (1, 2), (.[]): .a}`)
+	f.Fuzz(func(t *testing.T, src string) {
+		q, err := gojq.Parse(src)
+		if err != nil {
+			e, ok := err.(interface {
+				Token() (string, int)
+				TokenKind() gojq.TokenKind
+			})
+			if !ok {
+				t.Fatalf("should have the methods Token and TokenKind: %v", err)
+			}
+			if _, offset := e.Token(); offset < 0 || offset > len(src) {
+				t.Fatalf("invalid offset %d of %q: %v", offset, src, err)
+			}
+			return
+		}
+		if _, err := gojq.Parse(q.String()); err != nil {
+			t.Fatalf("failed to parse %q printed from %q: %v", q, src, err)
+		}
+	})
+}
+
+func TestParseWithOptions(t *testing.T) {
+	options := []gojq.ParseOption{
+		gojq.WithMaxStringLiteralLength(5),
+		gojq.WithMaxArrayLiteralLength(3),
+		gojq.WithMaxQueryDepth(8),
+	}
+	testCases := []struct {
+		src    string
+		err    string
+		offset int
+	}{
+		{src: `"abcde"`},
+		{src: `"abcdef"`, err: "string literal length 6 exceeds the limit 5", offset: 1},
+		{src: `. + "abc\u3042"`, err: "string literal length 6 exceeds the limit 5", offset: 5},
+		{src: `"\(.)abcde\(.)"`},
+		{src: `"ab\(.)abcdef"`, err: "string literal length 6 exceeds the limit 5", offset: 8},
+		{src: `{"abcdef": 1}`, err: "string literal length 6 exceeds the limit 5", offset: 2},
+		{src: `[]`},
+		{src: `[1, 2, 3]`},
+		{src: `[1, 2, 3, 4]`, err: "array literal length 4 exceeds the limit 3", offset: 1},
+		{src: `[1, [2, 3, 4, 5], 6]`, err: "array literal length 4 exceeds the limit 3", offset: 5},
+		{src: `[(1, 2, 3, 4), {a: 1, b: 2, c: 3, d: 4}, "\(1, 2, 3, 4)"]`},
+		{src: `. as [$a, $b, $c, $d] | $a`},
+		{src: `. as {a: [$a, $b, $c, $d]} ?// [[$a, $b, $c, $d]] | $a`},
+		{src: `. as {([1, 2, 3, 4] | tostring): $a} | $a`, err: "array literal length 4 exceeds the limit 3", offset: 8},
+		{src: `.[1, 2, 3, 4]`},
+		{src: `.a[1, 2, 3, 4] | $__loc__[1, 2, 3, 4]`},
+		{src: `[1][1, 2, 3, 4] | "a"[1, 2, 3, 4]`},
+		{src: `.[[1, 2, 3, 4]]`, err: "array literal length 4 exceeds the limit 3", offset: 3},
+		{src: `if . then [1, 2, 3, 4] else . end`, err: "array literal length 4 exceeds the limit 3", offset: 11},
+		{src: `[[[[[[[1]]]]]]]`},
+		{src: `[[[[[[[[1]]]]]]]]`, err: "query nesting depth exceeds the limit 8", offset: 17},
+		{src: `1 + 2 + 3 + 4 + 5 + 6 + 7 + 8`},
+		{src: `1 + 2 + 3 + 4 + 5 + 6 + 7 + 8 + 9`, err: "query nesting depth exceeds the limit 8", offset: 33},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.src, func(t *testing.T) {
+			_, err := gojq.ParseWithOptions(tc.src, options...)
+			if tc.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("should emit an error but got no error")
+			}
+			if err.Error() != tc.err {
+				t.Errorf("expected: %v, got: %v", tc.err, err)
+			}
+			if _, offset := err.(interface{ Token() (string, int) }).Token(); offset != tc.offset {
+				t.Errorf("expected: %v, got: %v", tc.offset, offset)
+			}
+		})
+	}
+	if _, err := gojq.ParseWithOptions(`["abcdef", 1, 2, 3, 4]`); err != nil {
+		t.Errorf("should not limit the literals by default: %v", err)
+	}
+	src := strings.Repeat("[", 20000) + strings.Repeat("]", 20000)
+	if _, err := gojq.ParseWithOptions(src, gojq.WithMaxQueryDepth(0)); err != nil {
+		t.Errorf("should not limit the depth with zero: %v", err)
+	}
+}
+
+func TestParseWithOptions_FileName(t *testing.T) {
+	query, err := gojq.ParseWithOptions("1 |\n$__loc__", gojq.WithFileName("foo.jq"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	iter := query.Run(nil)
+	v, _ := iter.Next()
+	expected := map[string]any{"file": "foo.jq", "line": 2}
+	if !reflect.DeepEqual(v, expected) {
+		t.Errorf("expected: %v, got: %v", expected, v)
+	}
+}
+
 func BenchmarkRun(b *testing.B) {
 	query, err := gojq.Parse("range(1000)")
 	if err != nil {
@@ -0,0 +1,134 @@
+package gojq
+
+import "context"
+
+// Reducer evaluates a reduce query incrementally. Compile a query like
+// reduce inputs as $x (0; . + $x) by [NewReducer], and feed the values by
+// [Reducer.Add] instead of the values of the source (inputs in this example),
+// which is ignored. The accumulator state is a plain value, so a long-running
+// process can take a snapshot by [Reducer.State], save it for example as JSON,
+// and resume from it after restart by [Reducer.Restore], without replaying all
+// the values. Do not use a reducer concurrently.
+type Reducer struct {
+	start, update *Code
+	state         any
+	values        []any
+}
+
+// NewReducer compiles the reduce query with the compiler options. The query
+// can have function definitions and imports, followed by the reduce syntax.
+func NewReducer(q *Query, options ...CompilerOption) (*Reducer, error) {
+	t := q.Term
+	if t == nil || t.Type != TermTypeReduce || len(t.SuffixList) > 0 {
+		return nil, &expectedReduceError{q}
+	}
+	e := t.Reduce
+	start, err := Compile(&Query{
+		Imports:  q.Imports,
+		FuncDefs: q.FuncDefs,
+		Term:     &Term{Type: TermTypeQuery, Query: e.Start},
+	}, options...)
+	if err != nil {
+		return nil, err
+	}
+	// The update query is applied to [state, value] as .[1] as $x | .[0] | update.
+	update, err := Compile(&Query{
+		Imports:  q.Imports,
+		FuncDefs: q.FuncDefs,
+		Term: &Term{
+			Type:  TermTypeIndex,
+			Index: &Index{Start: &Query{Term: &Term{Type: TermTypeNumber, Number: "1"}}},
+			SuffixList: []*Suffix{{Bind: &Bind{
+				Patterns: []*Pattern{e.Pattern},
+				Body: &Query{
+					Left: &Query{Term: &Term{
+						Type:  TermTypeIndex,
+						Index: &Index{Start: &Query{Term: &Term{Type: TermTypeNumber, Number: "0"}}},
+					}},
+					Op:    OpPipe,
+					Right: e.Update,
+				},
+			}}},
+		},
+	}, options...)
+	if err != nil {
+		return nil, err
+	}
+	return &Reducer{start: start, update: update}, nil
+}
+
+// Init evaluates the initial state against the input, with the variable
+// values (which should be in the same order as the given variables using
+// [WithVariables]). When the initial state query emits multiple values, the
+// last one is used; when it emits nothing, the state is null.
+func (r *Reducer) Init(v any, values ...any) error {
+	values, err := r.start.normalizeValues(values)
+	if err != nil {
+		return err
+	}
+	if v = normalizeValue(v, r.start.stringifyKeys); isNormalizeError(v) {
+		return v.(error)
+	}
+	state, err := r.run(r.start, v, values, nil)
+	if err != nil {
+		return err
+	}
+	r.state, r.values = state, values
+	return nil
+}
+
+// Add applies the update query to the state with the value bound to the
+// pattern. When the update query emits multiple values, the last one is the
+// new state; when it emits nothing, the state is unchanged. On error, the
+// state is kept as before the call.
+func (r *Reducer) Add(v any) error {
+	// The state is already normalized, so normalize only the value.
+	if v = normalizeValue(v, r.update.stringifyKeys); isNormalizeError(v) {
+		return v.(error)
+	}
+	state, err := r.run(r.update, []any{r.state, v}, r.values, r.state)
+	if err != nil {
+		return err
+	}
+	r.state = state
+	return nil
+}
+
+// State returns the current accumulator state, which is the result of the
+// reduce query on the values added so far.
+func (r *Reducer) State() any {
+	return r.state
+}
+
+// Restore sets the accumulator state taken by [Reducer.State], with the
+// variable values, instead of calling [Reducer.Init].
+func (r *Reducer) Restore(state any, values ...any) error {
+	values, err := r.start.normalizeValues(values)
+	if err != nil {
+		return err
+	}
+	if state = normalizeValue(state, r.start.stringifyKeys); isNormalizeError(state) {
+		return state.(error)
+	}
+	r.state, r.values = state, values
+	return nil
+}
+
+// Runs the code against the normalized value with the normalized variable
+// values, and returns the last output or the state if nothing is emitted.
+func (r *Reducer) run(c *Code, v any, values []any, state any) (any, error) {
+	if len(values) < len(c.variables) { // Add before Init or Restore
+		return nil, &expectedVariableError{c.variables[len(values)]}
+	}
+	iter := newEnv(context.Background()).execute(c, v, values...)
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			return state, nil
+		}
+		if err, ok := v.(error); ok {
+			return nil, err
+		}
+		state = v
+	}
+}
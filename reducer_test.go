@@ -0,0 +1,171 @@
+package gojq_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"testing"
+
+	"github.com/rturpen/gojq"
+)
+
+func ExampleReducer() {
+	query, err := gojq.Parse(`reduce inputs as {$name, $count} ({}; .[$name] += $count)`)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	reducer, err := gojq.NewReducer(query)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if err := reducer.Init(nil); err != nil {
+		log.Fatalln(err)
+	}
+	for _, v := range []any{
+		map[string]any{"name": "foo", "count": 1},
+		map[string]any{"name": "bar", "count": 2},
+	} {
+		if err := reducer.Add(v); err != nil {
+			log.Fatalln(err)
+		}
+	}
+	// Take a snapshot of the state, and resume from it.
+	snapshot, err := json.Marshal(reducer.State())
+	if err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Printf("%s\n", snapshot)
+	var state any
+	if err := json.Unmarshal(snapshot, &state); err != nil {
+		log.Fatalln(err)
+	}
+	if reducer, err = gojq.NewReducer(query); err != nil {
+		log.Fatalln(err)
+	}
+	if err := reducer.Restore(state); err != nil {
+		log.Fatalln(err)
+	}
+	if err := reducer.Add(map[string]any{"name": "foo", "count": 3}); err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Printf("%v\n", reducer.State())
+
+	// Output:
+	// {"bar":2,"foo":1}
+	// map[bar:2 foo:4]
+}
+
+func TestReducer(t *testing.T) {
+	query, err := gojq.Parse(`
+		def f($x): [$x, .] | max;
+		reduce .[] as [$x] ($init * 2, 10; f($x) | select(. < 20))
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reducer, err := gojq.NewReducer(query, gojq.WithVariables([]string{"$init"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reducer.Init(nil, 1); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		v        any
+		expected any
+		err      string
+	}{
+		{[]any{5}, 10, ""},
+		{[]any{15}, 15, ""},
+		{[]any{25}, 15, ""},
+		{[]any{"x"}, 15, ""},
+		{map[string]any{}, 15, "expected an array but got: object ({})"},
+		{[]any{json.Number("19")}, 19, ""},
+	} {
+		err := reducer.Add(tc.v)
+		if tc.err == "" {
+			if err != nil {
+				t.Errorf("Add(%v): %v", tc.v, err)
+			}
+		} else if err == nil || err.Error() != tc.err {
+			t.Errorf("Add(%v): expected error %q but got: %v", tc.v, tc.err, err)
+		}
+		if got := reducer.State(); !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("Add(%v): expected: %v, got: %v", tc.v, tc.expected, got)
+		}
+	}
+}
+
+func TestReducerError(t *testing.T) {
+	for _, src := range []string{".", "reduce . as $x (0; 1) | .", "(reduce . as $x (0; 1))"} {
+		query, err := gojq.Parse(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = gojq.NewReducer(query)
+		if got, expected := fmt.Sprint(err), "expected a reduce query but got: "+src; got != expected {
+			t.Errorf("expected: %v, got: %v", expected, got)
+		}
+	}
+	query, err := gojq.Parse("reduce . as $x (error; .)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reducer, err := gojq.NewReducer(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err, expected := reducer.Init("x"), "error: x"; err == nil || err.Error() != expected {
+		t.Errorf("expected: %v, got: %v", expected, err)
+	}
+	if query, err = gojq.Parse("reduce . as $x (0; . + $y)"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = gojq.NewReducer(query)
+	if got, expected := fmt.Sprint(err), "variable not defined: $y"; got != expected {
+		t.Errorf("expected: %v, got: %v", expected, got)
+	}
+	reducer, err = gojq.NewReducer(query, gojq.WithVariables([]string{"$y"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err, expected := reducer.Add(1), "variable defined but not bound: $y"; fmt.Sprint(err) != expected {
+		t.Errorf("expected: %v, got: %v", expected, err)
+	}
+	if err, expected := reducer.Restore(0, 1, 2), "too many variable values provided"; fmt.Sprint(err) != expected {
+		t.Errorf("expected: %v, got: %v", expected, err)
+	}
+}
+
+func TestReducerAdd_Allocs(t *testing.T) {
+	query, err := gojq.Parse("reduce inputs as $x (null; .[0] += $x)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reducer, err := gojq.NewReducer(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addAllocs := func(size int) float64 {
+		state := make([]any, size)
+		for i := range state {
+			state[i] = map[string]any{"x": []any{i}}
+		}
+		state[0] = 0
+		if err := reducer.Restore(state); err != nil {
+			t.Fatal(err)
+		}
+		return testing.AllocsPerRun(10, func() {
+			if err := reducer.Add(1); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+	// The state is not normalized again on each call, so the allocations
+	// depend only on the copy of the updated array.
+	small, large := addAllocs(10), addAllocs(10000)
+	if large > small+1 {
+		t.Errorf("expected allocations: %v, got: %v", small, large)
+	}
+}
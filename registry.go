@@ -0,0 +1,144 @@
+package gojq
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"sync"
+)
+
+// Registry is a concurrent-safe store of compiled queries by their names, for
+// the applications evaluating many stored queries (like rules of a rule engine).
+// The compiled codes are addressed by the hash of the query sources, so the
+// queries of the same source are compiled once and shared between the names.
+type Registry struct {
+	options []CompilerOption
+	mu      sync.RWMutex
+	entries map[string]RegistryEntry
+	codes   map[string]*registryCode
+}
+
+// RegistryEntry is an entry of [Registry].
+type RegistryEntry struct {
+	// Code is the compiled query.
+	Code *Code
+	// Hash is the SHA-256 hash of the query source in hexadecimal.
+	Hash string
+	// Version starts from 1, and is incremented on replacing the query source.
+	Version int
+}
+
+type registryCode struct {
+	code  *Code
+	count int
+}
+
+// NewRegistry creates a new [Registry] with the compiler options, which are
+// used on compiling all the queries.
+func NewRegistry(options ...CompilerOption) *Registry {
+	return &Registry{
+		options: options,
+		entries: make(map[string]RegistryEntry),
+		codes:   make(map[string]*registryCode),
+	}
+}
+
+// Put parses and compiles the query source, and stores it by the name. If the
+// name already exists, the entry is replaced atomically; the callers of
+// [Registry.Get] get either the previous entry or the new one. The version is
+// not incremented when the source is the same as the current one. On error,
+// the current entry is kept unchanged.
+func (r *Registry) Put(name, src string) (RegistryEntry, error) {
+	hash := registryHash(src)
+	r.mu.RLock()
+	e, ok := r.entries[name]
+	c := r.codes[hash]
+	r.mu.RUnlock()
+	if ok && e.Hash == hash {
+		return e, nil
+	}
+	var code *Code
+	if c != nil {
+		code = c.code
+	} else {
+		q, err := Parse(src)
+		if err != nil {
+			return RegistryEntry{}, &queryParseError{name, src, err}
+		}
+		if code, err = Compile(q, r.options...); err != nil {
+			return RegistryEntry{}, err
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e = r.entries[name]
+	if e.Hash == hash {
+		return e, nil
+	}
+	if c = r.codes[hash]; c == nil {
+		c = &registryCode{code: code}
+		r.codes[hash] = c
+	}
+	c.count++
+	r.release(e)
+	e = RegistryEntry{c.code, hash, e.Version + 1}
+	r.entries[name] = e
+	return e, nil
+}
+
+// Get returns the entry of the name.
+func (r *Registry) Get(name string) (RegistryEntry, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	e, ok := r.entries[name]
+	return e, ok
+}
+
+// Lookup returns the compiled code by the hash of the query source, which is
+// the SHA-256 hash in hexadecimal like [RegistryEntry.Hash]. The code is found
+// while any entry of the source is stored.
+func (r *Registry) Lookup(hash string) (*Code, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if c := r.codes[hash]; c != nil {
+		return c.code, true
+	}
+	return nil, false
+}
+
+// Delete deletes the entry of the name. The version restarts from 1 when the
+// name is stored again.
+func (r *Registry) Delete(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if e, ok := r.entries[name]; ok {
+		delete(r.entries, name)
+		r.release(e)
+	}
+}
+
+// Names returns the names of the entries in the sorted order.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.entries))
+	for name := range r.entries {
+		names = append(names, name)
+	}
+	r.mu.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
+// Releases the compiled code of the entry when no other entries refer to it.
+func (r *Registry) release(e RegistryEntry) {
+	if c := r.codes[e.Hash]; c != nil {
+		if c.count--; c.count == 0 {
+			delete(r.codes, e.Hash)
+		}
+	}
+}
+
+func registryHash(src string) string {
+	h := sha256.Sum256([]byte(src))
+	return hex.EncodeToString(h[:])
+}
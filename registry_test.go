@@ -0,0 +1,165 @@
+package gojq_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/rturpen/gojq"
+)
+
+func ExampleRegistry() {
+	registry := gojq.NewRegistry()
+	for name, src := range map[string]string{
+		"large": ".size > 100",
+		"admin": `.user == "admin"`,
+	} {
+		if _, err := registry.Put(name, src); err != nil {
+			log.Fatalln(err)
+		}
+	}
+	e, err := registry.Put("large", ".size > 1000")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Printf("version: %d\n", e.Version)
+	event := map[string]any{"user": "admin", "size": 500}
+	for _, name := range registry.Names() {
+		e, _ := registry.Get(name)
+		iter := e.Code.Run(event)
+		for {
+			v, ok := iter.Next()
+			if !ok {
+				break
+			}
+			if err, ok := v.(error); ok {
+				log.Fatalln(err)
+			}
+			fmt.Printf("%s: %v\n", name, v)
+		}
+	}
+
+	// Output:
+	// version: 2
+	// admin: true
+	// large: false
+}
+
+func TestRegistry(t *testing.T) {
+	registry := gojq.NewRegistry(gojq.WithVariables([]string{"$x"}))
+	e1, err := registry.Put("a", ". + $x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e1.Version != 1 || len(e1.Hash) != 64 {
+		t.Errorf("unexpected entry: %+v", e1)
+	}
+	e2, err := registry.Put("b", ". + $x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e2.Code != e1.Code || e2.Hash != e1.Hash || e2.Version != 1 {
+		t.Errorf("expected the code to be shared: %+v, %+v", e1, e2)
+	}
+	if e, err := registry.Put("a", ". + $x"); err != nil || e != e1 {
+		t.Errorf("expected the same entry: %+v, %+v, %v", e, e1, err)
+	}
+	if _, err := registry.Put("a", ". +"); err == nil {
+		t.Errorf("expected an error")
+	} else if expected := "invalid query: a: unexpected EOF"; err.Error() != expected {
+		t.Errorf("expected: %v, got: %v", expected, err)
+	}
+	if _, err := registry.Put("a", ". + $y"); err == nil {
+		t.Errorf("expected an error")
+	} else if expected := "variable not defined: $y"; err.Error() != expected {
+		t.Errorf("expected: %v, got: %v", expected, err)
+	}
+	if e, ok := registry.Get("a"); !ok || e != e1 {
+		t.Errorf("expected the entry unchanged: %+v, %+v", e, e1)
+	}
+	e3, err := registry.Put("a", ". - $x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e3.Version != 2 || e3.Hash == e1.Hash {
+		t.Errorf("unexpected entry: %+v", e3)
+	}
+	if v, _ := e3.Code.Run(3, 1).Next(); v != 2 {
+		t.Errorf("expected: %v, got: %v", 2, v)
+	}
+	registry.Delete("a")
+	registry.Delete("c")
+	if _, ok := registry.Get("a"); ok {
+		t.Errorf("expected the entry to be deleted")
+	}
+	if names := fmt.Sprint(registry.Names()); names != "[b]" {
+		t.Errorf("expected: %v, got: %v", "[b]", names)
+	}
+	if e, _ := registry.Put("a", ". - $x"); e.Version != 1 {
+		t.Errorf("expected the version to restart: %+v", e)
+	}
+}
+
+func TestRegistryLookup(t *testing.T) {
+	registry := gojq.NewRegistry()
+	src := ".foo"
+	h := sha256.Sum256([]byte(src))
+	hash := hex.EncodeToString(h[:])
+	if _, ok := registry.Lookup(hash); ok {
+		t.Errorf("expected the code not to be found")
+	}
+	e1, err := registry.Put("a", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e1.Hash != hash {
+		t.Errorf("expected: %v, got: %v", hash, e1.Hash)
+	}
+	if code, ok := registry.Lookup(hash); !ok || code != e1.Code {
+		t.Errorf("expected the code of the entry: %v, %v", code, ok)
+	}
+	if _, err := registry.Put("b", src); err != nil {
+		t.Fatal(err)
+	}
+	registry.Delete("a")
+	if code, ok := registry.Lookup(hash); !ok || code != e1.Code {
+		t.Errorf("expected the code shared with the other entry: %v, %v", code, ok)
+	}
+	if _, err := registry.Put("b", ".bar"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := registry.Lookup(hash); ok {
+		t.Errorf("expected the code to be released")
+	}
+}
+
+func TestRegistryConcurrency(t *testing.T) {
+	registry := gojq.NewRegistry()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				name := strconv.Itoa(j % 10)
+				if _, err := registry.Put(name, strconv.Itoa(i%3)); err != nil {
+					t.Error(err)
+					return
+				}
+				if e, ok := registry.Get(name); ok { // may be deleted by others
+					if v, _ := e.Code.Run(nil).Next(); v != 0 && v != 1 && v != 2 {
+						t.Errorf("unexpected value: %v", v)
+					}
+				}
+				if j%7 == 0 {
+					registry.Delete(name)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
@@ -14,3 +14,5 @@ func (env *env) debugCodes() {}
 func (env *env) debugState(int, bool) {}
 
 func (env *env) debugForks(int, string) {}
+
+func (c *compiler) verifyCodes() error { return nil }
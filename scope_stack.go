@@ -11,8 +11,8 @@ type scopeBlock struct {
 	next  int
 }
 
-func newScopeStack() *scopeStack {
-	return &scopeStack{index: -1, limit: -1}
+func newScopeStack() scopeStack {
+	return scopeStack{index: -1, limit: -1}
 }
 
 func (s *scopeStack) push(v scope) {
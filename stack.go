@@ -11,8 +11,8 @@ type block struct {
 	next  int
 }
 
-func newStack() *stack {
-	return &stack{index: -1, limit: -1}
+func newStack() stack {
+	return stack{index: -1, limit: -1}
 }
 
 func (s *stack) push(v any) {
@@ -54,3 +54,10 @@ func (s *stack) save() (index, limit int) {
 func (s *stack) restore(index, limit int) {
 	s.index, s.limit = index, limit
 }
+
+func (s *stack) clear() {
+	for i := range s.data {
+		s.data[i] = block{}
+	}
+	s.restore(-1, -1)
+}
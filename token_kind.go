@@ -0,0 +1,104 @@
+package gojq
+
+// TokenKind represents the kind of a token in the query string.
+type TokenKind int
+
+// TokenKind list.
+const (
+	TokenKindInvalid TokenKind = iota
+	TokenKindEOF
+	TokenKindIdent
+	TokenKindNumber
+	TokenKindString
+	TokenKindKeyword
+	TokenKindOp
+	TokenKindFormat
+	TokenKindVariable
+	TokenKindPunct
+)
+
+// String implements [fmt.Stringer].
+func (kind TokenKind) String() string {
+	switch kind {
+	case TokenKindInvalid:
+		return "invalid"
+	case TokenKindEOF:
+		return "EOF"
+	case TokenKindIdent:
+		return "identifier"
+	case TokenKindNumber:
+		return "number"
+	case TokenKindString:
+		return "string"
+	case TokenKindKeyword:
+		return "keyword"
+	case TokenKindOp:
+		return "operator"
+	case TokenKindFormat:
+		return "format"
+	case TokenKindVariable:
+		return "variable"
+	case TokenKindPunct:
+		return "punctuation"
+	default:
+		panic(kind)
+	}
+}
+
+// GoString implements [fmt.GoStringer].
+func (kind TokenKind) GoString() (str string) {
+	defer func() { str = "gojq." + str }()
+	switch kind {
+	case TokenKindInvalid:
+		return "TokenKindInvalid"
+	case TokenKindEOF:
+		return "TokenKindEOF"
+	case TokenKindIdent:
+		return "TokenKindIdent"
+	case TokenKindNumber:
+		return "TokenKindNumber"
+	case TokenKindString:
+		return "TokenKindString"
+	case TokenKindKeyword:
+		return "TokenKindKeyword"
+	case TokenKindOp:
+		return "TokenKindOp"
+	case TokenKindFormat:
+		return "TokenKindFormat"
+	case TokenKindVariable:
+		return "TokenKindVariable"
+	case TokenKindPunct:
+		return "TokenKindPunct"
+	default:
+		panic(kind)
+	}
+}
+
+// Maps the token type of the lexer to the token kind.
+func toTokenKind(tokenType int) TokenKind {
+	switch tokenType {
+	case eof:
+		return TokenKindEOF
+	case tokIdent, tokModuleIdent, tokIndex:
+		return TokenKindIdent
+	case tokNumber:
+		return TokenKindNumber
+	case tokString, tokStringStart, tokStringQuery, tokStringEnd:
+		return TokenKindString
+	case tokModule, tokImport, tokInclude, tokDef, tokAs, tokLabel, tokBreak,
+		tokNull, tokTrue, tokFalse, tokIf, tokThen, tokElif, tokElse, tokEnd,
+		tokTry, tokCatch, tokReduce, tokForeach:
+		return TokenKindKeyword
+	case tokAltOp, tokUpdateOp, tokDestAltOp, tokOrOp, tokAndOp, tokCompareOp,
+		tokRecurse, '|', ',', '+', '-', '*', '/', '%', '?':
+		return TokenKindOp
+	case tokFormat:
+		return TokenKindFormat
+	case tokVariable, tokModuleVariable:
+		return TokenKindVariable
+	case '.', '(', ')', '[', ']', '{', '}', ':', ';', '$':
+		return TokenKindPunct
+	default:
+		return TokenKindInvalid
+	}
+}
@@ -8,9 +8,10 @@ import (
 // TypeOf returns the jq-flavored type name of v.
 //
 // This method is used by built-in type/0 function, and accepts only limited
-// types (nil, bool, int, float64, *big.Int, string, []any, and map[string]any).
+// types (nil, bool, int, float64, *big.Int, string, []any, and map[string]any),
+// and the types implementing [JQMarshaler].
 func TypeOf(v any) string {
-	switch v.(type) {
+	switch v := v.(type) {
 	case nil:
 		return "null"
 	case bool:
@@ -23,6 +24,8 @@ func TypeOf(v any) string {
 		return "array"
 	case map[string]any:
 		return "object"
+	case JQMarshaler:
+		return TypeOf(marshalJQ(v))
 	default:
 		panic(fmt.Sprintf("invalid type: %[1]T (%[1]v)", v))
 	}